@@ -0,0 +1,11 @@
+-- Test file: 1.sql
+
+DROP TABLE IF EXISTS {{.test_table}};
+
+CREATE TABLE {{.test_table}} (
+  age Int32,
+  firstName String,
+  city Nullable(String),
+  country LowCardinality(String)
+) ENGINE = MergeTree
+ORDER BY age;
@@ -0,0 +1,6 @@
+-- Test file: 2.sql
+
+INSERT INTO {{.test_table}} VALUES (18, 'john', 'new york', 'us');
+INSERT INTO {{.test_table}}
+SELECT 20 + number * 2, ['ben', 'anna'][number + 1], NULL, ['uk', 'fr'][number + 1]
+FROM numbers(2);
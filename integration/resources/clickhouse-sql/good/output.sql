@@ -0,0 +1,4 @@
+-- Test file: output.sql
+
+SELECT 'multiple statements; one script' AS note;
+SELECT * FROM {{.test_table}} ORDER BY age;
@@ -0,0 +1,4 @@
+-- Test file: 1.sql
+
+CREATE TABLE
+GO
@@ -0,0 +1,17 @@
+-- Test file: 1.sql
+
+DROP VIEW IF EXISTS adults;
+DROP TABLE IF EXISTS table1;
+
+CREATE TABLE table1 (
+  age int,
+  firstName nvarchar(255),
+  city nvarchar(255),
+  country nvarchar(255)
+);
+GO
+
+-- CREATE VIEW must be the only statement of its batch
+CREATE VIEW adults AS
+SELECT firstName, country FROM table1 WHERE age >= 18;
+GO
@@ -0,0 +1,13 @@
+-- Test file: 2.sql
+
+-- The temp table lives as long as the connection, which all batches share
+CREATE TABLE #staged (age int, firstName nvarchar(255), city nvarchar(255), country nvarchar(255));
+INSERT INTO #staged VALUES (18, 'john', 'new york', 'us');
+GO
+INSERT INTO #staged VALUES
+(20, 'ben', 'london', 'uk'),
+(22, 'anna', 'paris', 'fr');
+GO
+INSERT INTO table1 SELECT * FROM #staged;
+DROP TABLE #staged;
+GO
@@ -0,0 +1,3 @@
+-- Test file: encrypted.sql
+
+SELECT encrypt_option FROM sys.dm_exec_connections WHERE session_id = @@SPID;
@@ -0,0 +1,6 @@
+-- Test file: output.sql
+
+SELECT 'multiple result sets' AS note;
+SELECT * FROM table1 ORDER BY age;
+GO
+SELECT * FROM adults ORDER BY firstName;
@@ -0,0 +1,10 @@
+-- Test file: 1.sql
+
+DROP TABLE IF EXISTS table1;
+
+CREATE TABLE table1 (
+  age int,
+  firstName varchar(255),
+  city varchar(255),
+  country varchar(255)
+);
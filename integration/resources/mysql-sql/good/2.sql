@@ -0,0 +1,6 @@
+-- Test file: 2.sql
+
+INSERT INTO table1 VALUES (18, 'john', 'new york', 'us');
+INSERT INTO table1 VALUES
+(20, 'ben', 'london', 'uk'),
+(22, 'anna', 'paris', 'fr');
@@ -0,0 +1,4 @@
+-- Test file: output.sql
+
+SELECT 'multiple result sets' AS note;
+SELECT * FROM table1 ORDER BY age;
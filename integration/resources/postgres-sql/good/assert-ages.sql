@@ -0,0 +1,3 @@
+-- Test file: assert-ages.sql
+
+SELECT * FROM {{.test_schema}}.table1 WHERE age < 0 OR age > 150;
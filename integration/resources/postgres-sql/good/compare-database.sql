@@ -0,0 +1,3 @@
+-- Test file: compare-database.sql
+
+SELECT current_database() AS database_name;
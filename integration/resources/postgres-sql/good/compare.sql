@@ -0,0 +1,3 @@
+-- Test file: compare.sql
+
+SELECT age, firstName, city, country FROM {{.test_schema}}.table1;
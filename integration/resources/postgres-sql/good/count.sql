@@ -0,0 +1,3 @@
+-- Test file: count.sql
+
+SELECT COUNT(*) AS row_count FROM {{.test_schema}}.table1;
@@ -0,0 +1 @@
+SELECT current_database();
@@ -0,0 +1,3 @@
+-- Test file: missing.sql
+
+SELECT * FROM validate_missing_table;
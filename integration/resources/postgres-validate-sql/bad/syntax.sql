@@ -0,0 +1,5 @@
+-- Test file: syntax.sql
+
+SELECT 1;
+
+INSERT INTO validate_probe VALUES (1,;
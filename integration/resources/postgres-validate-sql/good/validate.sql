@@ -0,0 +1,16 @@
+-- Test file: validate.sql
+
+DROP TABLE IF EXISTS validate_probe;
+
+CREATE TABLE validate_probe AS
+SELECT table_schema, count(*) AS tables
+FROM information_schema.tables
+GROUP BY 1;
+
+INSERT INTO validate_probe SELECT 'none', 0;
+
+CREATE TABLE validate_staged (id int);
+
+INSERT INTO validate_staged VALUES (1);
+
+SELECT * FROM information_schema.schemata WHERE schema_name = 'public';
@@ -0,0 +1 @@
+SELECT left(current_user, 2);
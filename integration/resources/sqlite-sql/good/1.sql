@@ -0,0 +1,12 @@
+-- Test file: 1.sql
+
+CREATE TABLE {{.test_table}} (
+  age int,
+  firstName varchar(255),
+  city varchar(255),
+  country varchar(255)
+);
+
+INSERT INTO {{.test_table}} VALUES
+(18, 'john', 'new york', 'us'),
+(20, 'ben', 'london', 'uk');
@@ -0,0 +1,4 @@
+-- Test file: output.sql
+
+SELECT COUNT(*) AS row_count FROM {{.test_table}};
+SELECT * FROM {{.test_table}} ORDER BY age;
@@ -0,0 +1,3 @@
+-- Test file: 1.sql
+
+CREATE TABLE
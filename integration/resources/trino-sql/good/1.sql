@@ -0,0 +1,10 @@
+-- Test file: 1.sql
+
+DROP TABLE IF EXISTS table1;
+
+CREATE TABLE table1 (
+  age integer,
+  firstName varchar,
+  city varchar,
+  country varchar
+);
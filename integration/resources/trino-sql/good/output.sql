@@ -0,0 +1,4 @@
+-- Test file: output.sql
+
+SELECT 'one statement per request; split by sql-runner' AS note;
+SELECT * FROM table1 ORDER BY age;
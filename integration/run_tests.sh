@@ -126,6 +126,78 @@ assert_ExitCodeForCommand "0" "${bin_path} -playbook ${root_key}/good-postgres.y
 # Test: Valid playbook outputs proper results from playbooks using -showQueryOutput
 assert_ExitCodeForCommand "6" "${bin_path} -showQueryOutput -playbook ${root_key}/good-postgres.yml"
 
+# Test: Valid playbook with a failing assertion should return exit code 6
+assert_ExitCodeForCommand "6" "${bin_path} -playbook ${root_key}/bad-postgres-assert.yml"
+
+# Test: Valid playbook whose checks only raise warnings should return exit code 0
+assert_ExitCodeForCommand "0" "${bin_path} -playbook ${root_key}/good-postgres-checks.yml"
+
+# Test: Valid playbook comparing matching results across targets should return exit code 0
+assert_ExitCodeForCommand "0" "${bin_path} -playbook ${root_key}/good-postgres-compare.yml"
+
+# Test: Valid playbook comparing differing results across targets should return exit code 6
+assert_ExitCodeForCommand "6" "${bin_path} -playbook ${root_key}/bad-postgres-compare.yml"
+
+# Test: Valid MySQL playbook with multi-statement scripts should return exit code 0
+assert_ExitCodeForCommand "0" "${bin_path} -playbook ${root_key}/good-mysql.yml"
+assert_ExitCodeForCommand "0" "${bin_path} -showQueryOutput -playbook ${root_key}/good-mysql.yml"
+
+# Test: MySQL playbook with invalid query should return exit code 6
+assert_ExitCodeForCommand "6" "${bin_path} -playbook ${root_key}/bad-mysql.yml"
+
+# Test: Valid SQLite playbook runs fully offline and should return exit code 0
+assert_ExitCodeForCommand "0" "${bin_path} -showQueryOutput -playbook ${root_key}/good-sqlite.yml"
+
+# Test: Valid ClickHouse playbooks over the native and HTTP protocols should return exit code 0
+assert_ExitCodeForCommand "0" "${bin_path} -playbook ${root_key}/good-clickhouse.yml"
+assert_ExitCodeForCommand "0" "${bin_path} -showQueryOutput -playbook ${root_key}/good-clickhouse.yml"
+assert_ExitCodeForCommand "0" "${bin_path} -playbook ${root_key}/good-clickhouse-http.yml"
+
+# Test: ClickHouse playbook with invalid query should return exit code 6
+assert_ExitCodeForCommand "6" "${bin_path} -playbook ${root_key}/bad-clickhouse.yml"
+
+# Test: Valid SQL Server playbook with GO separated batches should return exit code 0
+assert_ExitCodeForCommand "0" "${bin_path} -playbook ${root_key}/good-mssql.yml"
+assert_ExitCodeForCommand "0" "${bin_path} -showQueryOutput -playbook ${root_key}/good-mssql.yml"
+assert_ExitCodeForCommand "0" "${bin_path} -playbook ${root_key}/good-mssql-encrypted.yml"
+
+# Test: SQL Server playbook with invalid query should return exit code 6
+assert_ExitCodeForCommand "6" "${bin_path} -playbook ${root_key}/bad-mssql.yml"
+
+# Test: Valid Trino playbook split into single statements should return exit code 0
+assert_ExitCodeForCommand "0" "${bin_path} -playbook ${root_key}/good-trino.yml"
+assert_ExitCodeForCommand "0" "${bin_path} -showQueryOutput -playbook ${root_key}/good-trino.yml"
+
+# Test: Trino playbook with invalid query should return exit code 6
+assert_ExitCodeForCommand "6" "${bin_path} -playbook ${root_key}/bad-trino.yml"
+
+# Test: Valid generic playbook using a compiled-in database/sql driver should return exit code 0
+assert_ExitCodeForCommand "0" "${bin_path} -showQueryOutput -playbook ${root_key}/good-generic.yml"
+
+# Test: Valid Postgres playbook verifying the server and client certificates should return exit code 0
+assert_ExitCodeForCommand "0" "${bin_path} -playbook ${root_key}/good-postgres-tls.yml"
+
+# Test: Postgres playbook expecting another server name should return exit code 6
+assert_ExitCodeForCommand "6" "${bin_path} -playbook ${root_key}/bad-postgres-tls.yml"
+
+# Test: Valid Postgres playbook connecting through an SSH bastion should return exit code 0
+assert_ExitCodeForCommand "0" "${bin_path} -playbook ${root_key}/good-postgres-ssh.yml"
+
+# Test: Valid Postgres playbook validated with -dryRun -validate should return exit code 0
+assert_ExitCodeForCommand "0" "${bin_path} -playbook ${root_key}/good-postgres-validate.yml -dryRun -validate"
+
+# Test: Postgres playbook with a missing relation and a syntax error validated with -dryRun -validate should return exit code 6
+assert_ExitCodeForCommand "6" "${bin_path} -playbook ${root_key}/bad-postgres-validate.yml -dryRun -validate"
+
+# Test: -validate without -dryRun should return exit code 2
+assert_ExitCodeForCommand "2" "${bin_path} -playbook ${root_key}/good-postgres-validate.yml -validate"
+
+# Test: Valid Postgres playbook connecting with credentials leased from Vault should return exit code 0
+assert_ExitCodeForCommand "0" "SQL_RUNNER_VAULT_TOKEN=root ${bin_path} -playbook ${root_key}/good-postgres-vault.yml"
+
+# Test: Postgres playbook whose Vault token is not set should return exit code 5
+assert_ExitCodeForCommand "5" "${bin_path} -playbook ${root_key}/good-postgres-vault.yml"
+
 # Test: Valid playbook which uses playbook template variables
 assert_ExitCodeForCommand "6" "${bin_path} -playbook ${root_key}/good-postgres-with-template.yml -var password=,host=localhost"
 assert_ExitCodeForCommand "6" "${bin_path} -playbook ${root_key}/good-postgres-with-template.yml"
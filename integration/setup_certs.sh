@@ -0,0 +1,57 @@
+#!/bin/bash
+
+# Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+#
+# This program is licensed to you under the Apache License Version 2.0,
+# and you may not use this file except in compliance with the Apache License Version 2.0.
+# You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+#
+# Unless required by applicable law or agreed to in writing,
+# software distributed under the Apache License Version 2.0 is distributed on an
+# "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+
+set -e
+
+# -----------------------------------------------------------------------------
+#  CONSTANTS
+# -----------------------------------------------------------------------------
+
+DIR="$( cd "$( dirname "${BASH_SOURCE[0]}" )" >/dev/null 2>&1 && pwd )"
+CERTS_DIR=${DIR}/certs
+
+# -----------------------------------------------------------------------------
+#  EXECUTION
+# -----------------------------------------------------------------------------
+
+echo " --- Generating a CA and certificates for the TLS integration tests --- "
+
+mkdir -p ${CERTS_DIR}
+cd ${CERTS_DIR}
+
+openssl req -x509 -new -nodes -newkey rsa:2048 -days 30 \
+  -subj "/CN=sql-runner integration CA" \
+  -keyout ca.key -out ca.crt
+
+openssl req -new -nodes -newkey rsa:2048 \
+  -subj "/CN=localhost" \
+  -keyout server.key -out server.csr
+printf "subjectAltName=DNS:localhost\n" > server.ext
+openssl x509 -req -in server.csr -CA ca.crt -CAkey ca.key -CAcreateserial \
+  -days 30 -extfile server.ext -out server.crt
+
+openssl req -new -nodes -newkey rsa:2048 \
+  -subj "/CN=snowplow" \
+  -keyout client.key -out client.csr
+openssl x509 -req -in client.csr -CA ca.crt -CAkey ca.key -CAcreateserial \
+  -days 30 -out client.crt
+
+chmod 644 server.key client.key
+rm -f server.csr client.csr server.ext
+
+echo " --- Generating the keys of the SSH bastion for the tunnel integration tests --- "
+
+rm -f ssh_host_ed25519_key* ssh_client_key*
+ssh-keygen -q -t ed25519 -N "" -f ssh_host_ed25519_key
+ssh-keygen -q -t ed25519 -N "" -f ssh_client_key
+echo "[localhost]:2223 $(cat ssh_host_ed25519_key.pub)" > known_hosts
@@ -0,0 +1,178 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+)
+
+const (
+	defaultSampleSize = 10
+	sampleIndent      = "    "
+)
+
+// Assertion describes the output a query is expected to produce.
+// All configured expectations must hold for the query to succeed.
+type Assertion struct {
+	ExpectRows  *int    `yaml:"expect_rows"`
+	MaxRows     *int    `yaml:"max_rows"`
+	ExpectValue *string `yaml:"expect_value"`
+	Operator    string
+	Threshold   *float64
+	SampleSize  int `yaml:"sample_size"`
+}
+
+// AssertionError reports a failed Assertion along with
+// a sample of the rows which caused it.
+type AssertionError struct {
+	Message string
+	Sample  ResultSet
+}
+
+// Error implements error.
+func (e *AssertionError) Error() string {
+	if len(e.Sample.Rows) == 0 {
+		return e.Message
+	}
+
+	return fmt.Sprintf("%s, SAMPLE:\n%s", e.Message, indentedTable(e.Sample, sampleIndent))
+}
+
+// Validate checks that the assertion is usable.
+func (a Assertion) Validate() error {
+	if a.ExpectRows == nil && a.MaxRows == nil && a.ExpectValue == nil && a.Threshold == nil {
+		return fmt.Errorf("assert has no expectations")
+	}
+
+	if a.Threshold == nil && a.Operator != "" {
+		return fmt.Errorf("assert operator %q requires a threshold", a.Operator)
+	}
+
+	if a.Threshold != nil {
+		if _, err := compareThreshold(0, a.operator(), 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check tests a query output against the assertion.
+func (a Assertion) Check(rs ResultSet) error {
+	rowCount := len(rs.Rows)
+
+	if a.ExpectRows != nil && rowCount != *a.ExpectRows {
+		return a.failure(rs, fmt.Sprintf("expected %d rows, got %d", *a.ExpectRows, rowCount))
+	}
+
+	if a.MaxRows != nil && rowCount > *a.MaxRows {
+		return a.failure(rs, fmt.Sprintf("expected at most %d rows, got %d", *a.MaxRows, rowCount))
+	}
+
+	if a.ExpectValue == nil && a.Threshold == nil {
+		return nil
+	}
+
+	if rowCount == 0 || len(rs.Rows[0]) == 0 {
+		return a.failure(rs, "expected a value, got no rows")
+	}
+	value := rs.StringRow(rs.Rows[0])[0]
+
+	if a.ExpectValue != nil && value != *a.ExpectValue {
+		return a.failure(rs, fmt.Sprintf("expected value %q, got %q", *a.ExpectValue, value))
+	}
+
+	if a.Threshold != nil {
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return a.failure(rs, fmt.Sprintf("expected a numeric value, got %q", value))
+		}
+
+		ok, err := compareThreshold(number, a.operator(), *a.Threshold)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return a.failure(rs, fmt.Sprintf("expected value %s %s, got %s", a.operator(), formatFloat(*a.Threshold), value))
+		}
+	}
+
+	return nil
+}
+
+// operator returns the comparison used against the threshold.
+func (a Assertion) operator() string {
+	if a.Operator == "" {
+		return "<="
+	}
+	return a.Operator
+}
+
+// failure builds an AssertionError with the leading rows of rs.
+func (a Assertion) failure(rs ResultSet, message string) *AssertionError {
+	size := a.SampleSize
+	if size <= 0 {
+		size = defaultSampleSize
+	}
+	if size > len(rs.Rows) {
+		size = len(rs.Rows)
+	}
+
+	return &AssertionError{
+		Message: fmt.Sprintf("ASSERTION FAILED: %s", message),
+		Sample:  ResultSet{Columns: rs.Columns, Rows: rs.Rows[:size]},
+	}
+}
+
+// compareThreshold applies operator to value and threshold.
+func compareThreshold(value float64, operator string, threshold float64) (bool, error) {
+	switch operator {
+	case "<=":
+		return value <= threshold, nil
+	case "<":
+		return value < threshold, nil
+	case ">=":
+		return value >= threshold, nil
+	case ">":
+		return value > threshold, nil
+	case "=", "==":
+		return value == threshold, nil
+	case "!=", "<>":
+		return value != threshold, nil
+	default:
+		return false, fmt.Errorf("unsupported assert operator %q", operator)
+	}
+}
+
+// runAssertion runs a query and checks its output against
+// the query's assertion. The last result set produced by
+// the script is the one being checked.
+func runAssertion(database Db, query ReadyQuery, showQueryOutput bool) QueryStatus {
+	sets, err := database.FetchResults(query)
+	if err != nil {
+		return QueryStatus{Query: query, Path: query.Path, Error: err}
+	}
+
+	var rs ResultSet
+	if len(sets) > 0 {
+		rs = sets[len(sets)-1]
+	}
+
+	if showQueryOutput {
+		if err := printTable(rs); err != nil {
+			log.Printf("ERROR: %s.", err)
+			return QueryStatus{Query: query, Path: query.Path, Affected: len(rs.Rows), Error: err}
+		}
+	}
+
+	return QueryStatus{Query: query, Path: query.Path, Affected: len(rs.Rows), Error: query.Assert.Check(rs)}
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func TestAssertion_Validate(t *testing.T) {
+	testCases := []struct {
+		Name      string
+		Assert    Assertion
+		ErrString string
+	}{
+		{
+			Name:      "empty",
+			Assert:    Assertion{},
+			ErrString: "assert has no expectations",
+		},
+		{
+			Name:      "operator_without_threshold",
+			Assert:    Assertion{ExpectRows: intPtr(0), Operator: "<"},
+			ErrString: `assert operator "<" requires a threshold`,
+		},
+		{
+			Name:      "bad_operator",
+			Assert:    Assertion{Operator: "~", Threshold: floatPtr(1)},
+			ErrString: `unsupported assert operator "~"`,
+		},
+		{
+			Name:   "default_operator",
+			Assert: Assertion{Threshold: floatPtr(1)},
+		},
+		{
+			Name:   "expect_rows",
+			Assert: Assertion{ExpectRows: intPtr(0)},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			err := tt.Assert.Validate()
+			if tt.ErrString == "" {
+				assert.Nil(t, err)
+			} else if assert.NotNil(t, err) {
+				assert.Equal(t, tt.ErrString, err.Error())
+			}
+		})
+	}
+}
+
+func TestAssertion_Check(t *testing.T) {
+	empty := ResultSet{Columns: []Column{{Name: "id", Kind: KindInteger}}}
+	offending := ResultSet{
+		Columns: []Column{{Name: "id", Kind: KindInteger}},
+		Rows:    [][]interface{}{{int64(4)}, {int64(5)}, {int64(6)}},
+	}
+
+	testCases := []struct {
+		Name      string
+		Assert    Assertion
+		Input     ResultSet
+		ErrPrefix string
+	}{
+		{
+			Name:   "expect_rows_ok",
+			Assert: Assertion{ExpectRows: intPtr(0)},
+			Input:  empty,
+		},
+		{
+			Name:      "expect_rows_failed",
+			Assert:    Assertion{ExpectRows: intPtr(0)},
+			Input:     offending,
+			ErrPrefix: "ASSERTION FAILED: expected 0 rows, got 3",
+		},
+		{
+			Name:      "max_rows_failed",
+			Assert:    Assertion{MaxRows: intPtr(2)},
+			Input:     offending,
+			ErrPrefix: "ASSERTION FAILED: expected at most 2 rows, got 3",
+		},
+		{
+			Name:   "expect_value_ok",
+			Assert: Assertion{ExpectValue: stringPtr("4")},
+			Input:  offending,
+		},
+		{
+			Name:      "expect_value_failed",
+			Assert:    Assertion{ExpectValue: stringPtr("5")},
+			Input:     offending,
+			ErrPrefix: `ASSERTION FAILED: expected value "5", got "4"`,
+		},
+		{
+			Name:      "expect_value_no_rows",
+			Assert:    Assertion{ExpectValue: stringPtr("5")},
+			Input:     empty,
+			ErrPrefix: "ASSERTION FAILED: expected a value, got no rows",
+		},
+		{
+			Name:   "threshold_ok",
+			Assert: Assertion{Operator: ">", Threshold: floatPtr(3)},
+			Input:  offending,
+		},
+		{
+			Name:      "threshold_failed",
+			Assert:    Assertion{Threshold: floatPtr(3.5)},
+			Input:     offending,
+			ErrPrefix: "ASSERTION FAILED: expected value <= 3.5, got 4",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			err := tt.Assert.Check(tt.Input)
+			if tt.ErrPrefix == "" {
+				assert.Nil(t, err)
+			} else if assert.NotNil(t, err) {
+				assert.True(t, strings.HasPrefix(err.Error(), tt.ErrPrefix), err.Error())
+			}
+		})
+	}
+}
+
+func TestAssertionError_Sample(t *testing.T) {
+	assert := assert.New(t)
+
+	rs := ResultSet{
+		Columns: []Column{{Name: "id", Kind: KindInteger}, {Name: "name", Kind: KindString}},
+		Rows:    [][]interface{}{{int64(1), "a"}, {int64(2), nil}, {int64(3), "c"}},
+	}
+
+	err := Assertion{ExpectRows: intPtr(0), SampleSize: 2}.Check(rs)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	assertErr, ok := err.(*AssertionError)
+	if !ok {
+		t.Fatalf("expected *AssertionError, got %T", err)
+	}
+	assert.Equal(2, len(assertErr.Sample.Rows))
+
+	lines := strings.Split(err.Error(), "\n")
+	assert.Equal("ASSERTION FAILED: expected 0 rows, got 3, SAMPLE:", lines[0])
+	assert.Equal(5, len(lines)) // message, header, separator, two rows
+	for _, line := range lines[1:] {
+		assert.True(strings.HasPrefix(line, sampleIndent))
+	}
+	assert.Contains(lines[4], "NULL")
+}
@@ -21,6 +21,8 @@ func awsCredentials(creds *credentials.Credentials) (string, error) {
 	value, err := creds.Get()
 	accessKeyID := value.AccessKeyID
 	secretAccessKey := value.SecretAccessKey
+	registerSecret(secretAccessKey)
+	registerSecret(value.SessionToken)
 	return fmt.Sprintf("CREDENTIALS 'aws_access_key_id=%s;aws_secret_access_key=%s'", accessKeyID, secretAccessKey), err
 }
 
@@ -28,11 +28,15 @@ func TestAwsEnvCredentials(t *testing.T) {
 
 	os.Setenv("AWS_ACCESS_KEY_ID", "some-aws-key")
 	os.Setenv("AWS_SECRET_ACCESS_KEY", "some-aws-secret")
+	os.Setenv("AWS_SESSION_TOKEN", "some-aws-session-token")
+	defer os.Unsetenv("AWS_SESSION_TOKEN")
 
 	str, err = awsEnvCredentials()
 	assert.NotNil(str)
 	assert.Nil(err)
 	assert.Equal("CREDENTIALS 'aws_access_key_id=some-aws-key;aws_secret_access_key=some-aws-secret'", str)
+	assert.Equal("CREDENTIALS 'aws_access_key_id=some-aws-key;aws_secret_access_key=******'", redactSecrets(str))
+	assert.Equal("token=******", redactSecrets("token=some-aws-session-token"))
 }
 
 func TestAwsProfileCredentials(t *testing.T) {
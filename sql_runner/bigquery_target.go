@@ -11,21 +11,56 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"os"
+	"reflect"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	bq "cloud.google.com/go/bigquery"
-	"github.com/olekukonko/tablewriter"
+	"cloud.google.com/go/civil"
 	"golang.org/x/net/context"
+	"google.golang.org/api/impersonate"
 	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// Priorities of BigQuery jobs
+const (
+	bqPriorityBatch       = "batch"
+	bqPriorityInteractive = "interactive"
+)
+
+// On-demand price of a TiB processed, used to estimate costs
+const (
+	bqDefaultPricePerTib = 6.25
+	bqBytesPerTib        = 1 << 40
 )
 
 // BigQueryTarget represents BigQuery as a target.
 type BigQueryTarget struct {
 	Target
-	Client *bq.Client
+	Client   *bq.Client
+	estimate *bqEstimate
+}
+
+// bqEstimate totals the bytes processed by the dry-run jobs of a target.
+type bqEstimate struct {
+	mu    sync.Mutex
+	bytes int64
+}
+
+// add adds the bytes of a job, returning the total.
+func (e *bqEstimate) add(bytes int64) int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.bytes += bytes
+	return e.bytes
 }
 
 // IsConnectable tests connection to determine whether the BigQuery target is
@@ -53,19 +88,36 @@ func (bqt BigQueryTarget) IsConnectable() bool {
 	return fmt.Sprint(row) == "[1]"
 }
 
+func init() {
+	registerTarget(NewBigQueryTarget, bigqueryType)
+}
+
 // NewBigQueryTarget returns a ptr to a BigQueryTarget.
 func NewBigQueryTarget(target Target) (*BigQueryTarget, error) {
+	if err := validateBigQueryTarget(target); err != nil {
+		return nil, err
+	}
+
+	opts, err := bqClientOptions(target)
+	if err != nil {
+		return nil, err
+	}
+
+	// Jobs are run in, and billed to, the billing project
 	projectID := target.Project
+	if target.BillingProject != "" {
+		projectID = target.BillingProject
+	}
 	ctx := context.Background()
 
-	client, err := bq.NewClient(ctx, projectID)
+	client, err := bq.NewClient(ctx, projectID, opts...)
 	if err != nil {
 		return nil, err
 	}
 
 	client.Location = target.Region
 
-	return &BigQueryTarget{target, client}, nil
+	return &BigQueryTarget{target, client, &bqEstimate{}}, nil
 }
 
 // GetTarget returns the Target field of BigQueryTarget.
@@ -73,120 +125,463 @@ func (bqt BigQueryTarget) GetTarget() Target {
 	return bqt.Target
 }
 
+// Close closes the client of the BigQuery target.
+func (bqt BigQueryTarget) Close() error {
+	return bqt.Client.Close()
+}
+
 // RunQuery runs a query against the target.
 func (bqt BigQueryTarget) RunQuery(query ReadyQuery, dryRun bool, showQueryOutput bool) QueryStatus {
 	var affected int64 = 0
 	var err error = nil
-	var schema bq.Schema = nil
 	ctx := context.Background()
 
 	if dryRun {
-		if bqt.IsConnectable() {
-			log.Printf("SUCCESS: Able to connect to target database, %s.", bqt.Project)
-		} else {
-			log.Printf("ERROR: Cannot connect to target database, %s.", bqt.Project)
-		}
-		return QueryStatus{query, query.Path, 0, nil}
+		return bqt.estimateQuery(ctx, query)
 	}
 
 	script := query.Script
+	var info *JobInfo
 
 	if len(strings.TrimSpace(script)) > 0 {
-		// If showing query output, perform a dry run to get column metadata
+		q, err := bqt.query(query)
+		if err != nil {
+			return QueryStatus{Query: query, Path: query.Path, Affected: int(affected), Error: err}
+		}
+
+		job, it, err := bqt.runJob(ctx, q)
+		info = bqJobInfo(job, q)
+		if err != nil {
+			return QueryStatus{Query: query, Path: query.Path, Affected: int(affected), Error: err, Job: info}
+		}
+
 		if showQueryOutput {
-			dq := bqt.Client.Query(script)
-			dq.DryRun = true
-			dqJob, err := dq.Run(ctx)
+			rs, err := bqResultSet(it)
 			if err != nil {
-				log.Printf("ERROR: Failed to dry run job: %s.", err)
-				return QueryStatus{query, query.Path, int(affected), err}
+				log.Printf("ERROR: Failed to read job results: %s.", err)
+				return QueryStatus{Query: query, Path: query.Path, Affected: int(affected), Error: err, Job: info}
 			}
 
-			schema = dqJob.LastStatus().Statistics.Details.(*bq.QueryStatistics).Schema
+			err = printTable(rs)
+			if err != nil {
+				log.Printf("ERROR: Failed to print output: %s.", err)
+				return QueryStatus{Query: query, Path: query.Path, Affected: int(affected), Error: err, Job: info}
+			}
+		} else {
+			queryStats := job.LastStatus().Statistics.Details.(*bq.QueryStatistics)
+			aff := queryStats.NumDMLAffectedRows
+			affected += aff
 		}
+	}
 
-		q := bqt.Client.Query(script)
+	return QueryStatus{Query: query, Path: query.Path, Affected: int(affected), Error: err, Job: info}
+}
 
-		job, err := q.Run(ctx)
-		if err != nil {
-			log.Printf("ERROR: Failed to run job: %s.", err)
-			return QueryStatus{query, query.Path, int(affected), err}
+// estimateQuery runs a query as a dry-run job, which validates it and
+// reports the bytes it would process, failing once the estimated cost
+// of the target exceeds its budget.
+func (bqt BigQueryTarget) estimateQuery(ctx context.Context, query ReadyQuery) QueryStatus {
+	if len(strings.TrimSpace(query.Script)) == 0 {
+		return QueryStatus{Query: query, Path: query.Path}
+	}
+
+	q, err := bqt.query(query)
+	if err != nil {
+		return QueryStatus{Query: query, Path: query.Path, Error: err}
+	}
+	q.DryRun = true
+
+	job, err := q.Run(ctx)
+	if err != nil {
+		log.Printf("ERROR: Failed to validate query: %s.", err)
+		return QueryStatus{Query: query, Path: query.Path, Error: err}
+	}
+
+	info := bqJobInfo(job, q)
+	info.DryRun = true
+	info.EstimatedCost = bqt.cost(info.BytesProcessed)
+	log.Printf("ESTIMATE: %s would process %d bytes, costing %.2f.", query.Name, info.BytesProcessed, info.EstimatedCost)
+
+	total := bqt.cost(bqt.estimate.add(info.BytesProcessed))
+	if bqt.EstimateBudget > 0 && total > bqt.EstimateBudget {
+		err = fmt.Errorf("estimated cost %.2f of target %s exceeds its estimate_budget %.2f", total, bqt.Name, bqt.EstimateBudget)
+	}
+	return QueryStatus{Query: query, Path: query.Path, Error: err, Job: info}
+}
+
+// ValidateQuery validates a query, and estimates its cost, with a
+// dry-run job as any dry run does.
+func (bqt BigQueryTarget) ValidateQuery(query ReadyQuery) QueryStatus {
+	return bqt.estimateQuery(context.Background(), query)
+}
+
+// cost estimates the on-demand cost of processing bytes.
+func (bqt BigQueryTarget) cost(bytes int64) float64 {
+	price := bqt.PricePerTib
+	if price == 0 {
+		price = bqDefaultPricePerTib
+	}
+	return float64(bytes) / bqBytesPerTib * price
+}
+
+// FetchResults runs a query against the target and returns its output.
+func (bqt BigQueryTarget) FetchResults(query ReadyQuery) ([]ResultSet, error) {
+	q, err := bqt.query(query)
+	if err != nil {
+		return nil, err
+	}
+
+	_, it, err := bqt.runJob(context.Background(), q)
+	if err != nil {
+		return nil, err
+	}
+
+	rs, err := bqResultSet(it)
+	if err != nil {
+		return nil, err
+	}
+	return []ResultSet{rs}, nil
+}
+
+// bqImpersonateOptions are the options of the IAM Credentials client
+// used for impersonation, which tests point to a stand-in.
+var bqImpersonateOptions []option.ClientOption
+
+// bqClientOptions returns the options authenticating the client of a
+// target with its credentials, or else with application default
+// credentials, optionally impersonating a service account. Targets with
+// an endpoint and no credentials, such as emulators, are not
+// authenticated.
+func bqClientOptions(target Target) ([]option.ClientOption, error) {
+	var opts []option.ClientOption
+	switch {
+	case target.CredentialsFile != "" && target.CredentialsEnv != "":
+		return nil, fmt.Errorf("only one of credentials_file or credentials_env may be used")
+	case target.CredentialsFile != "":
+		opts = append(opts, option.WithCredentialsFile(target.CredentialsFile))
+	case target.CredentialsEnv != "":
+		credentials := os.Getenv(target.CredentialsEnv)
+		if credentials == "" {
+			return nil, fmt.Errorf("environment variable %s of credentials_env is not set", target.CredentialsEnv)
 		}
+		registerCredentialsJSON(credentials)
+		opts = append(opts, option.WithCredentialsJSON([]byte(credentials)))
+	}
 
-		it, err := job.Read(ctx)
+	if target.ImpersonateServiceAccount != "" {
+		ts, err := impersonate.CredentialsTokenSource(context.Background(), impersonate.CredentialsConfig{
+			TargetPrincipal: target.ImpersonateServiceAccount,
+			Scopes:          []string{bq.Scope},
+		}, append(opts, bqImpersonateOptions...)...)
 		if err != nil {
-			log.Printf("ERROR: Failed to read job results: %s.", err)
-			return QueryStatus{query, query.Path, int(affected), err}
+			return nil, fmt.Errorf("cannot impersonate %s: %s", target.ImpersonateServiceAccount, err)
 		}
+		opts = []option.ClientOption{option.WithTokenSource(ts)}
+	}
 
-		status, err := job.Status(ctx)
-		if err != nil {
-			log.Printf("ERROR: Failed to read job results: %s.", err)
-			return QueryStatus{query, query.Path, int(affected), err}
+	if target.Endpoint != "" {
+		if len(opts) == 0 {
+			opts = append(opts, option.WithoutAuthentication())
+		}
+		opts = append(opts, option.WithEndpoint(target.Endpoint))
+	}
+	return opts, nil
+}
+
+// registerCredentialsJSON records a credentials JSON as a secret,
+// along with the keys and tokens in it.
+func registerCredentialsJSON(credentials string) {
+	registerSecret(credentials)
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(credentials), &fields); err != nil {
+		return
+	}
+	for _, name := range []string{"private_key", "client_secret", "refresh_token"} {
+		if value, ok := fields[name].(string); ok {
+			registerSecret(value)
+		}
+	}
+}
+
+// validateBigQueryTarget checks the job configuration of a target.
+func validateBigQueryTarget(target Target) error {
+	if target.UseLegacySql != nil && *target.UseLegacySql {
+		return fmt.Errorf("use_legacy_sql is not supported, queries are run as standard sql")
+	}
+	switch strings.ToLower(target.Priority) {
+	case "", bqPriorityBatch, bqPriorityInteractive:
+	default:
+		return fmt.Errorf("unsupported priority %q (supported: %s, %s)", target.Priority, bqPriorityBatch, bqPriorityInteractive)
+	}
+	if target.MaximumBytesBilled < 0 {
+		return fmt.Errorf("maximum_bytes_billed cannot be negative")
+	}
+	if target.PricePerTib < 0 || target.EstimateBudget < 0 {
+		return fmt.Errorf("price_per_tib and estimate_budget cannot be negative")
+	}
+	if _, _, err := bqDefaultDataset(target); err != nil {
+		return err
+	}
+	if err := rejectPool(target); err != nil {
+		return err
+	}
+	_, err := bqParameters(target.QueryParameters, nil)
+	return err
+}
+
+// bqDefaultDataset splits the default dataset of a target, which is
+// in the project of the target unless given as project.dataset.
+func bqDefaultDataset(target Target) (string, string, error) {
+	if target.DefaultDataset == "" {
+		return "", "", nil
+	}
+
+	parts := strings.Split(target.DefaultDataset, ".")
+	switch {
+	case len(parts) == 1 && parts[0] != "":
+		return target.Project, parts[0], nil
+	case len(parts) == 2 && parts[0] != "" && parts[1] != "":
+		return parts[0], parts[1], nil
+	default:
+		return "", "", fmt.Errorf("invalid default_dataset %q (expected dataset or project.dataset)", target.DefaultDataset)
+	}
+}
+
+// query configures the job of a query, with the labels and query
+// parameters of the query taking precedence over those of the target.
+// Queries are always run as standard SQL.
+func (bqt BigQueryTarget) query(query ReadyQuery) (*bq.Query, error) {
+	q := bqt.Client.Query(query.Script)
+
+	if len(bqt.Labels) > 0 || len(query.Labels) > 0 {
+		q.Labels = make(map[string]string)
+		for k, v := range bqt.Labels {
+			q.Labels[k] = v
 		}
-		if err := status.Err(); err != nil {
-			log.Printf("ERROR: Error running job: %s.", err)
-			return QueryStatus{query, query.Path, int(affected), err}
+		for k, v := range query.Labels {
+			q.Labels[k] = v
 		}
+	}
 
-		if showQueryOutput {
-			err = printBqTable(it, schema)
+	project, dataset, err := bqDefaultDataset(bqt.Target)
+	if err != nil {
+		return nil, err
+	}
+	q.DefaultProjectID = project
+	q.DefaultDatasetID = dataset
+
+	switch strings.ToLower(bqt.Priority) {
+	case bqPriorityBatch:
+		q.Priority = bq.BatchPriority
+	case bqPriorityInteractive:
+		q.Priority = bq.InteractivePriority
+	}
+	q.MaxBytesBilled = bqt.MaximumBytesBilled
+
+	q.Parameters, err = bqParameters(bqt.QueryParameters, query.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// bqParameters returns the named query parameters of a target merged
+// with those of a query, sorted by name.
+func bqParameters(targetParams map[string]interface{}, queryParams map[string]interface{}) ([]bq.QueryParameter, error) {
+	merged := make(map[string]interface{})
+	for k, v := range targetParams {
+		merged[k] = v
+	}
+	for k, v := range queryParams {
+		merged[k] = v
+	}
+
+	names := make([]string, 0, len(merged))
+	for name := range merged {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	params := make([]bq.QueryParameter, 0, len(names))
+	for _, name := range names {
+		value, err := bqParameterValue(name, merged[name])
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, bq.QueryParameter{Name: name, Value: value})
+	}
+	return params, nil
+}
+
+// bqParameterValue converts a parameter decoded from YAML to a value
+// the BigQuery client can infer a type from. Lists become arrays, and
+// must hold values of a single type.
+func bqParameterValue(name string, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string, bool, int64, float64, time.Time:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return nil, fmt.Errorf("query parameter %s overflows INT64", name)
+		}
+		return int64(v), nil
+	case []interface{}:
+		if len(v) == 0 {
+			return nil, fmt.Errorf("query parameter %s is an empty list", name)
+		}
+		var array reflect.Value
+		for i, elem := range v {
+			if _, ok := elem.([]interface{}); ok {
+				return nil, fmt.Errorf("query parameter %s cannot hold nested lists", name)
+			}
+			converted, err := bqParameterValue(name, elem)
 			if err != nil {
-				log.Printf("ERROR: Failed to print output: %s.", err)
-				return QueryStatus{query, query.Path, int(affected), err}
+				return nil, err
 			}
-		} else {
-			queryStats := job.LastStatus().Statistics.Details.(*bq.QueryStatistics)
-			aff := queryStats.NumDMLAffectedRows
-			affected += aff
+			if i == 0 {
+				array = reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(converted)), len(v), len(v))
+			} else if reflect.TypeOf(converted) != array.Type().Elem() {
+				return nil, fmt.Errorf("query parameter %s mixes values of different types", name)
+			}
+			array.Index(i).Set(reflect.ValueOf(converted))
 		}
+		return array.Interface(), nil
+	default:
+		return nil, fmt.Errorf("unsupported value of query parameter %s: %v", name, value)
 	}
+}
 
-	return QueryStatus{query, query.Path, int(affected), err}
+// bqJobInfo reports the configuration and statistics of a job.
+func bqJobInfo(job *bq.Job, q *bq.Query) *JobInfo {
+	if job == nil {
+		return nil
+	}
+
+	info := &JobInfo{
+		ID:       job.ID(),
+		Location: job.Location(),
+		Priority: bqPriorityInteractive,
+		Labels:   q.Labels,
+	}
+	if q.Priority == bq.BatchPriority {
+		info.Priority = bqPriorityBatch
+	}
+	if status := job.LastStatus(); status != nil && status.Statistics != nil {
+		info.BytesProcessed = status.Statistics.TotalBytesProcessed
+		if stats, ok := status.Statistics.Details.(*bq.QueryStatistics); ok {
+			info.BytesBilled = stats.TotalBytesBilled
+			info.CacheHit = stats.CacheHit
+		}
+	}
+	return info
 }
 
-func printBqTable(rows *bq.RowIterator, schema bq.Schema) error {
-	outputBuffer := make([][]string, 0, 10)
+// runJob runs a query job and waits for it to complete. The job is
+// returned along with any error once it was created.
+func (bqt BigQueryTarget) runJob(ctx context.Context, q *bq.Query) (*bq.Job, *bq.RowIterator, error) {
+	job, err := q.Run(ctx)
+	if err != nil {
+		log.Printf("ERROR: Failed to run job: %s.", err)
+		return nil, nil, err
+	}
+
+	it, err := job.Read(ctx)
+	if err != nil {
+		log.Printf("ERROR: Failed to read job results: %s.", err)
+		return job, nil, err
+	}
+
+	status, err := job.Status(ctx)
+	if err != nil {
+		log.Printf("ERROR: Failed to read job results: %s.", err)
+		return job, nil, err
+	}
+	if err := status.Err(); err != nil {
+		log.Printf("ERROR: Error running job: %s.", err)
+		return job, nil, err
+	}
+
+	return job, it, nil
+}
 
+// bqResultSet reads all rows from a job into a ResultSet.
+// The iterator only knows its schema once the first page is fetched.
+func bqResultSet(it *bq.RowIterator) (ResultSet, error) {
+	rows := make([][]bq.Value, 0)
 	for {
 		var row []bq.Value
-		err := rows.Next(&row)
+		err := it.Next(&row)
 		if err == iterator.Done {
 			break
 		}
 		if err != nil {
-			return err
+			return ResultSet{}, err
 		}
-		outputBuffer = append(outputBuffer, bqStringify(row))
+		rows = append(rows, row)
 	}
 
-	if len(outputBuffer) > 0 {
-		log.Printf("QUERY OUTPUT:\n")
-		table := tablewriter.NewWriter(os.Stdout)
-
-		// Get columns from table schema
-		columns := make([]string, len(schema))
-		for i, field := range schema {
-			columns[i] = field.Name
+	columns := make([]Column, len(it.Schema))
+	for i, field := range it.Schema {
+		columns[i] = Column{
+			Name:         field.Name,
+			DatabaseType: string(field.Type),
+			Kind:         bqColumnKind(field.Type),
 		}
-		table.SetHeader(columns)
-
-		table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
-		table.SetCenterSeparator("|")
+	}
 
-		for _, row := range outputBuffer {
-			table.Append(row)
+	rs := ResultSet{Columns: columns, Rows: make([][]interface{}, 0, len(rows))}
+	for _, row := range rows {
+		line := make([]interface{}, len(row))
+		for i, value := range row {
+			kind := KindString
+			if i < len(columns) {
+				kind = columns[i].Kind
+			}
+			line[i] = bqNormalizeValue(value, kind)
 		}
+		rs.Rows = append(rs.Rows, line)
+	}
+	return rs, nil
+}
 
-		table.Render() // Send output
+// bqColumnKind maps a BigQuery field type to a ColumnKind.
+func bqColumnKind(fieldType bq.FieldType) ColumnKind {
+	switch fieldType {
+	case bq.IntegerFieldType:
+		return KindInteger
+	case bq.FloatFieldType:
+		return KindFloat
+	case bq.NumericFieldType, bq.BigNumericFieldType:
+		return KindDecimal
+	case bq.BooleanFieldType:
+		return KindBoolean
+	case bq.TimestampFieldType, bq.DateTimeFieldType:
+		return KindTimestamp
+	case bq.DateFieldType:
+		return KindDate
+	case bq.TimeFieldType:
+		return KindTime
+	case bq.BytesFieldType:
+		return KindBytes
+	default:
+		return KindString
 	}
-	return nil
 }
 
-func bqStringify(row []bq.Value) []string {
-	var line []string
-	for _, element := range row {
-		line = append(line, fmt.Sprint(element))
+// bqNormalizeValue converts the civil types used by the
+// BigQuery client before the shared normalization.
+func bqNormalizeValue(value bq.Value, kind ColumnKind) interface{} {
+	switch v := value.(type) {
+	case civil.Date:
+		return v.In(time.UTC)
+	case civil.DateTime:
+		return v.In(time.UTC)
+	case civil.Time:
+		return v.String()
+	default:
+		return normalizeValue(v, kind)
 	}
-	return line
 }
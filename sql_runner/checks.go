@@ -0,0 +1,375 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Supported check rules
+const (
+	ruleNotNull        = "not_null"
+	ruleUnique         = "unique"
+	ruleAcceptedValues = "accepted_values"
+	ruleRowCount       = "row_count"
+	ruleFreshness      = "freshness"
+	ruleRelationships  = "relationships"
+)
+
+// Supported check severities
+const (
+	severityError = "error"
+	severityWarn  = "warn"
+)
+
+// Check represents a data quality check declared in a playbook step.
+type Check struct {
+	Name, Table, Column, Rule, Severity, Where string
+	Values                                     []string
+	Min, Max                                   *int
+	MaxAge                                     string `yaml:"max_age"`
+	References                                 *CheckReference
+}
+
+// CheckReference is the parent column of a relationships check.
+type CheckReference struct {
+	Table, Column string
+}
+
+// Validate checks that the rule has everything it needs.
+func (c Check) Validate() error {
+	if c.Table == "" {
+		return fmt.Errorf("check has no table")
+	}
+
+	switch c.Severity {
+	case "", severityError, severityWarn:
+	default:
+		return fmt.Errorf("unsupported check severity %q", c.Severity)
+	}
+
+	switch c.Rule {
+	case ruleNotNull, ruleUnique:
+	case ruleAcceptedValues:
+		if len(c.Values) == 0 {
+			return fmt.Errorf("%s check requires values", c.Rule)
+		}
+	case ruleRowCount:
+		if c.Min == nil && c.Max == nil {
+			return fmt.Errorf("%s check requires min or max", c.Rule)
+		}
+		return nil
+	case ruleFreshness:
+		if _, err := time.ParseDuration(c.MaxAge); err != nil {
+			return fmt.Errorf("%s check requires a valid max_age: %s", c.Rule, err)
+		}
+	case ruleRelationships:
+		if c.References == nil || c.References.Table == "" || c.References.Column == "" {
+			return fmt.Errorf("%s check requires references table and column", c.Rule)
+		}
+	default:
+		return fmt.Errorf("unsupported check rule %q", c.Rule)
+	}
+
+	if c.Column == "" {
+		return fmt.Errorf("%s check requires a column", c.Rule)
+	}
+	return nil
+}
+
+// DisplayName returns the check name, or one derived from its rule.
+func (c Check) DisplayName() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	if c.Column == "" {
+		return fmt.Sprintf("%s(%s)", c.Rule, c.Table)
+	}
+	return fmt.Sprintf("%s(%s.%s)", c.Rule, c.Table, c.Column)
+}
+
+// isWarning returns whether failures of the check only warrant a warning.
+func (c Check) isWarning() bool {
+	return c.Severity == severityWarn
+}
+
+// checkDialect holds the SQL fragments which differ between targets.
+type checkDialect struct {
+	castToString func(expr string) string
+	quoteString  func(s string) string
+	ageSeconds   func(expr string) string
+}
+
+var (
+	ansiQuoteString = func(s string) string {
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	}
+
+	backslashQuoteString = func(s string) string {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+		return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+	}
+
+	checkDialects = map[string]checkDialect{
+		postgresType: {
+			castToString: func(expr string) string { return fmt.Sprintf("CAST(%s AS TEXT)", expr) },
+			quoteString:  ansiQuoteString,
+			ageSeconds: func(expr string) string {
+				return fmt.Sprintf("EXTRACT(EPOCH FROM (CURRENT_TIMESTAMP - MAX(%s)))", expr)
+			},
+		},
+		redshiftType: {
+			castToString: func(expr string) string { return fmt.Sprintf("CAST(%s AS VARCHAR)", expr) },
+			quoteString:  ansiQuoteString,
+			ageSeconds: func(expr string) string {
+				return fmt.Sprintf("DATEDIFF(second, MAX(%s), GETDATE())", expr)
+			},
+		},
+		snowflakeType: {
+			castToString: func(expr string) string { return fmt.Sprintf("TO_VARCHAR(%s)", expr) },
+			quoteString:  ansiQuoteString,
+			ageSeconds: func(expr string) string {
+				return fmt.Sprintf("DATEDIFF('second', MAX(%s), CURRENT_TIMESTAMP())", expr)
+			},
+		},
+		mysqlType: {
+			castToString: func(expr string) string { return fmt.Sprintf("CAST(%s AS CHAR)", expr) },
+			quoteString:  backslashQuoteString,
+			ageSeconds: func(expr string) string {
+				return fmt.Sprintf("TIMESTAMPDIFF(SECOND, MAX(%s), NOW())", expr)
+			},
+		},
+		sqliteType: {
+			castToString: func(expr string) string { return fmt.Sprintf("CAST(%s AS TEXT)", expr) },
+			quoteString:  ansiQuoteString,
+			ageSeconds: func(expr string) string {
+				return fmt.Sprintf("(julianday('now') - julianday(MAX(%s))) * 86400", expr)
+			},
+		},
+		duckdbType: {
+			castToString: func(expr string) string { return fmt.Sprintf("CAST(%s AS VARCHAR)", expr) },
+			quoteString:  ansiQuoteString,
+			ageSeconds: func(expr string) string {
+				return fmt.Sprintf("date_diff('second', CAST(MAX(%s) AS TIMESTAMP), CAST(now() AS TIMESTAMP))", expr)
+			},
+		},
+		clickhouseType: {
+			castToString: func(expr string) string { return fmt.Sprintf("toString(%s)", expr) },
+			quoteString:  backslashQuoteString,
+			ageSeconds: func(expr string) string {
+				return fmt.Sprintf("dateDiff('second', MAX(%s), now())", expr)
+			},
+		},
+		mssqlType: {
+			castToString: func(expr string) string { return fmt.Sprintf("CAST(%s AS NVARCHAR(MAX))", expr) },
+			quoteString:  ansiQuoteString,
+			ageSeconds: func(expr string) string {
+				return fmt.Sprintf("DATEDIFF(second, MAX(%s), GETDATE())", expr)
+			},
+		},
+		trinoType: {
+			castToString: func(expr string) string { return fmt.Sprintf("CAST(%s AS VARCHAR)", expr) },
+			quoteString:  ansiQuoteString,
+			ageSeconds: func(expr string) string {
+				return fmt.Sprintf("to_unixtime(current_timestamp) - to_unixtime(MAX(%s))", expr)
+			},
+		},
+		bigqueryType: {
+			castToString: func(expr string) string { return fmt.Sprintf("CAST(%s AS STRING)", expr) },
+			quoteString:  backslashQuoteString,
+			ageSeconds: func(expr string) string {
+				return fmt.Sprintf("TIMESTAMP_DIFF(CURRENT_TIMESTAMP(), TIMESTAMP(MAX(%s)), SECOND)", expr)
+			},
+		},
+	}
+)
+
+// dialectForType returns the check dialect of a target type.
+func dialectForType(targetType string) (checkDialect, error) {
+	targetType = strings.ToLower(targetType)
+	if targetType == postgresqlType {
+		targetType = postgresType
+	}
+
+	dialect, ok := checkDialects[targetType]
+	if !ok {
+		return checkDialect{}, fmt.Errorf("checks are not supported for target type %s", targetType)
+	}
+	return dialect, nil
+}
+
+// checkSQL generates the query for a check. The query returns a
+// single value: the number of offending rows, or for row_count
+// and freshness checks, the row count and age in seconds.
+func checkSQL(c Check, targetType string) (string, error) {
+	dialect, err := dialectForType(targetType)
+	if err != nil {
+		return "", err
+	}
+
+	where := func(cond string) string {
+		if c.Where != "" {
+			cond = fmt.Sprintf("(%s) AND %s", c.Where, cond)
+		}
+		return " WHERE " + cond
+	}
+
+	switch c.Rule {
+	case ruleNotNull:
+		return fmt.Sprintf("SELECT COUNT(*) AS failures FROM %s%s",
+			c.Table, where(fmt.Sprintf("%s IS NULL", c.Column))), nil
+	case ruleUnique:
+		return fmt.Sprintf("SELECT COUNT(*) AS failures FROM (SELECT %s FROM %s%s GROUP BY %s HAVING COUNT(*) > 1) AS duplicates",
+			c.Column, c.Table, where(fmt.Sprintf("%s IS NOT NULL", c.Column)), c.Column), nil
+	case ruleAcceptedValues:
+		values := make([]string, len(c.Values))
+		for i, v := range c.Values {
+			values[i] = dialect.quoteString(v)
+		}
+		return fmt.Sprintf("SELECT COUNT(*) AS failures FROM %s%s",
+			c.Table, where(fmt.Sprintf("%s IS NOT NULL AND %s NOT IN (%s)",
+				c.Column, dialect.castToString(c.Column), strings.Join(values, ", ")))), nil
+	case ruleRowCount:
+		query := fmt.Sprintf("SELECT COUNT(*) AS row_count FROM %s", c.Table)
+		if c.Where != "" {
+			query += fmt.Sprintf(" WHERE %s", c.Where)
+		}
+		return query, nil
+	case ruleFreshness:
+		query := fmt.Sprintf("SELECT %s AS age_seconds FROM %s", dialect.ageSeconds(c.Column), c.Table)
+		if c.Where != "" {
+			query += fmt.Sprintf(" WHERE %s", c.Where)
+		}
+		return query, nil
+	case ruleRelationships:
+		child := c.Table
+		if c.Where != "" {
+			child = fmt.Sprintf("(SELECT * FROM %s WHERE %s)", c.Table, c.Where)
+		}
+		return fmt.Sprintf("SELECT COUNT(*) AS failures FROM %s AS child LEFT JOIN %s AS parent ON child.%s = parent.%s WHERE child.%s IS NOT NULL AND parent.%s IS NULL",
+			child, c.References.Table, c.Column, c.References.Column, c.Column, c.References.Column), nil
+	default:
+		return "", fmt.Errorf("unsupported check rule %q", c.Rule)
+	}
+}
+
+// evaluateCheck interprets the output of a check query.
+func evaluateCheck(c Check, rs ResultSet) error {
+	if len(rs.Rows) == 0 || len(rs.Rows[0]) == 0 {
+		return fmt.Errorf("CHECK FAILED: %s: no result returned", c.DisplayName())
+	}
+
+	value := rs.Rows[0][0]
+	if value == nil && c.Rule == ruleFreshness {
+		return fmt.Errorf("CHECK FAILED: %s: no rows found", c.DisplayName())
+	}
+
+	number, ok := toFloat(value)
+	if !ok {
+		return fmt.Errorf("CHECK FAILED: %s: unexpected result %s", c.DisplayName(), rs.StringRow(rs.Rows[0])[0])
+	}
+
+	switch c.Rule {
+	case ruleRowCount:
+		if c.Min != nil && number < float64(*c.Min) {
+			return fmt.Errorf("CHECK FAILED: %s: expected at least %d rows, got %s", c.DisplayName(), *c.Min, formatFloat(number))
+		}
+		if c.Max != nil && number > float64(*c.Max) {
+			return fmt.Errorf("CHECK FAILED: %s: expected at most %d rows, got %s", c.DisplayName(), *c.Max, formatFloat(number))
+		}
+	case ruleFreshness:
+		maxAge, _ := time.ParseDuration(c.MaxAge) // checked in Validate
+		age := time.Duration(number) * time.Second
+		if age > maxAge {
+			return fmt.Errorf("CHECK FAILED: %s: latest value is %s old, expected at most %s", c.DisplayName(), age, maxAge)
+		}
+	default:
+		if number > 0 {
+			return fmt.Errorf("CHECK FAILED: %s: %s offending rows", c.DisplayName(), formatFloat(number))
+		}
+	}
+	return nil
+}
+
+// checkStatus is the outcome of one check on a target.
+type checkStatus struct {
+	check  Check
+	status QueryStatus
+}
+
+// Handles running the checks of a step in parallel.
+//
+// Failed checks with warn severity are reported
+// as warnings, which do not fail the step.
+func runChecks(database Db, progress Progress, stepName string, checks []Check, dryRun bool) ([]QueryStatus, []QueryStatus) {
+	target := database.GetTarget()
+	checkChan := make(chan checkStatus, len(checks))
+
+	for _, check := range checks {
+		go func(c Check) {
+			progress.QueryStarted(target.Name, c.DisplayName())
+			checkChan <- runCheck(database, c, dryRun)
+		}(check)
+	}
+
+	statuses := make([]QueryStatus, 0, len(checks))
+	warnings := make([]QueryStatus, 0)
+	for i := 0; i < len(checks); i++ {
+		result := <-checkChan
+		status := result.status
+		switch {
+		case status.Error == nil:
+			log.Printf("SUCCESS: %s (step %s @ target %s)\n", status.Query.Name, stepName, target.Name)
+			progress.QueryFinished(target.Name, status)
+			statuses = append(statuses, status)
+		case result.check.isWarning():
+			log.Printf("WARNING: %s (step %s @ target %s), WARNING: %s\n", status.Query.Name, stepName, target.Name, status.Error.Error())
+			// Warnings do not fail the step
+			progress.QueryFinished(target.Name, QueryStatus{Query: status.Query, Path: status.Path})
+			warnings = append(warnings, status)
+		default:
+			log.Printf("FAILURE: %s (step %s @ target %s), ERROR: %s\n", status.Query.Name, stepName, target.Name, status.Error.Error())
+			progress.QueryFinished(target.Name, status)
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses, warnings
+}
+
+// runCheck generates, runs and evaluates a single check.
+func runCheck(database Db, c Check, dryRun bool) checkStatus {
+	query := ReadyQuery{Name: c.DisplayName(), Path: fmt.Sprintf("check:%s", c.Rule)}
+
+	script, err := checkSQL(c, database.GetTarget().Type)
+	if err != nil {
+		return checkStatus{c, QueryStatus{Query: query, Path: query.Path, Error: err}}
+	}
+	query.Script = script
+
+	log.Printf("EXECUTING CHECK %s (@ %s): %s", query.Name, database.GetTarget().Name, script)
+	if dryRun {
+		return checkStatus{c, database.RunQuery(query, dryRun, false)}
+	}
+
+	sets, err := database.FetchResults(query)
+	if err != nil {
+		return checkStatus{c, QueryStatus{Query: query, Path: query.Path, Error: err}}
+	}
+
+	var rs ResultSet
+	if len(sets) > 0 {
+		rs = sets[len(sets)-1]
+	}
+	return checkStatus{c, QueryStatus{Query: query, Path: query.Path, Error: evaluateCheck(c, rs)}}
+}
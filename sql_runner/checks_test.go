@@ -0,0 +1,292 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck_Validate(t *testing.T) {
+	testCases := []struct {
+		Name      string
+		Check     Check
+		ErrString string
+	}{
+		{
+			Name:      "missing_table",
+			Check:     Check{Rule: ruleNotNull, Column: "id"},
+			ErrString: "check has no table",
+		},
+		{
+			Name:      "unknown_rule",
+			Check:     Check{Table: "t", Column: "id", Rule: "positive"},
+			ErrString: `unsupported check rule "positive"`,
+		},
+		{
+			Name:      "unknown_severity",
+			Check:     Check{Table: "t", Column: "id", Rule: ruleNotNull, Severity: "info"},
+			ErrString: `unsupported check severity "info"`,
+		},
+		{
+			Name:      "missing_column",
+			Check:     Check{Table: "t", Rule: ruleUnique},
+			ErrString: "unique check requires a column",
+		},
+		{
+			Name:      "accepted_values_without_values",
+			Check:     Check{Table: "t", Column: "c", Rule: ruleAcceptedValues},
+			ErrString: "accepted_values check requires values",
+		},
+		{
+			Name:      "row_count_without_bounds",
+			Check:     Check{Table: "t", Rule: ruleRowCount},
+			ErrString: "row_count check requires min or max",
+		},
+		{
+			Name:      "freshness_bad_age",
+			Check:     Check{Table: "t", Column: "c", Rule: ruleFreshness, MaxAge: "1 day"},
+			ErrString: `freshness check requires a valid max_age: time: unknown unit " day" in duration "1 day"`,
+		},
+		{
+			Name:      "relationships_without_references",
+			Check:     Check{Table: "t", Column: "c", Rule: ruleRelationships},
+			ErrString: "relationships check requires references table and column",
+		},
+		{
+			Name:  "row_count",
+			Check: Check{Table: "t", Rule: ruleRowCount, Min: intPtr(1)},
+		},
+		{
+			Name:  "not_null_warn",
+			Check: Check{Table: "t", Column: "c", Rule: ruleNotNull, Severity: severityWarn},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			err := tt.Check.Validate()
+			if tt.ErrString == "" {
+				assert.Nil(t, err)
+			} else if assert.NotNil(t, err) {
+				assert.Equal(t, tt.ErrString, err.Error())
+			}
+		})
+	}
+}
+
+func TestCheckSQL(t *testing.T) {
+	testCases := []struct {
+		Name       string
+		Check      Check
+		TargetType string
+		Expected   string
+	}{
+		{
+			Name:       "not_null",
+			Check:      Check{Table: "atomic.events", Column: "user_id", Rule: ruleNotNull},
+			TargetType: postgresType,
+			Expected:   "SELECT COUNT(*) AS failures FROM atomic.events WHERE user_id IS NULL",
+		},
+		{
+			Name:       "not_null_where",
+			Check:      Check{Table: "atomic.events", Column: "user_id", Rule: ruleNotNull, Where: "platform = 'web'"},
+			TargetType: redshiftType,
+			Expected:   "SELECT COUNT(*) AS failures FROM atomic.events WHERE (platform = 'web') AND user_id IS NULL",
+		},
+		{
+			Name:       "unique",
+			Check:      Check{Table: "t", Column: "id", Rule: ruleUnique},
+			TargetType: snowflakeType,
+			Expected:   "SELECT COUNT(*) AS failures FROM (SELECT id FROM t WHERE id IS NOT NULL GROUP BY id HAVING COUNT(*) > 1) AS duplicates",
+		},
+		{
+			Name:       "accepted_values_postgres",
+			Check:      Check{Table: "t", Column: "c", Rule: ruleAcceptedValues, Values: []string{"a", "o'b"}},
+			TargetType: postgresqlType,
+			Expected:   "SELECT COUNT(*) AS failures FROM t WHERE c IS NOT NULL AND CAST(c AS TEXT) NOT IN ('a', 'o''b')",
+		},
+		{
+			Name:       "accepted_values_bigquery",
+			Check:      Check{Table: "t", Column: "c", Rule: ruleAcceptedValues, Values: []string{"a", "o'b"}},
+			TargetType: bigqueryType,
+			Expected:   `SELECT COUNT(*) AS failures FROM t WHERE c IS NOT NULL AND CAST(c AS STRING) NOT IN ('a', 'o\'b')`,
+		},
+		{
+			Name:       "freshness_mysql",
+			Check:      Check{Table: "t", Column: "ts", Rule: ruleFreshness, MaxAge: "1h"},
+			TargetType: mysqlType,
+			Expected:   "SELECT TIMESTAMPDIFF(SECOND, MAX(ts), NOW()) AS age_seconds FROM t",
+		},
+		{
+			Name:       "row_count",
+			Check:      Check{Table: "t", Rule: ruleRowCount, Max: intPtr(10)},
+			TargetType: snowflakeType,
+			Expected:   "SELECT COUNT(*) AS row_count FROM t",
+		},
+		{
+			Name:       "freshness_redshift",
+			Check:      Check{Table: "t", Column: "ts", Rule: ruleFreshness, MaxAge: "1h"},
+			TargetType: redshiftType,
+			Expected:   "SELECT DATEDIFF(second, MAX(ts), GETDATE()) AS age_seconds FROM t",
+		},
+		{
+			Name:       "freshness_bigquery",
+			Check:      Check{Table: "t", Column: "ts", Rule: ruleFreshness, MaxAge: "1h"},
+			TargetType: bigqueryType,
+			Expected:   "SELECT TIMESTAMP_DIFF(CURRENT_TIMESTAMP(), TIMESTAMP(MAX(ts)), SECOND) AS age_seconds FROM t",
+		},
+		{
+			Name:       "relationships",
+			Check:      Check{Table: "orders", Column: "user_id", Rule: ruleRelationships, References: &CheckReference{Table: "users", Column: "id"}},
+			TargetType: postgresType,
+			Expected:   "SELECT COUNT(*) AS failures FROM orders AS child LEFT JOIN users AS parent ON child.user_id = parent.id WHERE child.user_id IS NOT NULL AND parent.id IS NULL",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			result, err := checkSQL(tt.Check, tt.TargetType)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.Expected, result)
+		})
+	}
+}
+
+func TestCheckSQL_UnsupportedTarget(t *testing.T) {
+	_, err := checkSQL(Check{Table: "t", Column: "c", Rule: ruleNotNull}, "oracle")
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "checks are not supported for target type oracle", err.Error())
+	}
+}
+
+func TestEvaluateCheck(t *testing.T) {
+	single := func(value interface{}) ResultSet {
+		return ResultSet{
+			Columns: []Column{{Name: "v", Kind: KindInteger}},
+			Rows:    [][]interface{}{{value}},
+		}
+	}
+
+	testCases := []struct {
+		Name      string
+		Check     Check
+		Input     ResultSet
+		ErrString string
+	}{
+		{
+			Name:  "no_failures",
+			Check: Check{Table: "t", Column: "c", Rule: ruleNotNull},
+			Input: single(int64(0)),
+		},
+		{
+			Name:      "failures",
+			Check:     Check{Table: "t", Column: "c", Rule: ruleNotNull},
+			Input:     single(int64(3)),
+			ErrString: "CHECK FAILED: not_null(t.c): 3 offending rows",
+		},
+		{
+			Name:      "row_count_below_min",
+			Check:     Check{Name: "enough", Table: "t", Rule: ruleRowCount, Min: intPtr(5)},
+			Input:     single(int64(3)),
+			ErrString: "CHECK FAILED: enough: expected at least 5 rows, got 3",
+		},
+		{
+			Name:  "row_count_in_range",
+			Check: Check{Table: "t", Rule: ruleRowCount, Min: intPtr(1), Max: intPtr(5)},
+			Input: single(int64(3)),
+		},
+		{
+			Name:      "stale",
+			Check:     Check{Table: "t", Column: "ts", Rule: ruleFreshness, MaxAge: "1h"},
+			Input:     single("7200.5"),
+			ErrString: "CHECK FAILED: freshness(t.ts): latest value is 2h0m0s old, expected at most 1h0m0s",
+		},
+		{
+			Name:      "empty_table_freshness",
+			Check:     Check{Table: "t", Column: "ts", Rule: ruleFreshness, MaxAge: "1h"},
+			Input:     single(nil),
+			ErrString: "CHECK FAILED: freshness(t.ts): no rows found",
+		},
+		{
+			Name:      "no_result",
+			Check:     Check{Table: "t", Column: "c", Rule: ruleUnique},
+			Input:     ResultSet{},
+			ErrString: "CHECK FAILED: unique(t.c): no result returned",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			err := evaluateCheck(tt.Check, tt.Input)
+			if tt.ErrString == "" {
+				assert.Nil(t, err)
+			} else if assert.NotNil(t, err) {
+				assert.Equal(t, tt.ErrString, err.Error())
+			}
+		})
+	}
+}
+
+func TestReview_Warnings(t *testing.T) {
+	assert := assert.New(t)
+
+	statuses := []TargetStatus{
+		{
+			Name: "db",
+			Steps: []StepStatus{
+				{
+					Name:    "checks",
+					Queries: []QueryStatus{{Query: ReadyQuery{Name: "ok"}}},
+					Warnings: []QueryStatus{
+						{Query: ReadyQuery{Name: "not_null(t.c)"}, Error: errors.New("CHECK FAILED: not_null(t.c): 3 offending rows")},
+					},
+				},
+			},
+		},
+	}
+
+	code, message := review(statuses)
+	assert.Equal(0, code)
+	assert.True(strings.HasPrefix(message, "SUCCESS: 1 queries executed against 1 targets"))
+	assert.Contains(message, "CHECK WARNINGS:\n* Check not_null(t.c) (in step checks @ target db), WARNING:\n  - CHECK FAILED: not_null(t.c): 3 offending rows")
+}
+
+func TestRunChecks_Progress(t *testing.T) {
+	assert := assert.New(t)
+
+	lp := newLiveProgress(io.Discard, io.Discard, func() int { return 0 })
+	defer lp.Stop()
+
+	database := fakeDb{
+		target: Target{Name: "db", Type: postgresType},
+		rs:     ResultSet{Columns: []Column{{Name: "v", Kind: KindInteger}}, Rows: [][]interface{}{{int64(3)}}},
+	}
+	checks := []Check{
+		{Table: "t", Column: "a", Rule: ruleNotNull, Severity: severityWarn},
+		{Table: "t", Column: "b", Rule: ruleNotNull},
+	}
+	statuses, warnings := runChecks(database, lp, "checks", checks, false)
+	assert.Equal(1, len(statuses))
+	assert.Equal(1, len(warnings))
+
+	// Warnings count as completed
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	tp := lp.target("db")
+	assert.Equal(1, tp.completed)
+	assert.Equal(1, tp.failed)
+	assert.Empty(tp.running)
+}
@@ -0,0 +1,227 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"net"
+	"reflect"
+	"strings"
+	"sync/atomic"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	clickhouseNative = "native"
+	clickhouseHTTP   = "http"
+)
+
+// ClickHouseTarget represents ClickHouse as target.
+type ClickHouseTarget struct {
+	Target
+	Client *sql.DB
+	tunnel *sshTunnel
+}
+
+// IsConnectable tests connection to determine whether the ClickHouse target is
+// connectable.
+func (ct ClickHouseTarget) IsConnectable() bool {
+	return ct.Client.Ping() == nil
+}
+
+func init() {
+	registerTarget(NewClickHouseTarget, clickhouseType)
+}
+
+// NewClickHouseTarget returns a ptr to a ClickHouseTarget.
+func NewClickHouseTarget(target Target) (*ClickHouseTarget, error) {
+	if target.Host == "" || target.Port == "" {
+		return nil, fmt.Errorf("missing target connection parameters")
+	}
+
+	protocol, err := clickhouseProtocol(target.Protocol)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := tlsConfigFromTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	dial, tunnel, err := dialerFromTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &clickhouse.Options{
+		Protocol: protocol,
+		Addr:     []string{net.JoinHostPort(target.Host, target.Port)},
+		Auth: clickhouse.Auth{
+			Database: target.Database,
+			Username: target.Username,
+			Password: target.Password,
+		},
+		TLS:         tlsConfig,
+		DialTimeout: dialTimeout,
+		ReadTimeout: readTimeout,
+	}
+	if tunnel != nil {
+		// Used by both the native and http protocols
+		options.DialContext = func(ctx context.Context, addr string) (net.Conn, error) {
+			return dial(ctx, "tcp", addr)
+		}
+	}
+
+	db := clickhouse.OpenDB(options)
+	if err := configurePool(db, target); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &ClickHouseTarget{target, db, tunnel}, nil
+}
+
+// clickhouseProtocol maps the protocol of a target, defaulting
+// to the native one.
+func clickhouseProtocol(protocol string) (clickhouse.Protocol, error) {
+	switch strings.ToLower(protocol) {
+	case "", clickhouseNative:
+		return clickhouse.Native, nil
+	case clickhouseHTTP:
+		return clickhouse.HTTP, nil
+	default:
+		return clickhouse.Native, fmt.Errorf("unsupported clickhouse protocol %q", protocol)
+	}
+}
+
+// GetTarget returns the Target field of ClickHouseTarget.
+func (ct ClickHouseTarget) GetTarget() Target {
+	return ct.Target
+}
+
+// Close closes the client of the ClickHouse target and its SSH tunnel.
+func (ct ClickHouseTarget) Close() error {
+	return closeTunneled(ct.Client, ct.tunnel)
+}
+
+// CheckHealth replaces dead pooled connections of the ClickHouse target.
+func (ct ClickHouseTarget) CheckHealth() error {
+	return checkSQLHealth(ct.Client, ct.Target)
+}
+
+// RunQuery runs a query against the target.
+func (ct ClickHouseTarget) RunQuery(query ReadyQuery, dryRun bool, showQueryOutput bool) QueryStatus {
+	return runScript(query, dryRun, showQueryOutput, ct.FetchResults, func() (int, error) {
+		return ct.exec(query.Script)
+	})
+}
+
+// FetchResults runs a query and returns its result sets.
+func (ct ClickHouseTarget) FetchResults(query ReadyQuery) ([]ResultSet, error) {
+	return ct.queryResults(query.Script)
+}
+
+// session pins a connection for a script, so that its settings apply
+// to all of its statements. Over the http protocol, which keeps no
+// state on connections, the statements share a server session instead.
+func (ct ClickHouseTarget) session(ctx context.Context) (context.Context, *sql.Conn, error) {
+	conn, err := ct.Client.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if strings.EqualFold(ct.Protocol, clickhouseHTTP) {
+		ctx = clickhouse.Context(ctx, clickhouse.WithSettings(clickhouse.Settings{
+			"session_id": uuid.NewString(),
+		}))
+	}
+	return ctx, conn, nil
+}
+
+// exec runs each statement of a script, returning the rows written
+// summed over all of them. ClickHouse has no rows affected count,
+// so written rows are taken from the progress the server reports,
+// which only the native protocol delivers: over http they are 0.
+func (ct ClickHouseTarget) exec(script string) (int, error) {
+	var written atomic.Uint64
+	ctx, conn, err := ct.session(clickhouse.Context(context.Background(), clickhouse.WithProgress(func(p *clickhouse.Progress) {
+		written.Add(p.WroteRows)
+	})))
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	for _, statement := range splitStatements(script, clickhouseDialect) {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return int(written.Load()), err
+		}
+	}
+	return int(written.Load()), nil
+}
+
+// queryResults runs each statement of a script, returning the
+// rows of those which produced a result set.
+func (ct ClickHouseTarget) queryResults(script string) ([]ResultSet, error) {
+	ctx, conn, err := ct.session(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	sets := make([]ResultSet, 0)
+	for _, statement := range splitStatements(script, clickhouseDialect) {
+		rows, err := conn.QueryContext(ctx, statement)
+		if err != nil {
+			return nil, err
+		}
+
+		results, err := resultSetsFromRows(rows, clickhouseValue)
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, results...)
+	}
+	return sets, nil
+}
+
+// clickhouseValue dereferences the values of nullable columns and
+// converts the ClickHouse specific types.
+func clickhouseValue(value interface{}, column Column) interface{} {
+	if _, ok := value.(*big.Int); ok {
+		// Used for 128 and 256 bit integers
+		return value
+	}
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		value = v.Elem().Interface()
+	}
+
+	switch v := value.(type) {
+	case decimal.Decimal:
+		return v.String()
+	case fmt.Stringer:
+		// UUIDs, IPs and enums
+		if column.Kind == KindString {
+			return v.String()
+		}
+	}
+	return value
+}
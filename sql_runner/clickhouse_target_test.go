@@ -0,0 +1,112 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"math/big"
+	"net"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewClickHouseTarget_Error(t *testing.T) {
+	testCases := []struct {
+		Name      string
+		Input     Target
+		ErrString string
+	}{
+		{
+			Name: "missing_port",
+			Input: Target{
+				Host:     "localhost",
+				Database: "default",
+			},
+			ErrString: "missing target connection parameters",
+		},
+		{
+			Name: "unsupported_protocol",
+			Input: Target{
+				Host:     "localhost",
+				Port:     "9000",
+				Protocol: "grpc",
+			},
+			ErrString: `unsupported clickhouse protocol "grpc"`,
+		},
+		{
+			Name: "missing_client_key",
+			Input: Target{
+				Host:    "localhost",
+				Port:    "9440",
+				SslCert: "client.pem",
+			},
+			ErrString: "ssl_cert and ssl_key must be set together",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			result, err := NewClickHouseTarget(tt.Input)
+			assert.Nil(result)
+			if err == nil {
+				t.Fatalf("expected error, got nil")
+			}
+
+			assert.Equal(tt.ErrString, err.Error())
+		})
+	}
+}
+
+func TestNewClickHouseTarget(t *testing.T) {
+	for _, protocol := range []string{"", "native", "HTTP"} {
+		t.Run(protocol, func(t *testing.T) {
+			assert := assert.New(t)
+
+			input := Target{
+				Name:     "clickhouse",
+				Type:     clickhouseType,
+				Host:     "localhost",
+				Port:     "9000",
+				Username: "default",
+				Database: "default",
+				Protocol: protocol,
+			}
+
+			result, err := NewClickHouseTarget(input)
+			assert.Nil(err)
+			if result == nil {
+				t.Fatal("unexpected nil result")
+			}
+
+			assert.Equal(input, result.GetTarget())
+		})
+	}
+}
+
+func TestClickHouseValue(t *testing.T) {
+	assert := assert.New(t)
+
+	name := "event"
+	var missing *string
+	stringColumn := Column{Kind: KindString}
+
+	assert.Equal("event", clickhouseValue(&name, stringColumn))
+	assert.Nil(clickhouseValue(missing, stringColumn))
+	assert.Equal("12.5", clickhouseValue(decimal.RequireFromString("12.50"), Column{Kind: KindDecimal}))
+	assert.Equal("10.0.0.1", clickhouseValue(net.ParseIP("10.0.0.1"), stringColumn))
+
+	huge := new(big.Int).Lsh(big.NewInt(1), 100)
+	assert.Equal(huge, clickhouseValue(huge, Column{Kind: KindInteger}))
+	assert.Equal(int64(3), clickhouseValue(int64(3), Column{Kind: KindInteger}))
+}
@@ -0,0 +1,409 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Supported compare modes
+const (
+	compareRowCount = "row_count"
+	compareChecksum = "checksum"
+	compareDiff     = "diff"
+
+	defaultCompareLimit = 10
+)
+
+// Compare represents a step which runs the same query on
+// two targets and compares their results.
+type Compare struct {
+	Name, File, Mode string
+	Template         bool
+	Targets          []string
+	Limit            int
+}
+
+// Validate checks the compare against the playbook targets.
+func (c Compare) Validate(targets []Target) error {
+	if c.File == "" {
+		return fmt.Errorf("compare has no file")
+	}
+
+	switch c.Mode {
+	case "", compareRowCount, compareChecksum, compareDiff:
+	default:
+		return fmt.Errorf("unsupported compare mode %q", c.Mode)
+	}
+
+	if len(c.Targets) != 2 || c.Targets[0] == c.Targets[1] {
+		return fmt.Errorf("compare requires two distinct targets")
+	}
+
+	for _, name := range c.Targets {
+		found := false
+		for _, tgt := range targets {
+			if tgt.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("compare target %s is not in the playbook", name)
+		}
+	}
+	return nil
+}
+
+// mode returns the compare mode, defaulting to a full diff.
+func (c Compare) mode() string {
+	if c.Mode == "" {
+		return compareDiff
+	}
+	return c.Mode
+}
+
+// limit returns the number of differing rows to report.
+func (c Compare) limit() int {
+	if c.Limit <= 0 {
+		return defaultCompareLimit
+	}
+	return c.Limit
+}
+
+// ReadyCompare contains a compare that is ready for execution.
+type ReadyCompare struct {
+	Compare
+	Query ReadyQuery
+}
+
+// CompareError reports mismatching results between two targets.
+type CompareError struct {
+	Message             string
+	LeftName, RightName string
+	LeftOnly, RightOnly ResultSet
+}
+
+// Error implements error.
+func (e *CompareError) Error() string {
+	var message strings.Builder
+	message.WriteString(e.Message)
+	if len(e.LeftOnly.Rows) > 0 {
+		message.WriteString(fmt.Sprintf("\n%sONLY IN %s:\n%s", sampleIndent, e.LeftName, indentedTable(e.LeftOnly, sampleIndent)))
+	}
+	if len(e.RightOnly.Rows) > 0 {
+		message.WriteString(fmt.Sprintf("\n%sONLY IN %s:\n%s", sampleIndent, e.RightName, indentedTable(e.RightOnly, sampleIndent)))
+	}
+	return message.String()
+}
+
+// compareResults compares the results of the left and right targets.
+func compareResults(c Compare, leftName string, left ResultSet, rightName string, right ResultSet) error {
+	leftCount, rightCount := len(left.Rows), len(right.Rows)
+
+	if c.mode() == compareRowCount {
+		if leftCount != rightCount {
+			return &CompareError{
+				Message:   fmt.Sprintf("COMPARE FAILED: %d rows in %s, %d rows in %s", leftCount, leftName, rightCount, rightName),
+				LeftName:  leftName,
+				RightName: rightName,
+			}
+		}
+		return nil
+	}
+
+	if c.mode() == compareChecksum {
+		leftSum, rightSum := checksumRows(left), checksumRows(right)
+		if leftCount != rightCount || leftSum != rightSum {
+			return &CompareError{
+				Message: fmt.Sprintf("COMPARE FAILED: %s has %d rows with checksum %s, %s has %d rows with checksum %s",
+					leftName, leftCount, leftSum, rightName, rightCount, rightSum),
+				LeftName:  leftName,
+				RightName: rightName,
+			}
+		}
+		return nil
+	}
+
+	leftOnly, rightOnly := diffRows(left, right)
+	if len(leftOnly) == 0 && len(rightOnly) == 0 {
+		return nil
+	}
+
+	return &CompareError{
+		Message: fmt.Sprintf("COMPARE FAILED: %d rows only in %s, %d rows only in %s",
+			len(leftOnly), leftName, len(rightOnly), rightName),
+		LeftName:  leftName,
+		RightName: rightName,
+		LeftOnly:  ResultSet{Columns: left.Columns, Rows: limitRows(leftOnly, c.limit())},
+		RightOnly: ResultSet{Columns: right.Columns, Rows: limitRows(rightOnly, c.limit())},
+	}
+}
+
+// checksumRows computes an order-independent checksum of the
+// formatted rows, so equal data typed differently by each
+// database still matches.
+func checksumRows(rs ResultSet) string {
+	keys := make([]string, len(rs.Rows))
+	for i, row := range rs.Rows {
+		keys[i] = rowKey(rs, row)
+	}
+	sort.Strings(keys)
+
+	hash := sha256.New()
+	for _, key := range keys {
+		hash.Write([]byte(key))
+		hash.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// diffRows returns the rows of each side with no match on the
+// other, treating both sides as multisets.
+func diffRows(left ResultSet, right ResultSet) ([][]interface{}, [][]interface{}) {
+	remaining := make(map[string]int)
+	for _, row := range right.Rows {
+		remaining[rowKey(right, row)]++
+	}
+
+	leftOnly := make([][]interface{}, 0)
+	for _, row := range left.Rows {
+		key := rowKey(left, row)
+		if remaining[key] > 0 {
+			remaining[key]--
+			continue
+		}
+		leftOnly = append(leftOnly, row)
+	}
+
+	rightOnly := make([][]interface{}, 0)
+	for _, row := range right.Rows {
+		key := rowKey(right, row)
+		if remaining[key] > 0 {
+			remaining[key]--
+			rightOnly = append(rightOnly, row)
+		}
+	}
+	return leftOnly, rightOnly
+}
+
+// rowKey identifies a row by its formatted values.
+func rowKey(rs ResultSet, row []interface{}) string {
+	return strings.Join(rs.StringRow(row), "\x1f")
+}
+
+func limitRows(rows [][]interface{}, limit int) [][]interface{} {
+	if len(rows) > limit {
+		return rows[:limit]
+	}
+	return rows
+}
+
+// runCompare fetches the compare query results from both
+// targets in parallel and compares them.
+func runCompare(rc ReadyCompare, left Db, right Db) QueryStatus {
+	query := rc.Query
+	leftName, rightName := left.GetTarget().Name, right.GetTarget().Name
+
+	type fetched struct {
+		rs  ResultSet
+		err error
+	}
+	fetch := func(database Db, out *fetched, wg *sync.WaitGroup) {
+		defer wg.Done()
+		sets, err := database.FetchResults(query)
+		if err != nil {
+			out.err = fmt.Errorf("%s: %s", database.GetTarget().Name, err)
+			return
+		}
+		if len(sets) > 0 {
+			out.rs = sets[len(sets)-1]
+		}
+	}
+
+	var l, r fetched
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go fetch(left, &l, &wg)
+	go fetch(right, &r, &wg)
+	wg.Wait()
+
+	if l.err != nil {
+		return QueryStatus{Query: query, Path: query.Path, Error: l.err}
+	}
+	if r.err != nil {
+		return QueryStatus{Query: query, Path: query.Path, Error: r.err}
+	}
+
+	return QueryStatus{Query: query, Path: query.Path, Affected: len(l.rs.Rows), Error: compareResults(rc.Compare, leftName, l.rs, rightName, r.rs)}
+}
+
+// compareCoordinator lets the targets taking part in a compare
+// step meet: the first target of the compare waits for the
+// second to reach the step, runs the comparison using both
+// clients, then releases the second target.
+type compareCoordinator struct {
+	mu       sync.Mutex
+	arrivals map[compareKey]*compareArrival
+	done     map[int]chan struct{}
+	owners   map[int]string
+}
+
+type compareKey struct {
+	step   int
+	target string
+}
+
+type compareArrival struct {
+	ready  chan struct{}
+	closed bool
+	db     Db
+}
+
+// newCompareCoordinator prepares a meeting point for each compare step.
+func newCompareCoordinator(steps []ReadyStep) *compareCoordinator {
+	cc := &compareCoordinator{
+		arrivals: make(map[compareKey]*compareArrival),
+		done:     make(map[int]chan struct{}),
+		owners:   make(map[int]string),
+	}
+	for i, stp := range steps {
+		if stp.Compare == nil {
+			continue
+		}
+		for _, name := range stp.Compare.Targets {
+			cc.arrivals[compareKey{i, name}] = &compareArrival{ready: make(chan struct{})}
+		}
+		cc.done[i] = make(chan struct{})
+		cc.owners[i] = stp.Compare.Targets[0]
+	}
+	return cc
+}
+
+// arrive records that a target reached a compare step.
+func (cc *compareCoordinator) arrive(step int, database Db) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	arrival := cc.arrivals[compareKey{step, database.GetTarget().Name}]
+	if arrival != nil && !arrival.closed {
+		arrival.db = database
+		arrival.closed = true
+		close(arrival.ready)
+	}
+}
+
+// await blocks until a target reached a compare step, returning
+// nil if the target stopped before getting there.
+func (cc *compareCoordinator) await(step int, target string) Db {
+	cc.mu.Lock()
+	arrival := cc.arrivals[compareKey{step, target}]
+	cc.mu.Unlock()
+
+	if arrival == nil {
+		return nil
+	}
+	<-arrival.ready
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return arrival.db
+}
+
+// finish releases the targets waiting on a compare step.
+func (cc *compareCoordinator) finish(step int) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if done, ok := cc.done[step]; ok {
+		close(done)
+		delete(cc.done, step)
+	}
+}
+
+// awaitFinish blocks until the compare of a step has run.
+func (cc *compareCoordinator) awaitFinish(step int) {
+	cc.mu.Lock()
+	done, ok := cc.done[step]
+	cc.mu.Unlock()
+
+	if ok {
+		<-done
+	}
+}
+
+// abandon releases everything a target will no longer reach,
+// either because it failed or it has finished its steps.
+func (cc *compareCoordinator) abandon(target string) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	for key, arrival := range cc.arrivals {
+		if key.target == target && !arrival.closed {
+			arrival.closed = true
+			close(arrival.ready)
+		}
+	}
+	for step, owner := range cc.owners {
+		if done, ok := cc.done[step]; ok && owner == target {
+			close(done)
+			delete(cc.done, step)
+		}
+	}
+}
+
+// reportsFor returns whether a target gets a status from
+// runCompareStep.
+func (rc ReadyCompare) reportsFor(name string, dryRun bool) bool {
+	return name == rc.Targets[0] || dryRun && name == rc.Targets[1]
+}
+
+// runCompareStep takes part in a compare step for a target. Only
+// the first target of the compare returns a status, or both of
+// them in a dry run.
+func runCompareStep(cc *compareCoordinator, database Db, stepIndex int, stepName string, rc ReadyCompare, dryRun bool) []QueryStatus {
+	name := database.GetTarget().Name
+	if name != rc.Targets[0] && name != rc.Targets[1] {
+		return nil
+	}
+
+	if dryRun {
+		return []QueryStatus{database.RunQuery(rc.Query, dryRun, false)}
+	}
+
+	cc.arrive(stepIndex, database)
+	if name != rc.Targets[0] {
+		cc.awaitFinish(stepIndex)
+		return nil
+	}
+	defer cc.finish(stepIndex)
+
+	other := cc.await(stepIndex, rc.Targets[1])
+	if other == nil {
+		err := fmt.Errorf("COMPARE FAILED: target %s did not reach step %s", rc.Targets[1], stepName)
+		return []QueryStatus{{Query: rc.Query, Path: rc.Query.Path, Error: err}}
+	}
+
+	log.Printf("EXECUTING COMPARE %s (in step %s @ %s vs %s): %s", rc.Query.Name, stepName, name, rc.Targets[1], rc.Query.Path)
+	status := runCompare(rc, database, other)
+	if status.Error != nil {
+		log.Printf("FAILURE: %s (step %s @ target %s), ERROR: %s\n", status.Query.Name, stepName, name, status.Error.Error())
+	} else {
+		log.Printf("SUCCESS: %s (step %s @ target %s), ROWS COMPARED: %d\n", status.Query.Name, stepName, name, status.Affected)
+	}
+	return []QueryStatus{status}
+}
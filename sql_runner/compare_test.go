@@ -0,0 +1,269 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDb returns a fixed result set for every query.
+type fakeDb struct {
+	target Target
+	rs     ResultSet
+}
+
+func (f fakeDb) RunQuery(query ReadyQuery, dryRun bool, showQueryOutput bool) QueryStatus {
+	return QueryStatus{Query: query, Path: query.Path}
+}
+
+func (f fakeDb) FetchResults(query ReadyQuery) ([]ResultSet, error) {
+	return []ResultSet{f.rs}, nil
+}
+
+func (f fakeDb) GetTarget() Target {
+	return f.target
+}
+
+func (f fakeDb) IsConnectable() bool {
+	return true
+}
+
+func (f fakeDb) Close() error {
+	return nil
+}
+
+// countingProgress counts the queries started and finished by target.
+type countingProgress struct {
+	lineProgress
+	mu                sync.Mutex
+	started, finished map[string]int
+}
+
+func (cp *countingProgress) QueryStarted(target string, query string) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.started[target]++
+}
+
+func (cp *countingProgress) QueryFinished(target string, status QueryStatus) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.finished[target]++
+}
+
+func TestCompare_Validate(t *testing.T) {
+	targets := []Target{{Name: "a"}, {Name: "b"}}
+
+	testCases := []struct {
+		Name      string
+		Compare   Compare
+		ErrString string
+	}{
+		{
+			Name:      "missing_file",
+			Compare:   Compare{Targets: []string{"a", "b"}},
+			ErrString: "compare has no file",
+		},
+		{
+			Name:      "bad_mode",
+			Compare:   Compare{File: "c.sql", Targets: []string{"a", "b"}, Mode: "fuzzy"},
+			ErrString: `unsupported compare mode "fuzzy"`,
+		},
+		{
+			Name:      "one_target",
+			Compare:   Compare{File: "c.sql", Targets: []string{"a"}},
+			ErrString: "compare requires two distinct targets",
+		},
+		{
+			Name:      "same_target",
+			Compare:   Compare{File: "c.sql", Targets: []string{"a", "a"}},
+			ErrString: "compare requires two distinct targets",
+		},
+		{
+			Name:      "unknown_target",
+			Compare:   Compare{File: "c.sql", Targets: []string{"a", "c"}},
+			ErrString: "compare target c is not in the playbook",
+		},
+		{
+			Name:    "happy_path",
+			Compare: Compare{File: "c.sql", Targets: []string{"b", "a"}, Mode: compareChecksum},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			err := tt.Compare.Validate(targets)
+			if tt.ErrString == "" {
+				assert.Nil(t, err)
+			} else if assert.NotNil(t, err) {
+				assert.Equal(t, tt.ErrString, err.Error())
+			}
+		})
+	}
+}
+
+func TestCompareResults(t *testing.T) {
+	columns := []Column{{Name: "id", Kind: KindInteger}, {Name: "name", Kind: KindString}}
+	left := ResultSet{
+		Columns: columns,
+		Rows:    [][]interface{}{{int64(1), "a"}, {int64(2), "b"}, {int64(2), "b"}},
+	}
+	reordered := ResultSet{
+		Columns: []Column{{Name: "id", Kind: KindDecimal}, {Name: "name", Kind: KindString}},
+		Rows:    [][]interface{}{{"2", "b"}, {"1", "a"}, {"2", "b"}},
+	}
+	changed := ResultSet{
+		Columns: columns,
+		Rows:    [][]interface{}{{int64(1), "a"}, {int64(2), "b"}, {int64(3), nil}},
+	}
+
+	testCases := []struct {
+		Name      string
+		Mode      string
+		Right     ResultSet
+		ErrPrefix string
+	}{
+		{
+			Name:  "diff_equal_any_order",
+			Right: reordered,
+		},
+		{
+			Name:      "diff_changed",
+			Right:     changed,
+			ErrPrefix: "COMPARE FAILED: 1 rows only in left, 1 rows only in right",
+		},
+		{
+			Name:  "checksum_equal",
+			Mode:  compareChecksum,
+			Right: reordered,
+		},
+		{
+			Name:      "checksum_changed",
+			Mode:      compareChecksum,
+			Right:     changed,
+			ErrPrefix: "COMPARE FAILED: left has 3 rows with checksum ",
+		},
+		{
+			Name:  "row_count_equal",
+			Mode:  compareRowCount,
+			Right: changed,
+		},
+		{
+			Name:      "row_count_changed",
+			Mode:      compareRowCount,
+			Right:     ResultSet{Columns: columns},
+			ErrPrefix: "COMPARE FAILED: 3 rows in left, 0 rows in right",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			err := compareResults(Compare{Mode: tt.Mode}, "left", left, "right", tt.Right)
+			if tt.ErrPrefix == "" {
+				assert.Nil(t, err)
+			} else if assert.NotNil(t, err) {
+				assert.True(t, strings.HasPrefix(err.Error(), tt.ErrPrefix), err.Error())
+			}
+		})
+	}
+}
+
+func TestCompareError_Diff(t *testing.T) {
+	assert := assert.New(t)
+
+	columns := []Column{{Name: "id", Kind: KindInteger}}
+	left := ResultSet{Columns: columns, Rows: [][]interface{}{{int64(1)}, {int64(2)}, {int64(3)}}}
+	right := ResultSet{Columns: columns, Rows: [][]interface{}{{int64(1)}, {int64(4)}}}
+
+	err := compareResults(Compare{Limit: 1}, "left", left, "right", right)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	compareErr, ok := err.(*CompareError)
+	if !ok {
+		t.Fatalf("expected *CompareError, got %T", err)
+	}
+	assert.Equal(1, len(compareErr.LeftOnly.Rows))
+	assert.Equal(1, len(compareErr.RightOnly.Rows))
+
+	message := err.Error()
+	assert.True(strings.HasPrefix(message, "COMPARE FAILED: 2 rows only in left, 1 rows only in right\n"))
+	assert.Contains(message, sampleIndent+"ONLY IN left:\n")
+	assert.Contains(message, sampleIndent+"ONLY IN right:\n")
+}
+
+func TestRunCompareStep(t *testing.T) {
+	assert := assert.New(t)
+
+	columns := []Column{{Name: "id", Kind: KindInteger}}
+	steps := []ReadyStep{
+		{
+			Name: "reconcile",
+			Compare: &ReadyCompare{
+				Compare: Compare{Name: "ids", Targets: []string{"a", "b"}},
+				Query:   ReadyQuery{Name: "ids", Path: "ids.sql"},
+			},
+		},
+	}
+	dbs := []Db{
+		fakeDb{target: Target{Name: "a"}, rs: ResultSet{Columns: columns, Rows: [][]interface{}{{int64(1)}}}},
+		fakeDb{target: Target{Name: "b"}, rs: ResultSet{Columns: columns, Rows: [][]interface{}{{int64(2)}}}},
+		fakeDb{target: Target{Name: "c"}},
+	}
+
+	progress := &countingProgress{started: make(map[string]int), finished: make(map[string]int)}
+	cc := newCompareCoordinator(steps)
+	targetChan := make(chan TargetStatus, len(dbs))
+	for _, database := range dbs {
+		go func(database Db) {
+			targetChan <- runSteps(database, steps, cc, progress, false, false, false)
+		}(database)
+	}
+
+	statuses := make(map[string]TargetStatus)
+	for range dbs {
+		status := <-targetChan
+		statuses[status.Name] = status
+	}
+
+	owner := statuses["a"].Steps[len(statuses["a"].Steps)-1]
+	if assert.Equal(1, len(owner.Queries)) {
+		assert.NotNil(owner.Queries[0].Error)
+		assert.True(strings.HasPrefix(owner.Queries[0].Error.Error(), "COMPARE FAILED: 1 rows only in a, 1 rows only in b"))
+	}
+	assert.Equal(0, len(statuses["b"].Steps[len(statuses["b"].Steps)-1].Queries))
+	assert.Equal(0, len(statuses["c"].Steps[len(statuses["c"].Steps)-1].Queries))
+
+	// Only the target reporting the compare shows it running
+	assert.Equal(map[string]int{"a": 1}, progress.started)
+	assert.Equal(map[string]int{"a": 1}, progress.finished)
+}
+
+func TestRunCompareStep_Abandoned(t *testing.T) {
+	assert := assert.New(t)
+
+	rc := ReadyCompare{
+		Compare: Compare{Name: "ids", Targets: []string{"a", "b"}},
+		Query:   ReadyQuery{Name: "ids", Path: "ids.sql"},
+	}
+	cc := newCompareCoordinator([]ReadyStep{{Name: "reconcile", Compare: &rc}})
+	cc.abandon("b")
+
+	statuses := runCompareStep(cc, fakeDb{target: Target{Name: "a"}}, 0, "reconcile", rc, false)
+	if assert.Equal(1, len(statuses)) {
+		assert.Equal("COMPARE FAILED: target b did not reach step reconcile", statuses[0].Error.Error())
+	}
+}
@@ -12,14 +12,61 @@ package main
 
 import (
 	"bytes"
+	"log"
 	"text/template"
 )
 
 // Db is a generalized interface to a database client.
 type Db interface {
 	RunQuery(ReadyQuery, bool, bool) QueryStatus
+	FetchResults(ReadyQuery) ([]ResultSet, error)
 	GetTarget() Target
 	IsConnectable() bool
+	Close() error
+}
+
+// Validator is implemented by targets which can check a query, on
+// -dryRun -validate, without modifying any data.
+type Validator interface {
+	ValidateQuery(ReadyQuery) QueryStatus
+}
+
+// HealthChecker is implemented by targets which pool connections. It is
+// called between steps, replacing connections which died during a long
+// step.
+type HealthChecker interface {
+	CheckHealth() error
+}
+
+// runScript implements RunQuery for targets which fetch result sets
+// separately. With showQueryOutput, the result sets are fetched and
+// printed, and their rows count as affected. Otherwise the script is
+// run by exec.
+func runScript(query ReadyQuery, dryRun bool, showQueryOutput bool, fetch func(ReadyQuery) ([]ResultSet, error), exec func() (int, error)) QueryStatus {
+	if dryRun {
+		return QueryStatus{Query: query, Path: query.Path}
+	}
+
+	if showQueryOutput {
+		sets, err := fetch(query)
+		if err != nil {
+			log.Printf("ERROR: %s.", err)
+			return QueryStatus{Query: query, Path: query.Path, Error: err}
+		}
+
+		affected := 0
+		for _, rs := range sets {
+			affected += len(rs.Rows)
+		}
+		if err := printTables(sets); err != nil {
+			log.Printf("ERROR: %s.", err)
+			return QueryStatus{Query: query, Path: query.Path, Affected: affected, Error: err}
+		}
+		return QueryStatus{Query: query, Path: query.Path, Affected: affected}
+	}
+
+	affected, err := exec()
+	return QueryStatus{Query: query, Path: query.Path, Affected: affected, Error: err}
 }
 
 // Reads the script and fills in the template
@@ -0,0 +1,82 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+
+//go:build cgo
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/marcboeker/go-duckdb"
+)
+
+func init() {
+	registerTarget(NewDuckDBTarget, duckdbType)
+}
+
+// NewDuckDBTarget returns a ptr to a SQLTarget for a DuckDB
+// database file, or an in-memory database.
+func NewDuckDBTarget(target Target) (*SQLTarget, error) {
+	if target.Path == "" {
+		return nil, fmt.Errorf("missing target path")
+	}
+
+	path := target.Path
+	if path == memoryPath {
+		path = ""
+	}
+
+	connector, err := duckdb.NewConnector(path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Connections of a connector share the same database,
+	// including an in-memory one
+	db := sql.OpenDB(connector)
+	if err := configurePool(db, target); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLTarget{
+		Target:  target,
+		Client:  db,
+		Dialect: postgresDialect,
+		Convert: duckdbValue,
+	}, nil
+}
+
+// duckdbValue converts the DuckDB specific types.
+func duckdbValue(value interface{}, column Column) interface{} {
+	switch v := value.(type) {
+	case duckdb.Decimal:
+		if v.Value == nil {
+			return nil
+		}
+		scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(v.Scale)), nil)
+		return new(big.Rat).SetFrac(v.Value, scale).FloatString(int(v.Scale))
+	case duckdb.Interval:
+		return fmt.Sprintf("%d months %d days %d microseconds", v.Months, v.Days, v.Micros)
+	case []byte:
+		if strings.EqualFold(column.DatabaseType, "UUID") {
+			if id, err := uuid.FromBytes(v); err == nil {
+				return id.String()
+			}
+		}
+	}
+	return value
+}
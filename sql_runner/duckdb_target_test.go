@@ -0,0 +1,64 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+
+//go:build cgo
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDuckDBTarget(t *testing.T) {
+	testCases := []struct {
+		Name string
+		Path string
+	}{
+		{Name: "memory", Path: memoryPath},
+		{Name: "file", Path: filepath.Join(t.TempDir(), "test.duckdb")},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			duck, err := NewDuckDBTarget(Target{Name: "duck", Type: duckdbType, Path: tt.Path})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.True(duck.IsConnectable())
+
+			status := duck.RunQuery(ReadyQuery{Name: "load", Script: embeddedScript}, false, false)
+			assert.Nil(status.Error)
+			assert.Equal(3, status.Affected)
+
+			// Queries may run on other connections of the pool
+			sets, err := duck.FetchResults(ReadyQuery{Script: "SELECT COUNT(*) AS n FROM table1; SELECT city FROM table1 ORDER BY age"})
+			assert.Nil(err)
+			if assert.Equal(2, len(sets)) {
+				assert.Equal([][]string{{"3"}}, sets[0].StringRows())
+				assert.Equal([][]string{{"new york"}, {"london;uk"}, {nullString}}, sets[1].StringRows())
+			}
+
+			sets, err = duck.FetchResults(ReadyQuery{Script: "SELECT CAST(1.50 AS DECIMAL(10,2)) AS d, CAST('6ba7b810-9dad-11d1-80b4-00c04fd430c8' AS UUID) AS u"})
+			assert.Nil(err)
+			if assert.Equal(1, len(sets)) {
+				assert.Equal([][]string{{"1.5", "6ba7b810-9dad-11d1-80b4-00c04fd430c8"}}, sets[0].StringRows())
+			}
+
+			status = duck.RunQuery(ReadyQuery{Name: "bad", Script: "CREATE TABLE"}, false, false)
+			assert.NotNil(status.Error)
+		})
+	}
+}
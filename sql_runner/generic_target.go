@@ -0,0 +1,94 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+const (
+	splitterStatements = "statements" // Split on semicolons, the default
+	splitterBatches    = "batches"    // Split on GO lines
+	splitterNone       = "none"       // Send the script as a whole
+)
+
+func init() {
+	registerTarget(NewGenericTarget, genericType)
+}
+
+// NewGenericTarget returns a ptr to a SQLTarget for any database/sql
+// driver compiled into sql-runner.
+func NewGenericTarget(target Target) (*SQLTarget, error) {
+	if target.Driver == "" || target.Dsn == "" {
+		return nil, fmt.Errorf("missing target driver or dsn")
+	}
+
+	split, err := splitterFor(target.Splitter, target.Dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isDriverRegistered(target.Driver) {
+		return nil, fmt.Errorf("sql driver %q is not available, available drivers are: %s",
+			target.Driver, strings.Join(sql.Drivers(), ", "))
+	}
+
+	db, err := sql.Open(target.Driver, target.Dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := configurePool(db, target); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// The DSN may hold credentials, so only the driver is logged
+	return &SQLTarget{Target: target, Client: db, Split: split}, nil
+}
+
+// splitterFor returns the statement splitter of a generic target,
+// splitting statements with the quoting rules of its dialect.
+func splitterFor(splitter string, dialectName string) (func(script string) []string, error) {
+	d, ok := dialects[strings.ToLower(dialectName)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported dialect %q", dialectName)
+	}
+
+	switch strings.ToLower(splitter) {
+	case "", splitterStatements:
+		return func(script string) []string {
+			return splitStatements(script, d)
+		}, nil
+	case splitterBatches:
+		return splitBatches, nil
+	case splitterNone:
+		return func(script string) []string {
+			if strings.TrimSpace(script) == "" {
+				return nil
+			}
+			return []string{script}
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported splitter %q", splitter)
+	}
+}
+
+// isDriverRegistered reports whether a database/sql driver is registered.
+func isDriverRegistered(driver string) bool {
+	for _, d := range sql.Drivers() {
+		if d == driver {
+			return true
+		}
+	}
+	return false
+}
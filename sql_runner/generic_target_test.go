@@ -0,0 +1,144 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewGenericTarget_Error(t *testing.T) {
+	testCases := []struct {
+		Name      string
+		Input     Target
+		ErrString string
+	}{
+		{
+			Name:      "missing_dsn",
+			Input:     Target{Driver: "sqlite"},
+			ErrString: "missing target driver or dsn",
+		},
+		{
+			Name:      "unsupported_splitter",
+			Input:     Target{Driver: "sqlite", Dsn: memoryPath, Splitter: "lines"},
+			ErrString: `unsupported splitter "lines"`,
+		},
+		{
+			Name:      "unknown_driver",
+			Input:     Target{Driver: "oracle", Dsn: "oracle://localhost"},
+			ErrString: `sql driver "oracle" is not available, available drivers are: `,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			result, err := NewGenericTarget(tt.Input)
+			assert.Nil(result)
+			if err == nil {
+				t.Fatalf("expected error, got nil")
+			}
+
+			assert.Contains(err.Error(), tt.ErrString)
+		})
+	}
+}
+
+func TestGenericTarget(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Splitter string
+		Affected int
+	}{
+		{Name: "statements", Splitter: "", Affected: 3},
+		{Name: "none", Splitter: splitterNone, Affected: 2}, // Only the last statement is counted
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			generic, err := NewGenericTarget(Target{
+				Name:     "generic",
+				Type:     genericType,
+				Driver:   "sqlite",
+				Dsn:      filepath.Join(t.TempDir(), "test.db"),
+				Splitter: tt.Splitter,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.True(generic.IsConnectable())
+
+			status := generic.RunQuery(ReadyQuery{Name: "load", Script: embeddedScript}, false, false)
+			assert.Nil(status.Error)
+			assert.Equal(tt.Affected, status.Affected)
+
+			sets, err := generic.FetchResults(ReadyQuery{Script: "SELECT COUNT(*) AS n FROM table1"})
+			assert.Nil(err)
+			if assert.Equal(1, len(sets)) {
+				assert.Equal([][]string{{"3"}}, sets[0].StringRows())
+			}
+		})
+	}
+}
+
+func TestGenericTarget_SingleConnection(t *testing.T) {
+	assert := assert.New(t)
+
+	generic, err := NewGenericTarget(Target{
+		Name:   "generic",
+		Type:   genericType,
+		Driver: "sqlite",
+		Dsn:    filepath.Join(t.TempDir(), "test.db"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Statements run on pooled connections would each get a new one
+	generic.Client.SetMaxIdleConns(0)
+
+	// Temp tables only live as long as their connection
+	script := "CREATE TEMP TABLE staged AS SELECT 1 AS a; INSERT INTO staged VALUES (2); "
+	status := generic.RunQuery(ReadyQuery{Name: "load", Script: script + "CREATE TABLE kept AS SELECT * FROM staged;"}, false, false)
+	assert.Nil(status.Error)
+
+	sets, err := generic.FetchResults(ReadyQuery{Script: script + "SELECT COUNT(*) AS n FROM staged"})
+	assert.Nil(err)
+	if assert.Equal(1, len(sets)) {
+		assert.Equal([][]string{{"2"}}, sets[0].StringRows())
+	}
+}
+
+func TestSplitterFor(t *testing.T) {
+	assert := assert.New(t)
+
+	split, err := splitterFor("BATCHES", "")
+	assert.Nil(err)
+	assert.Equal(2, len(split("SELECT 1;\nGO\nSELECT 2;\n")))
+
+	split, err = splitterFor(splitterNone, "")
+	assert.Nil(err)
+	assert.Equal([]string{"SELECT 1; SELECT 2;"}, split("SELECT 1; SELECT 2;"))
+	assert.Empty(split(" \n"))
+
+	split, err = splitterFor("", "MySQL")
+	assert.Nil(err)
+	assert.Equal([]string{`SELECT 'a\';b'`, "SELECT 2"}, split(`SELECT 'a\';b'; SELECT 2;`))
+
+	_, err = splitterFor("", "oracle")
+	if assert.NotNil(err) {
+		assert.Equal(`unsupported dialect "oracle"`, err.Error())
+	}
+}
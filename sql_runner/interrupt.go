@@ -0,0 +1,42 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+var (
+	interruptOnce sync.Once
+	interruptCtx  context.Context
+)
+
+// interruptContext returns a context cancelled by the first SIGINT or
+// SIGTERM, for targets which can cancel their queries on the server.
+// The default handling of the signals is then restored, so that a
+// second one exits straight away.
+func interruptContext() context.Context {
+	interruptOnce.Do(func() {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-ctx.Done()
+			stop()
+			log.Printf("INTERRUPTED: Cancelling running queries, interrupt again to exit.")
+		}()
+		interruptCtx = ctx
+	})
+	return interruptCtx
+}
@@ -23,7 +23,7 @@ import (
 
 const (
 	cliName        = "sql-runner"
-	cliDescription = `Run playbooks of SQL scripts in series and parallel on Redshift, Postgres, BigQuery and Snowflake`
+	cliDescription = `Run playbooks of SQL scripts in series and parallel on Redshift, Postgres, BigQuery, Snowflake, MySQL, SQLite, DuckDB, ClickHouse, MSSQL, Trino, any database/sql driver and plugins`
 	cliVersion     = "0.11.0"
 
 	sqlrootBinary        = "BINARY"
@@ -35,6 +35,7 @@ const (
 func main() {
 
 	options := processFlags()
+	log.SetOutput(redactingWriter{os.Stderr})
 
 	lockFile, lockErr := LockFileFromOptions(options)
 	if lockErr != nil {
@@ -71,7 +72,12 @@ func main() {
 		}
 	}
 
-	statuses := Run(*pb, sp, options.fromStep, options.runQuery, options.dryRun, options.fillTemplates, options.showQueryOutput)
+	// Live progress would be garbled by query output and template dumps
+	progressEnabled := !options.noProgress && !options.showQueryOutput && !options.fillTemplates
+	progress := NewProgress(os.Stdout, progressEnabled)
+
+	statuses := Run(*pb, sp, options.fromStep, options.runQuery, options.dryRun, options.validate, options.fillTemplates, options.showQueryOutput, progress)
+	progress.Stop()
 	code, message := review(statuses)
 
 	// Unlock on success and soft-lock
@@ -139,6 +145,11 @@ func processFlags() Options {
 		os.Exit(2)
 	}
 
+	if options.validate && !options.dryRun {
+		fmt.Println("flag -validate requires -dryRun")
+		os.Exit(2)
+	}
+
 	sr, err := resolveSQLRoot(options.sqlroot, options.playbook, options.consul, options.consulOnlyForLock)
 	if err != nil {
 		fmt.Printf("Error resolving -sqlroot: %s\n%s\n", options.sqlroot, err)
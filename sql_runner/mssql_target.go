@@ -0,0 +1,218 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+
+	mssql "github.com/microsoft/go-mssqldb"
+	"github.com/microsoft/go-mssqldb/batch"
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+const batchSeparator = "GO"
+
+// MSSQLTarget represents SQL Server and Azure SQL as target.
+type MSSQLTarget struct {
+	Target
+	Client *sql.DB
+	tunnel *sshTunnel
+}
+
+// IsConnectable tests connection to determine whether the SQL Server target is
+// connectable.
+func (mt MSSQLTarget) IsConnectable() bool {
+	return mt.Client.Ping() == nil
+}
+
+func init() {
+	registerTarget(NewMSSQLTarget, mssqlType)
+}
+
+// NewMSSQLTarget returns a ptr to a MSSQLTarget.
+func NewMSSQLTarget(target Target) (*MSSQLTarget, error) {
+	if target.Host == "" || target.Port == "" || target.Username == "" || target.Database == "" {
+		return nil, fmt.Errorf("missing target connection parameters")
+	}
+
+	tlsConfig, err := tlsConfigFromTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	_, tunnel, err := dialerFromTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("database", target.Database)
+	params.Set("app name", "sql-runner")
+	if tlsConfig != nil {
+		params.Set("encrypt", "true")
+	}
+	dsn := url.URL{
+		Scheme:   "sqlserver",
+		User:     url.UserPassword(target.Username, target.Password),
+		Host:     net.JoinHostPort(target.Host, target.Port),
+		RawQuery: params.Encode(),
+	}
+
+	config, err := msdsn.Parse(dsn.String())
+	if err != nil {
+		return nil, err
+	}
+	config.DialTimeout = dialTimeout
+	if tlsConfig != nil {
+		// Keep the server name chosen by tlsConfigFromTarget
+		config.TLSConfig = tlsConfig
+		config.HostInCertificateProvided = true
+	}
+
+	connector := mssql.NewConnectorConfig(config)
+	if tunnel != nil {
+		connector.Dialer = tunnelHostDialer{tunnel, target.Host}
+	}
+
+	db := sql.OpenDB(connector)
+	if err := configurePool(db, target); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &MSSQLTarget{target, db, tunnel}, nil
+}
+
+// GetTarget returns the Target field of MSSQLTarget.
+func (mt MSSQLTarget) GetTarget() Target {
+	return mt.Target
+}
+
+// Close closes the client of the SQL Server target and its SSH tunnel.
+func (mt MSSQLTarget) Close() error {
+	return closeTunneled(mt.Client, mt.tunnel)
+}
+
+// CheckHealth replaces dead pooled connections of the SQL Server target.
+func (mt MSSQLTarget) CheckHealth() error {
+	return checkSQLHealth(mt.Client, mt.Target)
+}
+
+// RunQuery runs a query against the target.
+func (mt MSSQLTarget) RunQuery(query ReadyQuery, dryRun bool, showQueryOutput bool) QueryStatus {
+	return runScript(query, dryRun, showQueryOutput, mt.FetchResults, func() (int, error) {
+		batches, err := mt.exec(query.Script)
+		return sumBatches(query, mt.Name, batches), err
+	})
+}
+
+// sumBatches logs the rows affected by each batch of a script run
+// in several, returning their total.
+func sumBatches(query ReadyQuery, target string, batches []int) int {
+	affected := 0
+	for i, rows := range batches {
+		if len(batches) > 1 {
+			log.Printf("BATCH %d: %s (@ target %s), ROWS AFFECTED: %d", i+1, query.Name, target, rows)
+		}
+		affected += rows
+	}
+	return affected
+}
+
+// FetchResults runs a query and returns its result sets.
+func (mt MSSQLTarget) FetchResults(query ReadyQuery) ([]ResultSet, error) {
+	return mt.queryResults(query.Script)
+}
+
+// exec runs each batch of a script on a single connection, so
+// temp tables, SET options and USE carry over to later batches, and
+// returns the rows affected by each batch. The count of a batch
+// covers every statement in it, unless the batch turns on NOCOUNT.
+func (mt MSSQLTarget) exec(script string) ([]int, error) {
+	ctx := context.Background()
+	conn, err := mt.Client.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	batches := splitBatches(script)
+	affected := make([]int, 0, len(batches))
+	for _, b := range batches {
+		res, err := conn.ExecContext(ctx, b)
+		if err != nil {
+			return affected, err
+		}
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return affected, err
+		}
+		affected = append(affected, int(rows))
+	}
+	return affected, nil
+}
+
+// queryResults runs each batch of a script on a single connection,
+// returning the rows of each statement which produced a result set.
+func (mt MSSQLTarget) queryResults(script string) ([]ResultSet, error) {
+	ctx := context.Background()
+	conn, err := mt.Client.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	sets := make([]ResultSet, 0)
+	for _, b := range splitBatches(script) {
+		rows, err := conn.QueryContext(ctx, b)
+		if err != nil {
+			return nil, err
+		}
+
+		results, err := resultSetsFromRows(rows, mssqlValue)
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, results...)
+	}
+	return sets, nil
+}
+
+// splitBatches splits a script on its GO separator lines, as
+// sqlcmd does, repeating batches followed by GO n. Empty batches
+// are dropped.
+func splitBatches(script string) []string {
+	batches := make([]string, 0)
+	for _, b := range batch.Split(script, batchSeparator) {
+		if strings.TrimSpace(b) != "" {
+			batches = append(batches, b)
+		}
+	}
+	return batches
+}
+
+// mssqlValue converts the SQL Server specific types.
+func mssqlValue(value interface{}, column Column) interface{} {
+	if v, ok := value.([]byte); ok && strings.EqualFold(column.DatabaseType, "UNIQUEIDENTIFIER") {
+		var id mssql.UniqueIdentifier
+		if err := id.Scan(v); err == nil {
+			return id.String()
+		}
+	}
+	return value
+}
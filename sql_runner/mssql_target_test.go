@@ -0,0 +1,151 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMSSQLTarget_Error(t *testing.T) {
+	testCases := []struct {
+		Name      string
+		Input     Target
+		ErrString string
+	}{
+		{
+			Name: "missing_username",
+			Input: Target{
+				Host:     "localhost",
+				Port:     "1433",
+				Database: "master",
+			},
+			ErrString: "missing target connection parameters",
+		},
+		{
+			Name: "missing_root_cert",
+			Input: Target{
+				Host:        "localhost",
+				Port:        "1433",
+				Username:    "sa",
+				Database:    "master",
+				SslRootCert: "/does/not/exist.pem",
+			},
+			ErrString: "unable to read ssl_root_cert: open /does/not/exist.pem: no such file or directory",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			result, err := NewMSSQLTarget(tt.Input)
+			assert.Nil(result)
+			if err == nil {
+				t.Fatalf("expected error, got nil")
+			}
+
+			assert.Equal(tt.ErrString, err.Error())
+		})
+	}
+}
+
+func TestNewMSSQLTarget(t *testing.T) {
+	assert := assert.New(t)
+
+	input := Target{
+		Name:     "mssql",
+		Type:     mssqlType,
+		Host:     "localhost",
+		Port:     "1433",
+		Username: "sa",
+		Password: "p@ss;word",
+		Database: "master",
+		Ssl:      true,
+	}
+
+	result, err := NewMSSQLTarget(input)
+	assert.Nil(err)
+	if result == nil {
+		t.Fatal("unexpected nil result")
+	}
+
+	assert.Equal(input, result.GetTarget())
+}
+
+func TestSplitBatches(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Script   string
+		Expected []string
+	}{
+		{
+			Name:     "no_separator",
+			Script:   "SELECT 1;\nSELECT 2;",
+			Expected: []string{"SELECT 1;\nSELECT 2;"},
+		},
+		{
+			Name:     "separated",
+			Script:   "CREATE TABLE t (a int);\nGO\nCREATE VIEW v AS SELECT a FROM t;\ngo\n",
+			Expected: []string{"CREATE TABLE t (a int);\n", "CREATE VIEW v AS SELECT a FROM t;\n"},
+		},
+		{
+			Name:     "repeated",
+			Script:   "INSERT INTO t VALUES (1);\nGO 2\n",
+			Expected: []string{"INSERT INTO t VALUES (1);\n", "INSERT INTO t VALUES (1);\n"},
+		},
+		{
+			Name:     "quoted_and_commented",
+			Script:   "SELECT 'GO\nGO' AS a;\n/*\nGO\n*/\nSELECT 2;",
+			Expected: []string{"SELECT 'GO\nGO' AS a;\n/*\nGO\n*/\nSELECT 2;"},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			batches := splitBatches(tt.Script)
+			for i := range batches {
+				batches[i] = strings.TrimLeft(batches[i], "\n")
+			}
+			assert.Equal(t, tt.Expected, batches)
+		})
+	}
+}
+
+func TestSumBatches(t *testing.T) {
+	assert := assert.New(t)
+
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+
+	query := ReadyQuery{Name: "load"}
+	assert.Equal(3, sumBatches(query, "mssql", []int{3}))
+	assert.Empty(out.String())
+
+	assert.Equal(6, sumBatches(query, "mssql", []int{1, 2, 3}))
+	assert.Contains(out.String(), "BATCH 1: load (@ target mssql), ROWS AFFECTED: 1")
+	assert.Contains(out.String(), "BATCH 3: load (@ target mssql), ROWS AFFECTED: 3")
+}
+
+func TestMSSQLValue(t *testing.T) {
+	assert := assert.New(t)
+
+	// SQL Server stores the first three groups little endian
+	raw := []byte{0x67, 0x45, 0x23, 0x01, 0xab, 0x89, 0xef, 0xcd, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+	assert.Equal("01234567-89AB-CDEF-0123-456789ABCDEF", mssqlValue(raw, Column{DatabaseType: "UNIQUEIDENTIFIER"}))
+	assert.Equal([]byte("12.50"), mssqlValue([]byte("12.50"), Column{DatabaseType: "DECIMAL"}))
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQLTarget represents MySQL and MariaDB as target.
+type MySQLTarget struct {
+	Target
+	Client *sql.DB
+	tunnel *sshTunnel
+}
+
+// IsConnectable tests connection to determine whether the MySQL target is
+// connectable.
+func (mt MySQLTarget) IsConnectable() bool {
+	return mt.Client.Ping() == nil
+}
+
+func init() {
+	registerTarget(NewMySQLTarget, mysqlType)
+}
+
+// NewMySQLTarget returns a ptr to a MySQLTarget.
+func NewMySQLTarget(target Target) (*MySQLTarget, error) {
+	if target.Host == "" || target.Port == "" || target.Username == "" || target.Database == "" {
+		return nil, fmt.Errorf("missing target connection parameters")
+	}
+
+	tlsConfig, err := tlsConfigFromTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	dial, tunnel, err := dialerFromTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	config := mysql.NewConfig()
+	config.User = target.Username
+	config.Passwd = target.Password
+	config.Net = "tcp"
+	config.Addr = net.JoinHostPort(target.Host, target.Port)
+	config.DBName = target.Database
+	config.TLS = tlsConfig
+	config.MultiStatements = true
+	config.Timeout = dialTimeout
+	config.ReadTimeout = readTimeout
+	if tunnel != nil {
+		config.DialFunc = dial
+	}
+
+	connector, err := mysql.NewConnector(config)
+	if err != nil {
+		return nil, err
+	}
+
+	db := sql.OpenDB(connector)
+	if err := configurePool(db, target); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &MySQLTarget{target, db, tunnel}, nil
+}
+
+// GetTarget returns the Target field of MySQLTarget.
+func (mt MySQLTarget) GetTarget() Target {
+	return mt.Target
+}
+
+// Close closes the client of the MySQL target and its SSH tunnel.
+func (mt MySQLTarget) Close() error {
+	return closeTunneled(mt.Client, mt.tunnel)
+}
+
+// CheckHealth replaces dead pooled connections of the MySQL target.
+func (mt MySQLTarget) CheckHealth() error {
+	return checkSQLHealth(mt.Client, mt.Target)
+}
+
+// RunQuery runs a query against the target.
+func (mt MySQLTarget) RunQuery(query ReadyQuery, dryRun bool, showQueryOutput bool) QueryStatus {
+	return runScript(query, dryRun, showQueryOutput, mt.FetchResults, func() (int, error) {
+		return mt.exec(query.Script)
+	})
+}
+
+// FetchResults runs a query and returns its result sets.
+func (mt MySQLTarget) FetchResults(query ReadyQuery) ([]ResultSet, error) {
+	return mt.queryResults(query.Script)
+}
+
+// exec runs a script, returning the rows affected summed
+// over all of its statements.
+func (mt MySQLTarget) exec(script string) (int, error) {
+	ctx := context.Background()
+	conn, err := mt.Client.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	affected := 0
+	err = conn.Raw(func(driverConn interface{}) error {
+		execer, ok := driverConn.(driver.ExecerContext)
+		if !ok {
+			return errors.New("mysql driver does not support exec")
+		}
+
+		res, err := execer.ExecContext(ctx, script, nil)
+		if err != nil {
+			return err
+		}
+
+		// RowsAffected only covers the last statement of the script
+		if multi, ok := res.(mysql.Result); ok {
+			for _, rows := range multi.AllRowsAffected() {
+				affected += int(rows)
+			}
+			return nil
+		}
+		rows, err := res.RowsAffected()
+		affected = int(rows)
+		return err
+	})
+	return affected, err
+}
+
+// queryResults runs a script, returning the rows of each
+// statement which produced a result set.
+func (mt MySQLTarget) queryResults(script string) ([]ResultSet, error) {
+	rows, err := mt.Client.Query(script)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return resultSetsFromRows(rows, nil)
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMySQLTarget_Error(t *testing.T) {
+	testCases := []struct {
+		Name      string
+		Input     Target
+		ErrString string
+	}{
+		{
+			Name: "missing_host",
+			Input: Target{
+				Port:     "3306",
+				Username: "root",
+				Database: "mysql",
+			},
+			ErrString: "missing target connection parameters",
+		},
+		{
+			Name: "missing_database",
+			Input: Target{
+				Host:     "localhost",
+				Port:     "3306",
+				Username: "root",
+			},
+			ErrString: "missing target connection parameters",
+		},
+		{
+			Name: "missing_root_cert",
+			Input: Target{
+				Host:        "localhost",
+				Port:        "3306",
+				Username:    "root",
+				Database:    "mysql",
+				SslRootCert: "/does/not/exist.pem",
+			},
+			ErrString: "unable to read ssl_root_cert: open /does/not/exist.pem: no such file or directory",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			result, err := NewMySQLTarget(tt.Input)
+			assert.Nil(result)
+			if err == nil {
+				t.Fatalf("expected error, got nil")
+			}
+
+			assert.Equal(tt.ErrString, err.Error())
+		})
+	}
+}
+
+func TestNewMySQLTarget(t *testing.T) {
+	assert := assert.New(t)
+
+	input := Target{
+		Name:     "mysql",
+		Type:     mysqlType,
+		Host:     "localhost",
+		Port:     "3306",
+		Username: "root",
+		Database: "mysql",
+		Ssl:      true,
+	}
+
+	result, err := NewMySQLTarget(input)
+	assert.Nil(err)
+	if result == nil {
+		t.Fatal("unexpected nil result")
+	}
+
+	assert.Equal(input, result.GetTarget())
+}
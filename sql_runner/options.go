@@ -49,6 +49,7 @@ type Options struct {
 	sqlroot           string
 	fromStep          string
 	dryRun            bool
+	validate          bool
 	consul            string
 	lock              string
 	softLock          string
@@ -59,6 +60,7 @@ type Options struct {
 	fillTemplates     bool
 	consulOnlyForLock bool
 	showQueryOutput   bool
+	noProgress        bool
 }
 
 // NewOptions returns Options.
@@ -76,7 +78,8 @@ func (o *Options) GetFlagSet() *flag.FlagSet {
 	fs.StringVar(&(o.sqlroot), "sqlroot", sqlrootPlaybook, fmt.Sprintf("Absolute path to SQL scripts. Use %s, %s and %s for those respective paths", sqlrootPlaybook, sqlrootBinary, sqlrootPlaybookChild))
 	fs.Var(&(o.variables), "var", "Variables to be passed to the playbook, in the key=value format")
 	fs.StringVar(&(o.fromStep), "fromStep", "", "Starts from a given step defined in your playbook")
-	fs.BoolVar(&(o.dryRun), "dryRun", false, "Runs through a playbook without executing any of the SQL")
+	fs.BoolVar(&(o.dryRun), "dryRun", false, "Runs through a playbook without executing any of the SQL, estimating the cost of BigQuery queries")
+	fs.BoolVar(&(o.validate), "validate", false, "With -dryRun, validates every query on its target without modifying any data")
 	fs.StringVar(&(o.consul), "consul", "", "The address of a consul server with playbooks and SQL files stored in KV pairs")
 	fs.StringVar(&(o.lock), "lock", "", "Optional argument which checks and sets a lockfile to ensure this run is a singleton. Deletes lock on run completing successfully")
 	fs.StringVar(&(o.softLock), "softLock", "", "Optional argument, like '-lock' but the lockfile will be deleted even if the run fails")
@@ -86,6 +89,7 @@ func (o *Options) GetFlagSet() *flag.FlagSet {
 	fs.BoolVar(&(o.fillTemplates), "fillTemplates", false, "Will print all queries after templates are filled")
 	fs.BoolVar(&(o.consulOnlyForLock), "consulOnlyForLock", false, "Will read playbooks locally, but use Consul for locking.")
 	fs.BoolVar(&(o.showQueryOutput), "showQueryOutput", false, "Will print all output from queries")
+	fs.BoolVar(&(o.noProgress), "noProgress", false, "Will log line by line instead of showing live progress in a terminal")
 	// TODO: add format flag if/when we support TOML
 
 	return fs
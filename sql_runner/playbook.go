@@ -18,6 +18,7 @@ import (
 type Playbook struct {
 	Targets   []Target
 	Variables map[string]interface{}
+	Sensitive []string // Variables redacted from the output
 	Steps     []Step
 }
 
@@ -25,22 +26,73 @@ type Playbook struct {
 type Target struct {
 	Name, Type, Host, Database, Port, Username,
 	Password, Region, Account, Warehouse, Project string
-	QueryTag             string `yaml:"query_tag"`
-	Ssl                  bool
-	PrivateKeyPath       string `yaml:"private_key_path"`
-	PrivateKeyPassphrase string `yaml:"private_key_passphrase"`
+	Path                      string // Database file of embedded targets
+	Protocol                  string // Wire protocol of ClickHouse targets
+	Catalog, Schema           string
+	QueryTag                  string `yaml:"query_tag"`
+	Role                      string
+	OauthTokenFile            string `yaml:"oauth_token_file"`
+	OauthTokenEnv             string `yaml:"oauth_token_env"` // Environment variable holding the OAuth token
+	ExternalBrowser           string `yaml:"external_browser"`
+	Ssl                       bool
+	PrivateKeyPath            string  `yaml:"private_key_path"`
+	PrivateKeyPassphrase      string  `yaml:"private_key_passphrase"`
+	SslRootCert               string  `yaml:"ssl_root_cert"`
+	SslCert                   string  `yaml:"ssl_cert"`
+	SslKey                    string  `yaml:"ssl_key"`
+	SslServerName             string  `yaml:"ssl_server_name"`
+	SslMode                   string  `yaml:"sslmode"`    // libpq sslmode of Postgres and Redshift targets
+	ClusterId                 string  `yaml:"cluster_id"` // Redshift cluster of IAM authentication
+	Workgroup                 string  // Redshift Serverless workgroup of IAM authentication
+	DbUser                    string  `yaml:"db_user"`
+	AutoCreate                bool    `yaml:"auto_create"`
+	IamRole                   string  `yaml:"iam_role"`
+	Profile                   string  // AWS profile of IAM authentication
+	AwsEndpoint               string  `yaml:"aws_endpoint"`
+	DefaultDataset            string  `yaml:"default_dataset"` // BigQuery dataset, or project.dataset, of unqualified tables
+	BillingProject            string  `yaml:"billing_project"` // BigQuery project running the jobs, defaults to project
+	CredentialsFile           string  `yaml:"credentials_file"`
+	CredentialsEnv            string  `yaml:"credentials_env"` // Environment variable holding the credentials JSON
+	ImpersonateServiceAccount string  `yaml:"impersonate_service_account"`
+	Endpoint                  string  // BigQuery API endpoint, such as an emulator
+	Priority                  string  // BigQuery job priority, batch or interactive
+	MaximumBytesBilled        int64   `yaml:"maximum_bytes_billed"`
+	UseLegacySql              *bool   `yaml:"use_legacy_sql"`  // Only false is supported
+	PricePerTib               float64 `yaml:"price_per_tib"`   // Price of a TiB processed by BigQuery, to estimate costs
+	EstimateBudget            float64 `yaml:"estimate_budget"` // Maximum estimated cost of a BigQuery dry run
+	MaxOpenConns              int     `yaml:"max_open_conns"`  // Pooled connections, 0 for the driver default
+	MaxIdleConns              int     `yaml:"max_idle_conns"`
+	ConnMaxLifetime           int     `yaml:"conn_max_lifetime"` // Seconds before a pooled connection is replaced, 0 to keep it
+
+	SshTunnel         *SshTunnel             `yaml:"ssh_tunnel"`
+	Async             *SnowflakeAsync        // Asynchronous execution of Snowflake queries
+	Vault             *VaultAuth             // Dynamic credentials of Postgres and Redshift targets
+	SessionProperties map[string]string      `yaml:"session_properties"`
+	Driver, Dsn       string                 // database/sql driver and DSN of generic targets
+	Splitter          string                 // Statement splitting of generic targets
+	Dialect           string                 // Quoting rules of generic targets splitting statements
+	Plugin            string                 // Executable of plugin targets
+	Params            map[string]string      // Extra connection parameters
+	Labels            map[string]string      // Job labels of BigQuery targets
+	QueryParameters   map[string]interface{} `yaml:"query_parameters"` // Query parameters of BigQuery targets
 }
 
 // Step represents a playbook step.
 type Step struct {
 	Name    string
 	Queries []Query
+	Checks  []Check
+	Compare *Compare
 }
 
 // Query represents a playbook query.
 type Query struct {
 	Name, File string
 	Template   bool
+	Assert     *Assertion
+
+	Labels          map[string]string      // Merged into the job labels of the target
+	QueryParameters map[string]interface{} `yaml:"query_parameters"` // Merged into the query parameters of the target
 }
 
 // NewPlaybook initializes properly the Playbook.
@@ -68,5 +120,32 @@ func (p Playbook) Validate() error {
 		return fmt.Errorf("no steps")
 	}
 
+	for _, target := range p.Targets {
+		if err := validateTarget(target); err != nil {
+			return fmt.Errorf("target %s: %s", target.Name, err)
+		}
+	}
+
+	for _, step := range p.Steps {
+		if step.Compare != nil {
+			if err := step.Compare.Validate(p.Targets); err != nil {
+				return fmt.Errorf("compare %s in step %s: %s", step.Compare.Name, step.Name, err)
+			}
+		}
+		for _, check := range step.Checks {
+			if err := check.Validate(); err != nil {
+				return fmt.Errorf("check %s in step %s: %s", check.DisplayName(), step.Name, err)
+			}
+		}
+		for _, query := range step.Queries {
+			if query.Assert == nil {
+				continue
+			}
+			if err := query.Assert.Validate(); err != nil {
+				return fmt.Errorf("query %s in step %s: %s", query.Name, step.Name, err)
+			}
+		}
+	}
+
 	return nil
 }
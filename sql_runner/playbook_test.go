@@ -110,6 +110,52 @@ func TestValidate(t *testing.T) {
 			IsValid:   true,
 			ErrString: "",
 		},
+		{
+			Name: "invalid_assert",
+			Play: Playbook{
+				Targets: make([]Target, 1),
+				Steps: []Step{
+					{
+						Name:    "foo",
+						Queries: []Query{{Name: "bar", Assert: &Assertion{}}},
+					},
+				},
+			},
+			IsValid:   false,
+			ErrString: "query bar in step foo: assert has no expectations",
+		},
+		{
+			Name: "invalid_compare",
+			Play: Playbook{
+				Targets: []Target{{Name: "a"}},
+				Steps: []Step{
+					{
+						Name:    "foo",
+						Compare: &Compare{Name: "bar", File: "bar.sql", Targets: []string{"a", "b"}},
+					},
+				},
+			},
+			IsValid:   false,
+			ErrString: "compare bar in step foo: compare target b is not in the playbook",
+		},
+		{
+			Name: "invalid_snowflake_target",
+			Play: Playbook{
+				Targets: []Target{{Name: "sf", Type: snowflakeType, Account: "acme", Username: "runner", Async: &SnowflakeAsync{Backoff: 0.5}}},
+				Steps:   make([]Step, 1),
+			},
+			IsValid:   false,
+			ErrString: "target sf: async backoff must be at least 1",
+		},
+		{
+			Name: "snowflake_secret_reference",
+			Play: Playbook{
+				Targets: []Target{{Name: "sf", Type: snowflakeType, Account: "acme", Username: "runner", Port: "env:SNOWFLAKE_PORT"}},
+				Steps:   make([]Step, 1),
+			},
+			IsValid:   true,
+			ErrString: "",
+		},
 	}
 
 	for _, tt := range testCases {
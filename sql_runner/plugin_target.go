@@ -0,0 +1,473 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Plugin targets run an external executable which speaks JSON-RPC 2.0
+// over its stdin and stdout, one message per line. Its stderr is passed
+// through to sql-runner's. The methods are:
+//
+//	connect       {"target": {...}}                      -> anything
+//	ping          {}                                     -> anything
+//	run_query     {"name", "path", "script"}             -> {"affected": n}
+//	fetch_results {"name", "path", "script"}             -> {"result_sets": [...]}
+//	cancel        {"id": id of the request to cancel}    (notification)
+//
+// A result set is {"columns": [{"name", "type"}], "rows": [[...]]}, where
+// type is the database type name of the column. Timestamps are sent as
+// RFC 3339 strings and bytes as base64 strings.
+//
+// Queries of a step are sent concurrently, so responses may come back
+// in any order. The plugin should exit when its stdin is closed, or it
+// is killed after a grace period. A response line may be up to 64MB.
+const (
+	pluginPrefix = "sql-runner-target-" // Executable of a plugin target type on PATH
+
+	pluginJSONRPC = "2.0"
+
+	pluginConnect      = "connect"
+	pluginPing         = "ping"
+	pluginRunQuery     = "run_query"
+	pluginFetchResults = "fetch_results"
+	pluginCancel       = "cancel"
+)
+
+// Limits of a plugin process, variables so that tests can lower them
+var (
+	pluginMaxLine     = 64 * 1024 * 1024 // Bytes of a response line
+	pluginExitTimeout = 10 * time.Second // Wait for the plugin to exit once its stdin is closed
+)
+
+// PluginTarget represents a target served by a plugin executable.
+type PluginTarget struct {
+	Target
+	Client *pluginClient
+	ctx    context.Context
+}
+
+// IsConnectable tests connection to determine whether the plugin target is
+// connectable.
+func (pt PluginTarget) IsConnectable() bool {
+	return pt.Client.call(pt.ctx, pluginPing, struct{}{}, nil) == nil
+}
+
+func init() {
+	registerTarget(NewPluginTarget, pluginType)
+}
+
+// NewPluginTarget returns a ptr to a PluginTarget, starting its plugin
+// and handing it the target. The plugin is either given by the plugin
+// field or, for any other type, found on PATH as sql-runner-target-<type>.
+func NewPluginTarget(target Target) (*PluginTarget, error) {
+	if err := rejectPool(target); err != nil {
+		return nil, err
+	}
+
+	path := target.Plugin
+	if path == "" && !strings.EqualFold(target.Type, pluginType) {
+		path = pluginPrefix + strings.ToLower(target.Type)
+	}
+	if path == "" {
+		return nil, fmt.Errorf("missing target plugin")
+	}
+
+	path, err := exec.LookPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := startPlugin(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := interruptContext()
+	params := pluginConnectParams{Target: pluginTargetParams{
+		Name:     target.Name,
+		Type:     target.Type,
+		Host:     target.Host,
+		Port:     target.Port,
+		Database: target.Database,
+		Username: target.Username,
+		Password: target.Password,
+		Ssl:      target.Ssl,
+		Params:   target.Params,
+	}}
+	if err := client.call(ctx, pluginConnect, params, nil); err != nil {
+		client.close()
+		return nil, fmt.Errorf("plugin %s: %s", path, err)
+	}
+
+	return &PluginTarget{target, client, ctx}, nil
+}
+
+// GetTarget returns the Target field of PluginTarget.
+func (pt PluginTarget) GetTarget() Target {
+	return pt.Target
+}
+
+// Close asks the plugin to exit and waits for it.
+func (pt PluginTarget) Close() error {
+	pt.Client.close()
+	return nil
+}
+
+// RunQuery runs a query against the target.
+func (pt PluginTarget) RunQuery(query ReadyQuery, dryRun bool, showQueryOutput bool) QueryStatus {
+	return runScript(query, dryRun, showQueryOutput, pt.FetchResults, func() (int, error) {
+		var result pluginRunResult
+		err := pt.Client.call(pt.ctx, pluginRunQuery, newPluginQueryParams(query), &result)
+		return result.Affected, err
+	})
+}
+
+// FetchResults runs a query and returns its result sets.
+func (pt PluginTarget) FetchResults(query ReadyQuery) ([]ResultSet, error) {
+	var result pluginFetchResult
+	if err := pt.Client.call(pt.ctx, pluginFetchResults, newPluginQueryParams(query), &result); err != nil {
+		return nil, err
+	}
+
+	sets := make([]ResultSet, 0, len(result.ResultSets))
+	for _, prs := range result.ResultSets {
+		rs, err := prs.resultSet()
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, rs)
+	}
+	return sets, nil
+}
+
+// --- Protocol messages
+
+type pluginTargetParams struct {
+	Name     string            `json:"name"`
+	Type     string            `json:"type"`
+	Host     string            `json:"host,omitempty"`
+	Port     string            `json:"port,omitempty"`
+	Database string            `json:"database,omitempty"`
+	Username string            `json:"username,omitempty"`
+	Password string            `json:"password,omitempty"`
+	Ssl      bool              `json:"ssl"`
+	Params   map[string]string `json:"params,omitempty"`
+}
+
+type pluginConnectParams struct {
+	Target pluginTargetParams `json:"target"`
+}
+
+type pluginQueryParams struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Script string `json:"script"`
+}
+
+func newPluginQueryParams(query ReadyQuery) pluginQueryParams {
+	return pluginQueryParams{query.Name, query.Path, query.Script}
+}
+
+type pluginCancelParams struct {
+	ID uint64 `json:"id"`
+}
+
+type pluginRunResult struct {
+	Affected int `json:"affected"`
+}
+
+type pluginFetchResult struct {
+	ResultSets []pluginResultSet `json:"result_sets"`
+}
+
+type pluginColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type pluginResultSet struct {
+	Columns []pluginColumn  `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// resultSet converts the JSON values of a plugin result set to those
+// documented on ResultSet.
+func (prs pluginResultSet) resultSet() (ResultSet, error) {
+	columns := make([]Column, len(prs.Columns))
+	for i, pc := range prs.Columns {
+		columns[i] = Column{
+			Name:         pc.Name,
+			DatabaseType: pc.Type,
+			Kind:         kindFromDatabaseType(pc.Type, 0),
+		}
+	}
+
+	rs := ResultSet{Columns: columns, Rows: make([][]interface{}, 0, len(prs.Rows))}
+	for _, values := range prs.Rows {
+		if len(values) != len(columns) {
+			return ResultSet{}, fmt.Errorf("plugin returned a row of %d values for %d columns", len(values), len(columns))
+		}
+		row := make([]interface{}, len(columns))
+		for i, value := range values {
+			row[i] = normalizeValue(pluginValue(value, columns[i]), columns[i].Kind)
+		}
+		rs.Rows = append(rs.Rows, row)
+	}
+	return rs, nil
+}
+
+// pluginValue converts the JSON encoded values of a plugin. Numbers are
+// kept as text, so that parseValue reads them according to the column.
+func pluginValue(value interface{}, column Column) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		return v.String()
+	case string:
+		switch column.Kind {
+		case KindBytes:
+			if b, err := base64.StdEncoding.DecodeString(v); err == nil {
+				return b
+			}
+		case KindTimestamp:
+			if ts, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				return ts
+			}
+		}
+	}
+	return value
+}
+
+type pluginRequest struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      *uint64     `json:"id,omitempty"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type pluginResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *uint64         `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *pluginError    `json:"error"`
+}
+
+// decode returns the error of a response, or decodes its result into
+// result, unless it is nil.
+func (resp pluginResponse) decode(result interface{}) error {
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result == nil || len(resp.Result) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(resp.Result))
+	decoder.UseNumber()
+	return decoder.Decode(result)
+}
+
+type pluginError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (pe *pluginError) Error() string {
+	return pe.Message
+}
+
+// --- Plugin process
+
+// pluginClient sends requests to a plugin process and routes its
+// responses back to the waiting callers.
+type pluginClient struct {
+	path  string
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan pluginResponse
+
+	done    chan struct{}
+	exitErr error
+}
+
+// startPlugin starts the plugin executable and reads its responses
+// until it exits.
+func startPlugin(path string) (*pluginClient, error) {
+	cmd := exec.Command(path)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	pc := &pluginClient{
+		path:    path,
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[uint64]chan pluginResponse),
+		done:    make(chan struct{}),
+	}
+	go pc.read(stdout)
+	return pc, nil
+}
+
+// read dispatches each response line to its caller. Once the plugin
+// closes its stdout, the process is waited for and callers still
+// waiting are released. A plugin sending a line over the limit is
+// killed, as its responses can no longer be told apart.
+func (pc *pluginClient) read(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, min(64*1024, pluginMaxLine)), pluginMaxLine)
+	for scanner.Scan() {
+		decoder := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		decoder.UseNumber()
+		var resp pluginResponse
+		if err := decoder.Decode(&resp); err != nil || resp.ID == nil {
+			log.Printf("WARNING: Ignoring unexpected output of plugin %s: %s", pc.path, scanner.Text())
+			continue
+		}
+
+		pc.mu.Lock()
+		ch, ok := pc.pending[*resp.ID]
+		delete(pc.pending, *resp.ID)
+		pc.mu.Unlock()
+		if ok {
+			ch <- resp
+		}
+	}
+
+	scanErr := scanner.Err()
+	if scanErr == bufio.ErrTooLong {
+		scanErr = fmt.Errorf("a response is longer than %d bytes", pluginMaxLine)
+	}
+	if scanErr != nil {
+		log.Printf("ERROR: Stopping plugin %s: %s.", pc.path, scanErr)
+		pc.cmd.Process.Kill()
+		// Unread output would block the plugin, and so the wait
+		io.Copy(io.Discard, stdout)
+	}
+
+	err := pc.cmd.Wait()
+	if scanErr != nil {
+		err = scanErr
+	}
+	if err == nil {
+		err = fmt.Errorf("plugin %s exited", pc.path)
+	} else {
+		err = fmt.Errorf("plugin %s exited: %s", pc.path, err)
+	}
+	pc.mu.Lock()
+	pc.exitErr = err
+	pc.mu.Unlock()
+	close(pc.done)
+}
+
+// call sends a request and decodes its result into result, unless it
+// is nil. Cancelling the context asks the plugin to cancel the request,
+// which is then still waited for, so that the plugin reports how far
+// it got.
+func (pc *pluginClient) call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	ch := make(chan pluginResponse, 1)
+	pc.mu.Lock()
+	pc.nextID++
+	id := pc.nextID
+	pc.pending[id] = ch
+	pc.mu.Unlock()
+
+	if err := pc.send(pluginRequest{pluginJSONRPC, &id, method, params}); err != nil {
+		pc.mu.Lock()
+		delete(pc.pending, id)
+		pc.mu.Unlock()
+		return pc.exitError(err)
+	}
+
+	cancelled := ctx.Done()
+	for {
+		select {
+		case resp := <-ch:
+			return resp.decode(result)
+		case <-cancelled:
+			cancelled = nil
+			pc.send(pluginRequest{pluginJSONRPC, nil, pluginCancel, pluginCancelParams{id}})
+		case <-pc.done:
+			// The response may have been read just before the plugin exited
+			select {
+			case resp := <-ch:
+				return resp.decode(result)
+			default:
+				return pc.exitError(nil)
+			}
+		}
+	}
+}
+
+// send writes a message as a single line.
+func (pc *pluginClient) send(req pluginRequest) error {
+	line, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	pc.writeMu.Lock()
+	defer pc.writeMu.Unlock()
+	_, err = pc.stdin.Write(append(line, '\n'))
+	return err
+}
+
+// exitError returns the reason the plugin exited, falling back to err
+// while it is still running.
+func (pc *pluginClient) exitError(err error) error {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if pc.exitErr != nil {
+		return pc.exitErr
+	}
+	return err
+}
+
+// close closes the stdin of the plugin, asking it to exit, and waits
+// for it to do so. A plugin still running after the grace period is
+// killed.
+func (pc *pluginClient) close() {
+	pc.stdin.Close()
+	select {
+	case <-pc.done:
+		return
+	case <-time.After(pluginExitTimeout):
+	}
+
+	log.Printf("WARNING: Plugin %s did not exit after %s, killing it", pc.path, pluginExitTimeout)
+	pc.cmd.Process.Kill()
+	<-pc.done
+}
@@ -0,0 +1,309 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// buildReferencePlugin builds testdata/reference_plugin into dir,
+// under the given name.
+func buildReferencePlugin(t *testing.T, dir string, name string) string {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go is needed to build the reference plugin")
+	}
+
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	path := filepath.Join(dir, name)
+	cmd := exec.Command(goBin, "build", "-o", path, "./testdata/reference_plugin")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("cannot build reference plugin: %s\n%s", err, out)
+	}
+	return path
+}
+
+func referencePluginTarget(t *testing.T) Target {
+	return Target{
+		Name:     "plugin",
+		Type:     pluginType,
+		Host:     "localhost",
+		Database: "sql_runner_tests",
+		Plugin:   buildReferencePlugin(t, t.TempDir(), "reference_plugin"),
+	}
+}
+
+func TestNewPluginTarget_Error(t *testing.T) {
+	testCases := []struct {
+		Name      string
+		Input     Target
+		ErrString string
+	}{
+		{
+			Name: "missing_plugin",
+			Input: Target{
+				Type: pluginType,
+			},
+			ErrString: "missing target plugin",
+		},
+		{
+			Name: "not_found",
+			Input: Target{
+				Type:   pluginType,
+				Plugin: "./sql-runner-target-missing",
+			},
+			ErrString: "no such file or directory",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			result, err := NewPluginTarget(tt.Input)
+			assert.Nil(result)
+			if err == nil {
+				t.Fatalf("expected error, got nil")
+			}
+
+			assert.Contains(err.Error(), tt.ErrString)
+		})
+	}
+}
+
+func TestNewPluginTarget_ConnectError(t *testing.T) {
+	assert := assert.New(t)
+
+	target := referencePluginTarget(t)
+	target.Database = ""
+	result, err := NewPluginTarget(target)
+	assert.Nil(result)
+	if assert.NotNil(err) {
+		assert.Contains(err.Error(), "missing database")
+	}
+}
+
+func TestPluginTarget_RunQuery(t *testing.T) {
+	assert := assert.New(t)
+
+	plugin, err := NewPluginTarget(referencePluginTarget(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Client.close()
+	assert.True(plugin.IsConnectable())
+
+	status := plugin.RunQuery(ReadyQuery{Script: "INSERT INTO t VALUES (1);\nINSERT INTO t VALUES (2);", Name: "insert", Path: "insert.sql"}, false, false)
+	assert.Nil(status.Error)
+	assert.Equal(2, status.Affected)
+
+	sets, err := plugin.FetchResults(ReadyQuery{Script: "SELECT n, s, ts FROM t;"})
+	assert.Nil(err)
+	if assert.Len(sets, 1) {
+		assert.Equal([]string{"n", "s", "ts"}, sets[0].Header())
+		assert.Equal(KindInteger, sets[0].Columns[0].Kind)
+		assert.Equal(KindTimestamp, sets[0].Columns[2].Kind)
+		assert.Equal([][]interface{}{
+			{int64(1), "a", time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)},
+			{int64(2), nil, nil},
+		}, sets[0].Rows)
+	}
+
+	status = plugin.RunQuery(ReadyQuery{Script: "SELECT n FROM t;", Name: "select", Path: "select.sql"}, false, true)
+	assert.Nil(status.Error)
+	assert.Equal(2, status.Affected)
+
+	status = plugin.RunQuery(ReadyQuery{Script: "FAIL;", Name: "fail", Path: "fail.sql"}, false, false)
+	if assert.NotNil(status.Error) {
+		assert.Equal(`syntax error at "FAIL"`, status.Error.Error())
+	}
+
+	status = plugin.RunQuery(ReadyQuery{Script: "FAIL;", Name: "fail", Path: "fail.sql"}, true, false)
+	assert.Nil(status.Error)
+}
+
+func TestPluginTarget_Unreachable(t *testing.T) {
+	assert := assert.New(t)
+
+	target := referencePluginTarget(t)
+	target.Host = "unreachable"
+	plugin, err := NewPluginTarget(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Client.close()
+	assert.False(plugin.IsConnectable())
+}
+
+func TestPluginTarget_Cancel(t *testing.T) {
+	assert := assert.New(t)
+
+	plugin, err := NewPluginTarget(referencePluginTarget(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Client.close()
+	ctx, cancel := context.WithCancel(context.Background())
+	plugin.ctx = ctx
+
+	done := make(chan QueryStatus)
+	go func() {
+		done <- plugin.RunQuery(ReadyQuery{Script: "SLEEP;", Name: "sleep", Path: "sleep.sql"}, false, false)
+	}()
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	select {
+	case status := <-done:
+		if assert.NotNil(status.Error) {
+			assert.Equal("query sleep was cancelled", status.Error.Error())
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("query was not cancelled")
+	}
+}
+
+func TestPluginTarget_Exited(t *testing.T) {
+	assert := assert.New(t)
+
+	plugin, err := NewPluginTarget(referencePluginTarget(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugin.Client.close()
+
+	assert.False(plugin.IsConnectable())
+	status := plugin.RunQuery(ReadyQuery{Script: "SELECT 1;", Name: "select", Path: "select.sql"}, false, false)
+	if assert.NotNil(status.Error) {
+		assert.Contains(status.Error.Error(), "exited")
+	}
+}
+
+// scriptPlugin writes a shell script to be started as a plugin.
+func scriptPlugin(t *testing.T, script string) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin scripts need a shell")
+	}
+	path := filepath.Join(t.TempDir(), "plugin.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPluginClient_Kill(t *testing.T) {
+	defer func(timeout time.Duration, maxLine int) {
+		pluginExitTimeout, pluginMaxLine = timeout, maxLine
+	}(pluginExitTimeout, pluginMaxLine)
+	pluginExitTimeout, pluginMaxLine = 100*time.Millisecond, 64*1024
+
+	testCases := []struct {
+		Name      string
+		Script    string
+		ErrString string
+	}{
+		// Ignores its closed stdin
+		{"exit timeout", "exec sleep 60", "exited: signal: killed"},
+		// Keeps writing after a line over the limit
+		{"line too long", "head -c 1000000 /dev/zero | tr '\\0' x\nexec sleep 60", "exited: a response is longer than 65536 bytes"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			pc, err := startPlugin(scriptPlugin(t, tt.Script))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			closed := make(chan struct{})
+			go func() {
+				pc.close()
+				close(closed)
+			}()
+			select {
+			case <-closed:
+			case <-time.After(10 * time.Second):
+				t.Fatal("plugin was not killed")
+			}
+			assert.ErrorContains(pc.exitError(nil), tt.ErrString)
+		})
+	}
+}
+
+func TestConstructorFor(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	buildReferencePlugin(t, dir, pluginPrefix+"fake")
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	_, ok := constructorFor(Target{Type: "FAKE"})
+	assert.True(ok)
+	_, ok = constructorFor(Target{Type: "oracle"})
+	assert.False(ok)
+	_, ok = constructorFor(Target{Type: "oracle", Plugin: "/opt/sql-runner-target-oracle"})
+	assert.True(ok)
+
+	constructor, _ := constructorFor(Target{Type: "fake"})
+	database, err := constructor(Target{Name: "fake", Type: "fake", Database: "sql_runner_tests"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.(*PluginTarget).Client.close()
+	assert.True(database.IsConnectable())
+}
+
+func TestRun_Plugin(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	for name, script := range map[string]string{
+		"insert.sql": "INSERT INTO t VALUES (1);\nINSERT INTO t VALUES (2);",
+		"select.sql": "SELECT n, s, ts FROM t;",
+		"fail.sql":   "FAIL;",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pb := Playbook{
+		Targets: []Target{referencePluginTarget(t)},
+		Steps: []Step{
+			{Name: "insert", Queries: []Query{{Name: "insert", File: "insert.sql"}, {Name: "select", File: "select.sql"}}},
+		},
+	}
+	sp := NewFileSQLProvider(dir)
+
+	statuses := Run(pb, sp, "", "", false, false, false, false, lineProgress{})
+	code, message := review(statuses)
+	assert.Equal(0, code, message)
+
+	statuses = Run(pb, sp, "", "", true, false, false, false, lineProgress{})
+	code, message = review(statuses)
+	assert.Equal(0, code, message)
+
+	pb.Steps = append(pb.Steps, Step{Name: "fail", Queries: []Query{{Name: "fail", File: "fail.sql"}}})
+	statuses = Run(pb, sp, "", "", false, false, false, false, lineProgress{})
+	code, _ = review(statuses)
+	assert.Equal(6, code)
+}
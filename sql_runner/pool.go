@@ -0,0 +1,77 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Idle connections kept by database/sql, unless configured otherwise
+const sqlDefaultMaxIdleConns = 2
+
+// validatePool checks the pooling options of a target.
+func validatePool(target Target) error {
+	if target.MaxOpenConns < 0 || target.MaxIdleConns < 0 || target.ConnMaxLifetime < 0 {
+		return fmt.Errorf("max_open_conns, max_idle_conns and conn_max_lifetime cannot be negative")
+	}
+	if target.MaxOpenConns > 0 && target.MaxIdleConns > target.MaxOpenConns {
+		return fmt.Errorf("max_idle_conns cannot exceed max_open_conns")
+	}
+	return nil
+}
+
+// rejectPool fails for targets which do not pool connections, but set
+// pooling options.
+func rejectPool(target Target) error {
+	if target.MaxOpenConns != 0 || target.MaxIdleConns != 0 || target.ConnMaxLifetime != 0 {
+		return fmt.Errorf("max_open_conns, max_idle_conns and conn_max_lifetime are not supported by %s targets", strings.ToLower(target.Type))
+	}
+	return nil
+}
+
+// configurePool sizes the pool of a database/sql client. Options left
+// unset keep the defaults of database/sql.
+func configurePool(db *sql.DB, target Target) error {
+	if err := validatePool(target); err != nil {
+		return err
+	}
+	if target.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(target.MaxOpenConns)
+	}
+	db.SetMaxIdleConns(maxIdleConns(target))
+	if target.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(time.Duration(target.ConnMaxLifetime) * time.Second)
+	}
+	return nil
+}
+
+// maxIdleConns returns the idle connections kept by a target.
+func maxIdleConns(target Target) int {
+	if target.MaxIdleConns > 0 {
+		return target.MaxIdleConns
+	}
+	return sqlDefaultMaxIdleConns
+}
+
+// checkSQLHealth pings a database/sql client between steps. When the
+// ping fails, the idle connections, which may all have died during a
+// long step, are closed so that the next step opens new ones.
+func checkSQLHealth(db *sql.DB, target Target) error {
+	if err := db.Ping(); err == nil {
+		return nil
+	}
+	db.SetMaxIdleConns(0)
+	db.SetMaxIdleConns(maxIdleConns(target))
+	return db.Ping()
+}
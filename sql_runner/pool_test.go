@@ -0,0 +1,101 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatePool(t *testing.T) {
+	testCases := []struct {
+		Name      string
+		Input     Target
+		ErrString string
+	}{
+		{"defaults", Target{}, ""},
+		{"sized", Target{MaxOpenConns: 8, MaxIdleConns: 4, ConnMaxLifetime: 300}, ""},
+		{"unbounded", Target{MaxIdleConns: 4}, ""},
+		{"negative open", Target{MaxOpenConns: -1}, "max_open_conns, max_idle_conns and conn_max_lifetime cannot be negative"},
+		{"negative lifetime", Target{ConnMaxLifetime: -1}, "max_open_conns, max_idle_conns and conn_max_lifetime cannot be negative"},
+		{"idle over open", Target{MaxOpenConns: 2, MaxIdleConns: 4}, "max_idle_conns cannot exceed max_open_conns"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			err := validatePool(tt.Input)
+			if tt.ErrString == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, tt.ErrString)
+			}
+		})
+	}
+}
+
+func TestNewTarget_PoolError(t *testing.T) {
+	testCases := []struct {
+		Name      string
+		Input     Target
+		ErrString string
+	}{
+		{
+			Name:      "generic",
+			Input:     Target{Name: "generic", Type: genericType, Driver: "sqlite", Dsn: memoryPath, MaxOpenConns: -1},
+			ErrString: "max_open_conns, max_idle_conns and conn_max_lifetime cannot be negative",
+		},
+		{
+			Name:      "sqlite",
+			Input:     Target{Name: "lite", Type: sqliteType, Path: memoryPath, MaxOpenConns: 4},
+			ErrString: "sqlite targets use a single connection, max_open_conns cannot exceed 1",
+		},
+		{
+			Name:      "postgres",
+			Input:     Target{Name: "pg", Type: postgresType, Host: "localhost", Port: "5432", Username: "postgres", Database: "postgres", MaxIdleConns: 2},
+			ErrString: "max_idle_conns is not supported by postgres targets",
+		},
+		{
+			Name:      "bigquery",
+			Input:     Target{Name: "bq", Type: bigqueryType, Project: "acme", MaxOpenConns: 4},
+			ErrString: "max_open_conns, max_idle_conns and conn_max_lifetime are not supported by bigquery targets",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			constructor, ok := constructorFor(tt.Input)
+			if !ok {
+				t.Fatalf("no constructor for %s", tt.Input.Type)
+			}
+			database, err := constructor(tt.Input)
+			assert.Nil(t, database)
+			assert.EqualError(t, err, tt.ErrString)
+		})
+	}
+}
+
+func TestConfigurePool(t *testing.T) {
+	assert := assert.New(t)
+
+	st, err := NewGenericTarget(Target{Name: "generic", Type: genericType, Driver: "sqlite", Dsn: memoryPath, MaxOpenConns: 4, MaxIdleConns: 2, ConnMaxLifetime: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(4, st.Client.Stats().MaxOpenConnections)
+
+	assert.Nil(st.CheckHealth())
+	assert.Equal(1, st.Client.Stats().Idle)
+
+	assert.Nil(st.Close())
+	assert.False(st.IsConnectable())
+	assert.NotNil(st.CheckHealth())
+}
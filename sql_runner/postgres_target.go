@@ -12,17 +12,14 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
-	"errors"
 	"fmt"
 	"log"
-	"net"
-	"os"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/go-pg/pg/v10"
 	"github.com/go-pg/pg/v10/orm"
-	"github.com/olekukonko/tablewriter"
 )
 
 // For Redshift queries
@@ -31,35 +28,79 @@ const (
 	readTimeout = 8 * time.Hour // TODO: make this user configurable
 )
 
+// validationLockTimeout bounds how long ValidateQuery waits for, and so
+// holds up others behind, the locks of the schema changes it runs.
+const validationLockTimeout = 10 * time.Second
+
+// postgresExplainable matches the statements which EXPLAIN, on both
+// Postgres and Redshift, plans without running them.
+var postgresExplainable = regexp.MustCompile(`(?is)^((select|insert|update|delete|values|with)\b|create\s+((local|global)\s+)?((temp|temporary|unlogged)\s+)?table\s.*\bas\s)`)
+
+// postgresCreateAs splits a CREATE TABLE AS statement before its query.
+var postgresCreateAs = regexp.MustCompile(`(?is)^(create\s.*?\bas\s+)(\(?\s*(select|with|values)\b.*)$`)
+
+// postgresValidationRun matches the schema changes and settings which
+// ValidateQuery runs, so that later statements see them.
+var postgresValidationRun = regexp.MustCompile(`(?is)^(create|alter|drop|comment|set)\b`)
+
+// postgresNonTransactional matches the statements which cannot run in
+// a transaction, including Redshift's ALTER TABLE APPEND and VACUUM.
+var postgresNonTransactional = regexp.MustCompile(`(?is)^((create|drop)\s+(database|tablespace|external)\b|(create|drop)\s+(unique\s+)?index\s+concurrently\b|alter\s+table\s+\S+\s+append\b|vacuum\b)`)
+
 // PostgresTarget represents a Postgres as target.
 type PostgresTarget struct {
 	Target
 	Client *pg.DB
+	auth   *redshiftAuth
+	tunnel *sshTunnel
+	lease  *vaultLease
 }
 
 // IsConnectable tests connection to determine whether the Postgres target is
 // connectable.
 func (pt PostgresTarget) IsConnectable() bool {
-	client := pt.Client
-	err := client.Ping(context.Background())
+	client, err := pt.client()
+	if err != nil {
+		return false
+	}
+	err = client.Ping(context.Background())
 
 	return err == nil
 }
 
+func init() {
+	registerTarget(NewPostgresTarget, redshiftType, postgresType, postgresqlType)
+}
+
 // NewPostgresTarget returns a ptr to a PostgresTarget.
 func NewPostgresTarget(target Target) (*PostgresTarget, error) {
-	var tlsConfig *tls.Config
-	if target.Ssl == true {
-		tlsConfig = &tls.Config{
-			InsecureSkipVerify: true,
-		}
+	tlsConfig, err := postgresTLSConfig(target)
+	if err != nil {
+		return nil, err
 	}
 
-	if target.Host == "" || target.Port == "" || target.Username == "" || target.Database == "" {
+	iam := usesRedshiftIAM(target)
+	leased := target.Vault != nil
+	if target.Host == "" || target.Port == "" || (target.Username == "" && !iam && !leased) || target.Database == "" {
 		return nil, fmt.Errorf("missing target connection parameters")
 	}
+	if leased && (iam || target.Password != "") {
+		return nil, fmt.Errorf("vault credentials cannot be combined with a password or iam authentication")
+	}
+
+	if err := validatePool(target); err != nil {
+		return nil, err
+	}
+	if target.MaxIdleConns != 0 {
+		return nil, fmt.Errorf("max_idle_conns is not supported by %s targets", strings.ToLower(target.Type))
+	}
 
-	db := pg.Connect(&pg.Options{
+	dialer, tunnel, err := dialerFromTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	options := pg.Options{
 		Addr:        fmt.Sprintf("%s:%s", target.Host, target.Port),
 		User:        target.Username,
 		Password:    target.Password,
@@ -67,16 +108,44 @@ func NewPostgresTarget(target Target) (*PostgresTarget, error) {
 		TLSConfig:   tlsConfig,
 		DialTimeout: dialTimeout,
 		ReadTimeout: readTimeout,
-		Dialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			cn, err := net.DialTimeout(network, addr, dialTimeout)
-			if err != nil {
-				return nil, err
-			}
-			return cn, cn.(*net.TCPConn).SetKeepAlive(true)
-		},
-	})
+		Dialer:      dialer,
+		PoolSize:    target.MaxOpenConns,
+		MaxConnAge:  time.Duration(target.ConnMaxLifetime) * time.Second,
+	}
 
-	return &PostgresTarget{target, db}, nil
+	if iam {
+		auth, err := newRedshiftAuth(target, options)
+		if err != nil {
+			return nil, err
+		}
+		db, err := auth.connect()
+		if err != nil {
+			return nil, err
+		}
+		return &PostgresTarget{target, db, auth, tunnel, nil}, nil
+	}
+
+	// The lease is taken last, as nothing can fail after it
+	if leased {
+		lease, err := newVaultLease(*target.Vault)
+		if err != nil {
+			return nil, err
+		}
+		options.User = lease.Username
+		options.Password = lease.Password
+		return &PostgresTarget{target, pg.Connect(&options), nil, tunnel, lease}, nil
+	}
+
+	return &PostgresTarget{target, pg.Connect(&options), nil, tunnel, nil}, nil
+}
+
+// client returns the client to run queries with. With IAM authentication,
+// it changes when the credentials are refreshed.
+func (pt PostgresTarget) client() (*pg.DB, error) {
+	if pt.auth == nil {
+		return pt.Client, nil
+	}
+	return pt.auth.connect()
 }
 
 // GetTarget returns the Target field of PostgresTarget.
@@ -84,75 +153,180 @@ func (pt PostgresTarget) GetTarget() Target {
 	return pt.Target
 }
 
+// Close closes the clients of the Postgres target, revokes its vault
+// lease and closes its SSH tunnel.
+func (pt PostgresTarget) Close() error {
+	var err error
+	if pt.auth != nil {
+		err = pt.auth.close()
+	} else {
+		err = pt.Client.Close()
+	}
+	if pt.lease != nil {
+		if leaseErr := pt.lease.revoke(); leaseErr != nil && err == nil {
+			err = leaseErr
+		}
+	}
+	if pt.tunnel != nil {
+		if tunnelErr := pt.tunnel.close(); tunnelErr != nil && err == nil {
+			err = tunnelErr
+		}
+	}
+	return err
+}
+
+// CheckHealth pings each idle connection of the Postgres target
+// between steps, as they may all have died during a long step. The
+// driver drops a connection failing its ping, so the next step opens
+// new ones.
+func (pt PostgresTarget) CheckHealth() error {
+	client, err := pt.client()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	// The connections are held until all are pinged, so that each
+	// ping takes another one from the pool
+	idle := int(client.PoolStats().IdleConns)
+	conns := make([]*pg.Conn, 0, idle)
+	for i := 0; i < idle; i++ {
+		conn := client.Conn()
+		conns = append(conns, conn)
+		conn.Ping(ctx)
+	}
+	for _, conn := range conns {
+		conn.Close()
+	}
+	return client.Ping(ctx)
+}
+
 // RunQuery runs a query against the target.
 func (pt PostgresTarget) RunQuery(query ReadyQuery, dryRun bool, showQueryOutput bool) QueryStatus {
 	var err error = nil
 	var res orm.Result
 	if dryRun {
-		options := pt.Client.Options()
-		address := options.Addr
-		if pt.IsConnectable() {
-			log.Printf("SUCCESS: Able to connect to target database, %s\n.", address)
-		} else {
-			log.Printf("ERROR: Cannot connect to target database, %s\n.", address)
-		}
-		return QueryStatus{query, query.Path, 0, nil}
+		return QueryStatus{Query: query, Path: query.Path}
 	}
 
 	affected := 0
 	if showQueryOutput {
-		var results Results
-		res, err = pt.Client.Query(&results, query.Script)
-		if err == nil {
-			affected = res.RowsAffected()
-		} else {
+		var rs ResultSet
+		rs, affected, err = pt.queryResults(query.Script)
+		if err != nil {
 			log.Printf("ERROR: %s.", err)
-			return QueryStatus{query, query.Path, int(affected), err}
+			return QueryStatus{Query: query, Path: query.Path, Affected: int(affected), Error: err}
 		}
 
-		err = printTable(&results)
+		err = printTable(rs)
 		if err != nil {
 			log.Printf("ERROR: %s.", err)
-			return QueryStatus{query, query.Path, int(affected), err}
+			return QueryStatus{Query: query, Path: query.Path, Affected: int(affected), Error: err}
 		}
 	} else {
-		res, err = pt.Client.Exec(query.Script)
+		var client *pg.DB
+		client, err = pt.client()
+		if err == nil {
+			res, err = client.Exec(query.Script)
+		}
 		if err == nil {
 			affected = res.RowsAffected()
 		}
 	}
 
-	return QueryStatus{query, query.Path, affected, err}
+	return QueryStatus{Query: query, Path: query.Path, Affected: affected, Error: err}
 }
 
-func printTable(results *Results) error {
-	columns := make([]string, len(results.columns))
-	for k := range results.columns {
-		columns[k] = results.columns[k]
+// ValidateQuery plans each statement of a query with EXPLAIN, which
+// reports syntax errors and missing relations without running it.
+// Schema changes are run instead, and tables created from a query are
+// created empty, so that later statements see them. Everything runs
+// in a transaction which is rolled back, and gives up on locks after
+// validationLockTimeout. Statements which are neither planned nor run,
+// such as VACUUM, are skipped.
+func (pt PostgresTarget) ValidateQuery(query ReadyQuery) QueryStatus {
+	client, err := pt.client()
+	if err != nil {
+		return QueryStatus{Query: query, Path: query.Path, Error: err}
+	}
+	tx, err := client.Begin()
+	if err != nil {
+		return QueryStatus{Query: query, Path: query.Path, Error: err}
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(postgresLockTimeout(pt.Type)); err != nil {
+		return QueryStatus{Query: query, Path: query.Path, Error: err}
 	}
 
-	if results.elements == 1 {
-		if results.results[0][0] == "" {
-			return nil // blank output, edge case for asserts
+	statements := splitStatements(query.Script, postgresDialect)
+	validated := 0
+	for i, statement := range statements {
+		checks := postgresValidation(statement)
+		for _, check := range checks {
+			if _, err := tx.Exec(check); err != nil {
+				log.Printf("ERROR: %s.", err)
+				return QueryStatus{Query: query, Path: query.Path, Error: fmt.Errorf("statement %d: %s", i+1, err)}
+			}
 		}
-	} else if results.elements == 0 {
-		return nil // break for no output
+		if len(checks) > 0 {
+			validated++
+		}
+	}
+	log.Printf("VALIDATED %d of %d statements of %s", validated, len(statements), query.Name)
+	return QueryStatus{Query: query, Path: query.Path}
+}
+
+// postgresLockTimeout returns the setting which bounds lock waits in
+// the validation transaction. Redshift has no lock_timeout, so its
+// statements are bounded instead.
+func postgresLockTimeout(targetType string) string {
+	millis := validationLockTimeout.Milliseconds()
+	if targetType == redshiftType {
+		return fmt.Sprintf("SET LOCAL statement_timeout = %d", millis)
 	}
+	return fmt.Sprintf("SET LOCAL lock_timeout = %d", millis)
+}
 
-	log.Printf("QUERY OUTPUT:\n")
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader(columns)
-	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
-	table.SetCenterSeparator("|")
+// postgresValidation returns what ValidateQuery runs for a statement.
+func postgresValidation(statement string) []string {
+	trimmed := trimLeadingComments(statement)
+	switch {
+	case postgresNonTransactional.MatchString(trimmed):
+		return nil
+	case postgresExplainable.MatchString(trimmed):
+		checks := []string{"EXPLAIN " + statement}
+		if parts := postgresCreateAs.FindStringSubmatch(trimmed); parts != nil {
+			// The table is created without running its query
+			checks = append(checks, parts[1]+"SELECT * FROM (\n"+parts[2]+"\n) AS validated LIMIT 0")
+		}
+		return checks
+	case postgresValidationRun.MatchString(trimmed):
+		return []string{statement}
+	}
+	return nil
+}
 
-	if len(results.columns) == 0 {
-		return errors.New("Unable to read columns")
+// FetchResults runs a query against the target and returns its output.
+func (pt PostgresTarget) FetchResults(query ReadyQuery) ([]ResultSet, error) {
+	rs, _, err := pt.queryResults(query.Script)
+	if err != nil {
+		return nil, err
 	}
+	return []ResultSet{rs}, nil
+}
 
-	for _, row := range results.results {
-		table.Append(row)
+// queryResults runs a script, returning the rows of its
+// last statement along with the number of rows affected.
+func (pt PostgresTarget) queryResults(script string) (ResultSet, int, error) {
+	client, err := pt.client()
+	if err != nil {
+		return ResultSet{}, 0, err
 	}
 
-	table.Render() // Send output
-	return nil
+	var results Results
+	res, err := client.Query(&results, script)
+	if err != nil {
+		return ResultSet{}, 0, err
+	}
+	return results.ResultSet(), res.RowsAffected(), nil
 }
@@ -102,3 +102,69 @@ func TestNewPostgresTarget(t *testing.T) {
 		})
 	}
 }
+
+func TestPostgresValidation(t *testing.T) {
+	testCases := []struct {
+		Input    string
+		Expected []string
+	}{
+		{"SELECT 1", []string{"EXPLAIN SELECT 1"}},
+		{"-- load\nINSERT INTO t SELECT 1", []string{"EXPLAIN -- load\nINSERT INTO t SELECT 1"}},
+		{"CREATE TABLE t (a int)", []string{"CREATE TABLE t (a int)"}},
+		{"DROP TABLE IF EXISTS t", []string{"DROP TABLE IF EXISTS t"}},
+		{"SET search_path TO s", []string{"SET search_path TO s"}},
+		{
+			"CREATE TABLE t AS\nWITH x AS (SELECT 1 AS a) SELECT a FROM x -- all",
+			[]string{
+				"EXPLAIN CREATE TABLE t AS\nWITH x AS (SELECT 1 AS a) SELECT a FROM x -- all",
+				"CREATE TABLE t AS\nSELECT * FROM (\nWITH x AS (SELECT 1 AS a) SELECT a FROM x -- all\n) AS validated LIMIT 0",
+			},
+		},
+		{"CREATE INDEX CONCURRENTLY i ON t (a)", nil},
+		{"CREATE DATABASE d", nil},
+		{"VACUUM t", nil},
+		{"vacuum delete only t", nil},
+		{"ALTER TABLE t APPEND FROM s", nil},
+		{"ALTER TABLE t ADD COLUMN b int", []string{"ALTER TABLE t ADD COLUMN b int"}},
+		{"COMMIT", nil},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Input, func(t *testing.T) {
+			assert.Equal(t, tt.Expected, postgresValidation(tt.Input))
+		})
+	}
+}
+
+func TestPostgresLockTimeout(t *testing.T) {
+	assert.Equal(t, "SET LOCAL lock_timeout = 10000", postgresLockTimeout(postgresType))
+	assert.Equal(t, "SET LOCAL statement_timeout = 10000", postgresLockTimeout(redshiftType))
+}
+
+func TestPostgresExplainable(t *testing.T) {
+	testCases := []struct {
+		Input    string
+		Expected bool
+	}{
+		{"SELECT 1", true},
+		{"insert into t values (1)", true},
+		{"UPDATE t SET a = 1", true},
+		{"DELETE FROM t", true},
+		{"VALUES (1)", true},
+		{"WITH x AS (SELECT 1) INSERT INTO t SELECT * FROM x", true},
+		{"CREATE TABLE t AS SELECT 1", true},
+		{"CREATE TEMP TABLE t\nAS\nSELECT 1", true},
+		{"CREATE TABLE t (a int)", false},
+		{"CREATE SCHEMA s", false},
+		{"DROP SCHEMA IF EXISTS s CASCADE", false},
+		{"CREATE VIEW v AS SELECT 1", false},
+		{"VACUUM t", false},
+		{"SELECTED", false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Input, func(t *testing.T) {
+			assert.Equal(t, tt.Expected, postgresExplainable.MatchString(tt.Input))
+		})
+	}
+}
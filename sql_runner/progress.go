@@ -0,0 +1,279 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+const (
+	progressRefresh = 250 * time.Millisecond
+	progressIndent  = "    "
+)
+
+// Progress is told about the state of a run as it happens.
+type Progress interface {
+	StepStarted(target string, index int, total int, step string)
+	QueryStarted(target string, query string)
+	QueryFinished(target string, status QueryStatus)
+	TargetFinished(target string)
+	Stop()
+}
+
+// NewProgress returns a live progress view when out is a
+// terminal, or keeps the line logging otherwise.
+func NewProgress(out *os.File, enabled bool) Progress {
+	if !enabled || !term.IsTerminal(int(out.Fd())) {
+		return lineProgress{}
+	}
+
+	width := func() int {
+		w, _, err := term.GetSize(int(out.Fd()))
+		if err != nil {
+			return 0
+		}
+		return w
+	}
+	return newLiveProgress(out, log.Writer(), width)
+}
+
+// lineProgress leaves reporting to the log lines
+// printed while running.
+type lineProgress struct{}
+
+func (lineProgress) StepStarted(target string, index int, total int, step string) {}
+func (lineProgress) QueryStarted(target string, query string)                     {}
+func (lineProgress) QueryFinished(target string, status QueryStatus)              {}
+func (lineProgress) TargetFinished(target string)                                 {}
+func (lineProgress) Stop()                                                        {}
+
+// targetProgress is the state of one target in the live view.
+type targetProgress struct {
+	name              string
+	step              string
+	index, total      int
+	running           map[string][]time.Time // Start times by query name, which may repeat
+	completed, failed int
+	finished          bool
+}
+
+// liveProgress redraws a block with the state of every target
+// below the log lines, which keep scrolling above it.
+type liveProgress struct {
+	mu      sync.Mutex
+	out     io.Writer
+	logOut  io.Writer
+	width   func() int
+	targets []*targetProgress
+	drawn   int
+	now     func() time.Time
+	stop    chan struct{}
+	stopped chan struct{}
+	prevLog io.Writer
+}
+
+// newLiveProgress starts redrawing and routes the log through the view.
+func newLiveProgress(out io.Writer, logOut io.Writer, width func() int) *liveProgress {
+	lp := &liveProgress{
+		out:     out,
+		logOut:  logOut,
+		width:   width,
+		now:     time.Now,
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+		prevLog: log.Writer(),
+	}
+	log.SetOutput(lp)
+
+	go func() {
+		defer close(lp.stopped)
+		ticker := time.NewTicker(progressRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				lp.mu.Lock()
+				lp.redraw()
+				lp.mu.Unlock()
+			case <-lp.stop:
+				return
+			}
+		}
+	}()
+	return lp
+}
+
+// Write prints log lines above the live view.
+func (lp *liveProgress) Write(p []byte) (int, error) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+
+	lp.clear()
+	n, err := lp.logOut.Write(p)
+	lp.draw()
+	return n, err
+}
+
+// StepStarted implements Progress.
+func (lp *liveProgress) StepStarted(target string, index int, total int, step string) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+
+	tp := lp.target(target)
+	tp.step, tp.index, tp.total = step, index, total
+	lp.redraw()
+}
+
+// QueryStarted implements Progress.
+func (lp *liveProgress) QueryStarted(target string, query string) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+
+	tp := lp.target(target)
+	tp.running[query] = append(tp.running[query], lp.now())
+	lp.redraw()
+}
+
+// QueryFinished implements Progress.
+func (lp *liveProgress) QueryFinished(target string, status QueryStatus) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+
+	tp := lp.target(target)
+	if started := tp.running[status.Query.Name]; len(started) > 1 {
+		tp.running[status.Query.Name] = started[1:]
+	} else {
+		delete(tp.running, status.Query.Name)
+	}
+	if status.Error != nil {
+		tp.failed++
+	} else {
+		tp.completed++
+	}
+	lp.redraw()
+}
+
+// TargetFinished implements Progress.
+func (lp *liveProgress) TargetFinished(target string) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+
+	tp := lp.target(target)
+	tp.finished = true
+	tp.running = make(map[string][]time.Time)
+	lp.redraw()
+}
+
+// Stop draws the final state and hands the log back.
+func (lp *liveProgress) Stop() {
+	close(lp.stop)
+	<-lp.stopped
+
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+
+	lp.redraw()
+	lp.drawn = 0
+	log.SetOutput(lp.prevLog)
+}
+
+// target returns the state of a target, registering it on first use.
+func (lp *liveProgress) target(name string) *targetProgress {
+	for _, tp := range lp.targets {
+		if tp.name == name {
+			return tp
+		}
+	}
+	tp := &targetProgress{name: name, running: make(map[string][]time.Time)}
+	lp.targets = append(lp.targets, tp)
+	return tp
+}
+
+// redraw replaces the live view with the current state.
+func (lp *liveProgress) redraw() {
+	lp.clear()
+	lp.draw()
+}
+
+// clear erases the lines drawn by the last draw.
+func (lp *liveProgress) clear() {
+	if lp.drawn > 0 {
+		fmt.Fprintf(lp.out, "\x1b[%dA\x1b[J", lp.drawn)
+		lp.drawn = 0
+	}
+}
+
+// draw prints the live view, cutting lines to the terminal
+// width so that wrapping does not break the next clear.
+func (lp *liveProgress) draw() {
+	lines := lp.render()
+	width := lp.width()
+
+	var block bytes.Buffer
+	for _, line := range lines {
+		block.WriteString(cutLine(line, width))
+		block.WriteString("\n")
+	}
+	lp.out.Write(block.Bytes())
+	lp.drawn = len(lines)
+}
+
+// cutLine cuts a line to fewer runes than the width, so that multibyte
+// characters are kept whole.
+func cutLine(line string, width int) string {
+	if width <= 0 || utf8.RuneCountInString(line) < width {
+		return line
+	}
+	runes := []rune(line)
+	return string(runes[:width-1])
+}
+
+// render returns the lines of the live view.
+func (lp *liveProgress) render() []string {
+	now := lp.now()
+	lines := make([]string, 0)
+
+	for _, tp := range lp.targets {
+		counts := fmt.Sprintf("COMPLETED: %d, FAILED: %d", tp.completed, tp.failed)
+		switch {
+		case tp.finished:
+			lines = append(lines, fmt.Sprintf("%s: FINISHED, %s", tp.name, counts))
+			continue
+		case tp.total == 0:
+			lines = append(lines, fmt.Sprintf("%s: STARTING, %s", tp.name, counts))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s: STEP %d/%d %s, %s", tp.name, tp.index, tp.total, tp.step, counts))
+
+		names := make([]string, 0, len(tp.running))
+		for name := range tp.running {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for _, started := range tp.running[name] {
+				elapsed := now.Sub(started).Truncate(time.Second)
+				lines = append(lines, fmt.Sprintf("%s%s (%s)", progressIndent, name, elapsed))
+			}
+		}
+	}
+	return lines
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewProgress_NotTerminal(t *testing.T) {
+	out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	assert.IsType(t, lineProgress{}, NewProgress(out, true))
+	assert.IsType(t, lineProgress{}, NewProgress(out, false))
+}
+
+func TestLiveProgress_Render(t *testing.T) {
+	assert := assert.New(t)
+
+	var out, logOut bytes.Buffer
+	lp := newLiveProgress(&out, &logOut, func() int { return 0 })
+	defer lp.Stop()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	lp.mu.Lock()
+	lp.now = func() time.Time { return start }
+	lp.mu.Unlock()
+
+	lp.StepStarted("db1", 2, 5, "Load")
+	lp.QueryStarted("db1", "load b")
+	lp.QueryStarted("db1", "load a")
+	lp.QueryStarted("db2", "noop")
+	lp.QueryFinished("db1", QueryStatus{Query: ReadyQuery{Name: "load b"}})
+	lp.TargetFinished("db2")
+
+	lp.mu.Lock()
+	lp.now = func() time.Time { return start.Add(90 * time.Second) }
+	lines := lp.render()
+	lp.mu.Unlock()
+
+	assert.Equal([]string{
+		"db1: STEP 2/5 Load, COMPLETED: 1, FAILED: 0",
+		progressIndent + "load a (1m30s)",
+		"db2: FINISHED, COMPLETED: 0, FAILED: 0",
+	}, lines)
+
+	lp.QueryFinished("db1", QueryStatus{Query: ReadyQuery{Name: "load a"}, Error: errors.New("boom")})
+	lp.mu.Lock()
+	assert.Equal("db1: STEP 2/5 Load, COMPLETED: 1, FAILED: 1", lp.render()[0])
+	lp.mu.Unlock()
+}
+
+func TestLiveProgress_Log(t *testing.T) {
+	assert := assert.New(t)
+
+	var out, logOut bytes.Buffer
+	lp := newLiveProgress(&out, &logOut, func() int { return 20 })
+
+	lp.StepStarted("a very long target name", 1, 1, "Step")
+	log.Print("hello")
+	lp.Stop()
+
+	assert.Contains(logOut.String(), "hello\n")
+	// The log line is written after clearing the single line view
+	assert.Contains(out.String(), "\x1b[1A\x1b[J")
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		line = strings.TrimPrefix(line, "\x1b[1A\x1b[J")
+		assert.True(len(line) < 20, line)
+	}
+}
+
+func TestCutLine(t *testing.T) {
+	testCases := []struct {
+		Line     string
+		Width    int
+		Expected string
+	}{
+		{"short", 0, "short"},
+		{"short", 6, "short"},
+		{"longer", 6, "longe"},
+		{"données chargées", 8, "données"},
+		{"表格表格", 3, "表格"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Line, func(t *testing.T) {
+			cut := cutLine(tt.Line, tt.Width)
+			assert.Equal(t, tt.Expected, cut)
+			assert.True(t, utf8.ValidString(cut))
+		})
+	}
+}
+
+func TestLiveProgress_DuplicateNames(t *testing.T) {
+	assert := assert.New(t)
+
+	var out, logOut bytes.Buffer
+	lp := newLiveProgress(&out, &logOut, func() int { return 0 })
+	defer lp.Stop()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	lp.mu.Lock()
+	lp.now = func() time.Time { return start }
+	lp.mu.Unlock()
+
+	lp.StepStarted("db", 1, 1, "Load")
+	lp.QueryStarted("db", "load")
+	lp.mu.Lock()
+	lp.now = func() time.Time { return start.Add(30 * time.Second) }
+	lp.mu.Unlock()
+	lp.QueryStarted("db", "load")
+
+	lp.mu.Lock()
+	lp.now = func() time.Time { return start.Add(90 * time.Second) }
+	assert.Equal([]string{
+		"db: STEP 1/1 Load, COMPLETED: 0, FAILED: 0",
+		progressIndent + "load (1m30s)",
+		progressIndent + "load (1m0s)",
+	}, lp.render())
+	lp.mu.Unlock()
+
+	// Each query of the name is still shown until it finishes
+	lp.QueryFinished("db", QueryStatus{Query: ReadyQuery{Name: "load"}})
+	lp.mu.Lock()
+	assert.Equal([]string{
+		"db: STEP 1/1 Load, COMPLETED: 1, FAILED: 0",
+		progressIndent + "load (1m0s)",
+	}, lp.render())
+	lp.mu.Unlock()
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	redactedSecret = "******"
+
+	// Shorter values would redact unrelated output
+	minSecretLength = 4
+)
+
+// secretRegistry records the secrets met while running, from
+// credential template functions, secret references and sensitive
+// variables, so that they are redacted from everything printed.
+type secretRegistry struct {
+	mu       sync.RWMutex
+	values   map[string]bool
+	replacer *strings.Replacer
+}
+
+// secrets is the registry of the run.
+var secrets = &secretRegistry{values: make(map[string]bool)}
+
+// add records a secret. Secrets shorter than minSecretLength are not
+// redacted, which is warned about.
+func (sr *secretRegistry) add(value string) {
+	if value == "" {
+		return
+	}
+	if len(value) < minSecretLength {
+		log.Printf("WARNING: A secret shorter than %d characters is not redacted from the output.", minSecretLength)
+		return
+	}
+
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	if sr.values[value] {
+		return
+	}
+	sr.values[value] = true
+
+	// Longer secrets first, so a secret containing another is
+	// redacted whole
+	values := make([]string, 0, len(sr.values))
+	for v := range sr.values {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+
+	pairs := make([]string, 0, 2*len(values))
+	for _, v := range values {
+		pairs = append(pairs, v, redactedSecret)
+	}
+	sr.replacer = strings.NewReplacer(pairs...)
+}
+
+// redact replaces the recorded secrets in s.
+func (sr *secretRegistry) redact(s string) string {
+	sr.mu.RLock()
+	defer sr.mu.RUnlock()
+
+	if sr.replacer == nil {
+		return s
+	}
+	return sr.replacer.Replace(s)
+}
+
+// registerSecret records a secret of the run.
+func registerSecret(value string) {
+	secrets.add(value)
+}
+
+// redactSecrets replaces the secrets of the run in s.
+func redactSecrets(s string) string {
+	return secrets.redact(s)
+}
+
+// registerSensitiveVariables records the values of the playbook
+// variables marked sensitive, including those given with -var.
+func registerSensitiveVariables(pb Playbook) {
+	for _, name := range pb.Sensitive {
+		if value, ok := pb.Variables[name]; ok {
+			registerSensitiveValue(value)
+		}
+	}
+}
+
+// registerSensitiveValue records a variable value, or every value
+// nested in it.
+func registerSensitiveValue(value interface{}) {
+	switch v := value.(type) {
+	case nil:
+	case string:
+		registerSecret(v)
+	case map[string]interface{}:
+		for _, nested := range v {
+			registerSensitiveValue(nested)
+		}
+	case []interface{}:
+		for _, nested := range v {
+			registerSensitiveValue(nested)
+		}
+	default:
+		registerSecret(fmt.Sprint(v))
+	}
+}
+
+// redactingWriter redacts the secrets of the run from what is
+// written through it.
+type redactingWriter struct {
+	out io.Writer
+}
+
+// Write implements io.Writer.
+func (rw redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(rw.out, redactSecrets(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,207 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/redshift"
+	"github.com/aws/aws-sdk-go/service/redshiftserverless"
+	"github.com/go-pg/pg/v10"
+)
+
+// Lifetime of temporary Redshift credentials
+const (
+	redshiftCredentialsDuration = time.Hour
+	redshiftRefreshMargin       = 5 * time.Minute
+)
+
+// redshiftCredentials are temporary database credentials.
+type redshiftCredentials struct {
+	User, Password string
+	Expiration     time.Time
+}
+
+// redshiftAuth connects to Redshift with temporary credentials, fetched
+// with IAM from GetClusterCredentials, or GetCredentials for Redshift
+// Serverless. The client is replaced with one using new credentials
+// before they expire, as they are only checked on connecting.
+type redshiftAuth struct {
+	options pg.Options
+	fetch   func() (redshiftCredentials, error)
+
+	mu          sync.Mutex
+	client      *pg.DB
+	credentials redshiftCredentials
+	retired     []*pg.DB // Clients which may still be running queries
+}
+
+// usesRedshiftIAM reports whether a target authenticates with IAM.
+func usesRedshiftIAM(target Target) bool {
+	return target.ClusterId != "" || target.Workgroup != ""
+}
+
+// newRedshiftAuth validates the IAM options of a target, returning
+// its redshiftAuth.
+func newRedshiftAuth(target Target, options pg.Options) (*redshiftAuth, error) {
+	if !strings.EqualFold(target.Type, redshiftType) {
+		return nil, fmt.Errorf("iam authentication is only supported by redshift targets")
+	}
+	if target.ClusterId != "" && target.Workgroup != "" {
+		return nil, fmt.Errorf("cluster_id and workgroup are mutually exclusive")
+	}
+	if target.Password != "" {
+		return nil, fmt.Errorf("password cannot be combined with iam authentication")
+	}
+
+	sess, err := awsSession(target)
+	if err != nil {
+		return nil, err
+	}
+
+	ra := &redshiftAuth{options: options}
+	if target.ClusterId != "" {
+		dbUser := target.DbUser
+		if dbUser == "" {
+			dbUser = target.Username
+		}
+		if dbUser == "" {
+			return nil, fmt.Errorf("missing target db_user")
+		}
+		ra.fetch = clusterCredentials(redshift.New(sess), target, dbUser)
+	} else {
+		ra.fetch = serverlessCredentials(redshiftserverless.New(sess), target)
+	}
+	return ra, nil
+}
+
+// awsSession returns the AWS session of a target, using its profile,
+// region and endpoint when given, and assuming its IAM role.
+func awsSession(target Target) (*session.Session, error) {
+	config := aws.Config{}
+	if target.Region != "" {
+		config.Region = aws.String(target.Region)
+	}
+	if target.AwsEndpoint != "" {
+		config.Endpoint = aws.String(target.AwsEndpoint)
+	}
+
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            config,
+		Profile:           target.Profile,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if target.IamRole != "" {
+		sess = sess.Copy(&aws.Config{Credentials: stscreds.NewCredentials(sess, target.IamRole)})
+	}
+	return sess, nil
+}
+
+// clusterCredentials fetches the credentials of a provisioned cluster,
+// for a database user which may be created on the fly.
+func clusterCredentials(client *redshift.Redshift, target Target, dbUser string) func() (redshiftCredentials, error) {
+	return func() (redshiftCredentials, error) {
+		out, err := client.GetClusterCredentials(&redshift.GetClusterCredentialsInput{
+			ClusterIdentifier: aws.String(target.ClusterId),
+			DbUser:            aws.String(dbUser),
+			DbName:            aws.String(target.Database),
+			AutoCreate:        aws.Bool(target.AutoCreate),
+			DurationSeconds:   aws.Int64(int64(redshiftCredentialsDuration.Seconds())),
+		})
+		if err != nil {
+			return redshiftCredentials{}, err
+		}
+		return redshiftCredentials{
+			User:       aws.StringValue(out.DbUser),
+			Password:   aws.StringValue(out.DbPassword),
+			Expiration: aws.TimeValue(out.Expiration),
+		}, nil
+	}
+}
+
+// serverlessCredentials fetches the credentials of a serverless
+// workgroup, for the database user mapped from the IAM identity.
+func serverlessCredentials(client *redshiftserverless.RedshiftServerless, target Target) func() (redshiftCredentials, error) {
+	return func() (redshiftCredentials, error) {
+		out, err := client.GetCredentials(&redshiftserverless.GetCredentialsInput{
+			WorkgroupName:   aws.String(target.Workgroup),
+			DbName:          aws.String(target.Database),
+			DurationSeconds: aws.Int64(int64(redshiftCredentialsDuration.Seconds())),
+		})
+		if err != nil {
+			return redshiftCredentials{}, err
+		}
+		return redshiftCredentials{
+			User:       aws.StringValue(out.DbUser),
+			Password:   aws.StringValue(out.DbPassword),
+			Expiration: aws.TimeValue(out.Expiration),
+		}, nil
+	}
+}
+
+// close closes the client, and those replaced by refreshes.
+func (ra *redshiftAuth) close() error {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+
+	var err error
+	clients := ra.retired
+	if ra.client != nil {
+		clients = append(clients, ra.client)
+	}
+	for _, client := range clients {
+		if closeErr := client.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	ra.client = nil
+	ra.retired = nil
+	return err
+}
+
+// connect returns a client with valid credentials, fetching new ones
+// when they are about to expire.
+func (ra *redshiftAuth) connect() (*pg.DB, error) {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+
+	if ra.client != nil && time.Until(ra.credentials.Expiration) > redshiftRefreshMargin {
+		return ra.client, nil
+	}
+
+	credentials, err := ra.fetch()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get redshift credentials: %s", err)
+	}
+	registerSecret(credentials.Password)
+
+	options := ra.options
+	options.User = credentials.User
+	options.Password = credentials.Password
+	if ra.client != nil {
+		log.Printf("Refreshed redshift credentials of %s, expiring at %s", credentials.User, credentials.Expiration.Format(time.RFC3339))
+		ra.retired = append(ra.retired, ra.client)
+	}
+	ra.client = pg.Connect(&options)
+	ra.credentials = credentials
+	return ra.client, nil
+}
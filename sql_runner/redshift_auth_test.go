@@ -0,0 +1,275 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeAWS stands in for the Redshift, Redshift Serverless, Secrets
+// Manager and STS APIs, handing out credentials which expire after ttl.
+type fakeAWS struct {
+	mu       sync.Mutex
+	server   *httptest.Server
+	ttl      time.Duration
+	calls    []url.Values
+	targets  []string
+	keyIDs   []string
+	assumed  []string
+	password int
+	secrets  map[string]string
+}
+
+func newFakeAWS(t *testing.T, ttl time.Duration) *fakeAWS {
+	// Keep the credentials and config of the machine out of the tests
+	dir := t.TempDir()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDFAKE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+
+	fa := &fakeAWS{ttl: ttl}
+	fa.server = httptest.NewServer(http.HandlerFunc(fa.handle))
+	t.Cleanup(fa.server.Close)
+	return fa
+}
+
+func (fa *fakeAWS) target() Target {
+	return Target{
+		Name:        "redshift",
+		Type:        redshiftType,
+		Host:        "localhost",
+		Port:        "5439",
+		Database:    "sql_runner_tests",
+		Region:      "eu-west-1",
+		AwsEndpoint: fa.server.URL,
+	}
+}
+
+func (fa *fakeAWS) handle(w http.ResponseWriter, r *http.Request) {
+	fa.mu.Lock()
+	defer fa.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	authorization := r.Header.Get("Authorization")
+	if i := strings.Index(authorization, "Credential="); i >= 0 {
+		fa.keyIDs = append(fa.keyIDs, strings.SplitN(authorization[i+len("Credential="):], "/", 2)[0])
+	}
+	expiration := time.Now().Add(fa.ttl).UTC()
+
+	if target := r.Header.Get("X-Amz-Target"); target == "secretsmanager.GetSecretValue" {
+		var input struct{ SecretId string }
+		json.Unmarshal(body, &input)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		secret, ok := fa.secrets[input.SecretId]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"__type":  "ResourceNotFoundException",
+				"Message": "Secrets Manager can't find the specified secret.",
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"ARN": input.SecretId, "SecretString": secret})
+		return
+	} else if target != "" {
+		fa.targets = append(fa.targets, target)
+		fa.password++
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"dbUser":     "IAMR:snowplow",
+			"dbPassword": fmt.Sprintf("password-%d", fa.password),
+			"expiration": expiration.Unix(),
+		})
+		return
+	}
+
+	form, _ := url.ParseQuery(string(body))
+	w.Header().Set("Content-Type", "text/xml")
+	switch form.Get("Action") {
+	case "AssumeRole":
+		fa.assumed = append(fa.assumed, form.Get("RoleArn"))
+		fmt.Fprintf(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <Credentials>
+      <AccessKeyId>ASIAFAKE</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>token</SessionToken>
+      <Expiration>%s</Expiration>
+    </Credentials>
+    <AssumedRoleUser><Arn>%s</Arn><AssumedRoleId>AROAFAKE:sql-runner</AssumedRoleId></AssumedRoleUser>
+  </AssumeRoleResult>
+</AssumeRoleResponse>`, time.Now().Add(time.Hour).UTC().Format(time.RFC3339), form.Get("RoleArn"))
+	case "GetClusterCredentials":
+		fa.calls = append(fa.calls, form)
+		fa.password++
+		fmt.Fprintf(w, `<GetClusterCredentialsResponse xmlns="http://redshift.amazonaws.com/doc/2012-12-01/">
+  <GetClusterCredentialsResult>
+    <DbUser>IAM:%s</DbUser>
+    <DbPassword>password-%d</DbPassword>
+    <Expiration>%s</Expiration>
+  </GetClusterCredentialsResult>
+</GetClusterCredentialsResponse>`, form.Get("DbUser"), fa.password, expiration.Format(time.RFC3339))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestNewRedshiftAuth_Error(t *testing.T) {
+	testCases := []struct {
+		Name      string
+		Input     Target
+		ErrString string
+	}{
+		{
+			Name:      "not_redshift",
+			Input:     Target{Type: postgresType, ClusterId: "cluster"},
+			ErrString: "iam authentication is only supported by redshift targets",
+		},
+		{
+			Name:      "cluster_and_workgroup",
+			Input:     Target{Type: redshiftType, ClusterId: "cluster", Workgroup: "workgroup"},
+			ErrString: "cluster_id and workgroup are mutually exclusive",
+		},
+		{
+			Name:      "password",
+			Input:     Target{Type: redshiftType, ClusterId: "cluster", Username: "snowplow", Password: "secret"},
+			ErrString: "password cannot be combined with iam authentication",
+		},
+		{
+			Name:      "missing_db_user",
+			Input:     Target{Type: redshiftType, ClusterId: "cluster", Region: "eu-west-1"},
+			ErrString: "missing target db_user",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			result, err := newRedshiftAuth(tt.Input, pg.Options{})
+			assert.Nil(result)
+			if err == nil {
+				t.Fatalf("expected error, got nil")
+			}
+
+			assert.Equal(tt.ErrString, err.Error())
+		})
+	}
+}
+
+func TestNewPostgresTarget_RedshiftCluster(t *testing.T) {
+	assert := assert.New(t)
+	fa := newFakeAWS(t, time.Hour)
+
+	target := fa.target()
+	target.ClusterId = "sql-runner"
+	target.DbUser = "snowplow"
+	target.AutoCreate = true
+	pt, err := NewPostgresTarget(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(fa.calls, 1) {
+		assert.Equal("sql-runner", fa.calls[0].Get("ClusterIdentifier"))
+		assert.Equal("snowplow", fa.calls[0].Get("DbUser"))
+		assert.Equal("sql_runner_tests", fa.calls[0].Get("DbName"))
+		assert.Equal("true", fa.calls[0].Get("AutoCreate"))
+		assert.Equal("3600", fa.calls[0].Get("DurationSeconds"))
+	}
+	assert.Equal("IAM:snowplow", pt.Client.Options().User)
+	assert.Equal("password-1", pt.Client.Options().Password)
+	assert.Equal(redactedSecret, redactSecrets("password-1"))
+	assert.Equal([]string{"AKIDFAKE"}, fa.keyIDs)
+
+	// Credentials far from expiring are reused
+	client, err := pt.client()
+	assert.Nil(err)
+	assert.Same(pt.Client, client)
+	assert.Len(fa.calls, 1)
+}
+
+func TestNewPostgresTarget_RedshiftRefresh(t *testing.T) {
+	assert := assert.New(t)
+	fa := newFakeAWS(t, time.Minute)
+
+	target := fa.target()
+	target.ClusterId = "sql-runner"
+	target.Username = "snowplow"
+	pt, err := NewPostgresTarget(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := pt.client()
+	assert.Nil(err)
+	assert.NotSame(pt.Client, client)
+	assert.Equal("password-2", client.Options().Password)
+	assert.Len(fa.calls, 2)
+	assert.Len(pt.auth.retired, 1)
+
+	// Closing the target closes the retired clients too
+	assert.Nil(pt.Close())
+	assert.Empty(pt.auth.retired)
+	assert.NotNil(client.Ping(context.Background()))
+}
+
+func TestNewPostgresTarget_RedshiftServerless(t *testing.T) {
+	assert := assert.New(t)
+	fa := newFakeAWS(t, time.Hour)
+
+	target := fa.target()
+	target.Workgroup = "sql-runner"
+	target.IamRole = "arn:aws:iam::123456789012:role/sql-runner"
+	pt, err := NewPostgresTarget(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal([]string{"RedshiftServerless.GetCredentials"}, fa.targets)
+	assert.Equal([]string{"arn:aws:iam::123456789012:role/sql-runner"}, fa.assumed)
+	assert.Equal([]string{"AKIDFAKE", "ASIAFAKE"}, fa.keyIDs)
+	assert.Equal("IAMR:snowplow", pt.Client.Options().User)
+	assert.Equal("password-1", pt.Client.Options().Password)
+}
+
+func TestNewPostgresTarget_RedshiftError(t *testing.T) {
+	assert := assert.New(t)
+	fa := newFakeAWS(t, time.Hour)
+
+	target := fa.target()
+	target.ClusterId = "sql-runner"
+	target.Username = "snowplow"
+	fa.server.Close()
+
+	result, err := NewPostgresTarget(target)
+	assert.Nil(result)
+	if assert.NotNil(err) {
+		assert.Contains(err.Error(), "cannot get redshift credentials")
+	}
+}
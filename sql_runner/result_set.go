@@ -0,0 +1,417 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// Formatting shared by every target when rendering values
+const (
+	nullString      = "NULL"
+	dateLayout      = "2006-01-02"
+	timeLayout      = "15:04:05.999999999"
+	timestampLayout = "2006-01-02 15:04:05.999999999Z07:00"
+)
+
+// ColumnKind is the logical type of a result column,
+// independent of the database it was read from.
+type ColumnKind int
+
+// Supported column kinds.
+const (
+	KindString ColumnKind = iota
+	KindInteger
+	KindFloat
+	KindDecimal
+	KindBoolean
+	KindTimestamp
+	KindDate
+	KindTime
+	KindBytes
+)
+
+// Column describes a single column of a ResultSet.
+type Column struct {
+	Name         string
+	DatabaseType string
+	Kind         ColumnKind
+}
+
+// ResultSet is a typed, database-agnostic view of the rows
+// returned by a query.
+//
+// Values in Rows are normalized to one of: nil (SQL NULL),
+// string, int64, float64, bool, time.Time or []byte.
+// Decimals are kept as their exact string representation.
+type ResultSet struct {
+	Columns []Column
+	Rows    [][]interface{}
+}
+
+// Header returns the column names of the ResultSet.
+func (rs ResultSet) Header() []string {
+	header := make([]string, len(rs.Columns))
+	for i, col := range rs.Columns {
+		header[i] = col.Name
+	}
+	return header
+}
+
+// StringRows returns every row formatted with formatValue.
+func (rs ResultSet) StringRows() [][]string {
+	rows := make([][]string, len(rs.Rows))
+	for i, row := range rs.Rows {
+		rows[i] = rs.StringRow(row)
+	}
+	return rows
+}
+
+// StringRow formats a single row of the ResultSet.
+func (rs ResultSet) StringRow(row []interface{}) []string {
+	line := make([]string, len(row))
+	for j, value := range row {
+		kind := KindString
+		if j < len(rs.Columns) {
+			kind = rs.Columns[j].Kind
+		}
+		line[j] = formatValue(value, kind)
+	}
+	return line
+}
+
+// isBlank reports whether the ResultSet holds no meaningful output:
+// either no rows at all, or a single empty cell (e.g. from
+// selecting a function returning VOID, an edge case for asserts).
+func (rs ResultSet) isBlank() bool {
+	if len(rs.Rows) == 0 {
+		return true
+	}
+	if len(rs.Rows) == 1 && len(rs.Rows[0]) == 1 {
+		value := rs.Rows[0][0]
+		return value == nil || value == ""
+	}
+	return false
+}
+
+// printTable renders a ResultSet to stdout.
+func printTable(rs ResultSet) error {
+	if rs.isBlank() {
+		return nil
+	}
+
+	if len(rs.Columns) == 0 {
+		return errors.New("Unable to read columns")
+	}
+
+	log.Printf("QUERY OUTPUT:\n")
+	renderTable(redactingWriter{os.Stdout}, rs) // Send output
+	return nil
+}
+
+// renderTable writes a ResultSet as a table.
+func renderTable(w io.Writer, rs ResultSet) {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader(rs.Header())
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.AppendBulk(rs.StringRows())
+	table.Render()
+}
+
+// indentedTable renders a ResultSet as a table, each line
+// prefixed with indent, for embedding in messages.
+func indentedTable(rs ResultSet, indent string) string {
+	var buffer bytes.Buffer
+	renderTable(&buffer, rs)
+
+	lines := strings.Split(strings.TrimRight(buffer.String(), "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = indent + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// printTables renders each of the given ResultSets to stdout.
+func printTables(sets []ResultSet) error {
+	for _, rs := range sets {
+		if err := printTable(rs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// valueConverter turns driver specific values into one of the
+// types handled by normalizeValue.
+type valueConverter func(value interface{}, column Column) interface{}
+
+// resultSetFromRows reads the current result set of rows into a ResultSet.
+// convert may be nil when the driver only returns standard types.
+func resultSetFromRows(rows *sql.Rows, convert valueConverter) (ResultSet, error) {
+	colTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return ResultSet{}, errors.New("Unable to read columns")
+	}
+
+	columns := make([]Column, len(colTypes))
+	for i, ct := range colTypes {
+		_, scale, _ := ct.DecimalSize()
+		columns[i] = Column{
+			Name:         ct.Name(),
+			DatabaseType: ct.DatabaseTypeName(),
+			Kind:         kindFromDatabaseType(ct.DatabaseTypeName(), scale),
+		}
+	}
+
+	rs := ResultSet{Columns: columns, Rows: make([][]interface{}, 0)}
+	for rows.Next() {
+		raw := make([]interface{}, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range raw {
+			dest[i] = &raw[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return ResultSet{}, errors.New("Unable to read row")
+		}
+
+		row := make([]interface{}, len(columns))
+		for i, value := range raw {
+			if convert != nil {
+				value = convert(value, columns[i])
+			}
+			row[i] = normalizeValue(value, columns[i].Kind)
+		}
+		rs.Rows = append(rs.Rows, row)
+	}
+	return rs, rows.Err()
+}
+
+// resultSetsFromRows reads every result set of a multi-statement
+// query, skipping statements which returned no columns.
+func resultSetsFromRows(rows *sql.Rows, convert valueConverter) ([]ResultSet, error) {
+	sets := make([]ResultSet, 0)
+	for {
+		rs, err := resultSetFromRows(rows, convert)
+		if err != nil {
+			return nil, err
+		}
+		if len(rs.Columns) > 0 {
+			sets = append(sets, rs)
+		}
+		if !rows.NextResultSet() {
+			break
+		}
+	}
+	return sets, rows.Err()
+}
+
+// kindFromDatabaseType maps a database type name, as reported by
+// any of the supported drivers, to a ColumnKind.
+func kindFromDatabaseType(dbType string, scale int64) ColumnKind {
+	name := strings.ToUpper(strings.TrimSpace(dbType))
+	name = strings.TrimPrefix(name, "UNSIGNED ")
+	// ClickHouse wraps the type of nullable and dictionary encoded columns
+	for _, wrapper := range []string{"LOWCARDINALITY(", "NULLABLE("} {
+		if strings.HasPrefix(name, wrapper) {
+			name = strings.TrimSuffix(strings.TrimPrefix(name, wrapper), ")")
+		}
+	}
+	if i := strings.IndexAny(name, "( "); i > 0 {
+		name = name[:i]
+	}
+
+	switch name {
+	case "INT", "INT2", "INT4", "INT8", "INTEGER", "BIGINT", "SMALLINT", "TINYINT", "MEDIUMINT",
+		"INT16", "INT32", "INT64", "INT128", "INT256", "UINT8", "UINT16", "UINT32", "UINT64", "UINT128", "UINT256", "SERIAL", "BIGSERIAL", "OID", "YEAR",
+		"HUGEINT", "UHUGEINT", "UBIGINT", "UINTEGER", "USMALLINT", "UTINYINT":
+		return KindInteger
+	case "FIXED":
+		if scale > 0 {
+			return KindDecimal
+		}
+		return KindInteger
+	case "FLOAT", "FLOAT4", "FLOAT8", "FLOAT32", "FLOAT64", "REAL", "DOUBLE":
+		return KindFloat
+	case "NUMERIC", "DECIMAL", "NUMBER", "BIGNUMERIC", "MONEY", "SMALLMONEY":
+		return KindDecimal
+	case "BOOL", "BOOLEAN", "BIT":
+		return KindBoolean
+	case "DATE", "DATE32":
+		return KindDate
+	case "TIME", "TIMETZ":
+		return KindTime
+	case "BYTEA", "BYTES", "BINARY", "VARBINARY", "BLOB", "TINYBLOB", "MEDIUMBLOB", "LONGBLOB", "IMAGE":
+		return KindBytes
+	}
+
+	if strings.HasPrefix(name, "TIMESTAMP") || strings.HasPrefix(name, "DATETIME") || name == "SMALLDATETIME" {
+		return KindTimestamp
+	}
+	return KindString
+}
+
+// normalizeValue converts a raw driver value to the representation
+// documented on ResultSet, using the column kind to parse values
+// that drivers hand back as text.
+func normalizeValue(value interface{}, kind ColumnKind) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		if kind == KindBytes {
+			return append([]byte(nil), v...)
+		}
+		return normalizeValue(string(v), kind)
+	case string:
+		return parseValue(v, kind)
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		if v > math.MaxInt64 {
+			return strconv.FormatUint(v, 10)
+		}
+		return int64(v)
+	case float32:
+		return float64(v)
+	case float64:
+		return v
+	case *big.Int:
+		if v.IsInt64() {
+			return v.Int64()
+		}
+		return v.String()
+	case *big.Rat:
+		return normalizeDecimal(v.FloatString(9))
+	case bool, time.Time:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// parseValue parses a textual value according to its column kind,
+// leaving it as text when it cannot be parsed.
+func parseValue(s string, kind ColumnKind) interface{} {
+	switch kind {
+	case KindInteger:
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i
+		}
+	case KindFloat:
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	case KindDecimal:
+		return normalizeDecimal(s)
+	case KindBoolean:
+		switch strings.ToLower(s) {
+		case "t", "true", "1":
+			return true
+		case "f", "false", "0":
+			return false
+		}
+	}
+	return s
+}
+
+// normalizeDecimal strips insignificant trailing zeros from
+// the fractional part of a decimal string.
+func normalizeDecimal(s string) string {
+	if !strings.Contains(s, ".") || strings.ContainsAny(s, "eE") {
+		return s
+	}
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// formatValue renders a normalized value as text, so that
+// every target prints NULLs, numbers and timestamps the same way.
+func formatValue(value interface{}, kind ColumnKind) string {
+	switch v := value.(type) {
+	case nil:
+		return nullString
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return formatFloat(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case []byte:
+		return `\x` + hex.EncodeToString(v)
+	case time.Time:
+		switch kind {
+		case KindDate:
+			return v.Format(dateLayout)
+		case KindTime:
+			return v.Format(timeLayout)
+		default:
+			return v.UTC().Format(timestampLayout)
+		}
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// formatFloat avoids exponent notation for floats in a
+// human-readable range.
+func formatFloat(f float64) string {
+	abs := math.Abs(f)
+	if abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// toFloat returns the numeric value of a normalized value, if it has one.
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKindFromDatabaseType(t *testing.T) {
+	testCases := []struct {
+		DbType   string
+		Scale    int64
+		Expected ColumnKind
+	}{
+		{DbType: "BIGINT", Expected: KindInteger},
+		{DbType: "int4", Expected: KindInteger},
+		{DbType: "FIXED", Scale: 0, Expected: KindInteger},
+		{DbType: "FIXED", Scale: 2, Expected: KindDecimal},
+		{DbType: "DECIMAL(10,2)", Expected: KindDecimal},
+		{DbType: "DOUBLE PRECISION", Expected: KindFloat},
+		{DbType: "BOOLEAN", Expected: KindBoolean},
+		{DbType: "TIMESTAMP_NTZ", Expected: KindTimestamp},
+		{DbType: "DATETIME2", Expected: KindTimestamp},
+		{DbType: "DATE", Expected: KindDate},
+		{DbType: "TIME", Expected: KindTime},
+		{DbType: "VARBINARY", Expected: KindBytes},
+		{DbType: "UNSIGNED BIGINT", Expected: KindInteger},
+		{DbType: "MEDIUMBLOB", Expected: KindBytes},
+		{DbType: "Nullable(UInt128)", Expected: KindInteger},
+		{DbType: "BIT", Expected: KindBoolean},
+		{DbType: "DATETIMEOFFSET", Expected: KindTimestamp},
+		{DbType: "LowCardinality(Nullable(Date32))", Expected: KindDate},
+		{DbType: "Nullable(DateTime64(3, 'UTC'))", Expected: KindTimestamp},
+		{DbType: "TEXT", Expected: KindString},
+		{DbType: "", Expected: KindString},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.DbType, func(t *testing.T) {
+			assert.Equal(t, tt.Expected, kindFromDatabaseType(tt.DbType, tt.Scale))
+		})
+	}
+}
+
+func TestNormalizeValue(t *testing.T) {
+	ts := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	testCases := []struct {
+		Name     string
+		Value    interface{}
+		Kind     ColumnKind
+		Expected interface{}
+	}{
+		{Name: "null", Value: nil, Kind: KindInteger, Expected: nil},
+		{Name: "int_from_text", Value: "42", Kind: KindInteger, Expected: int64(42)},
+		{Name: "int_from_bytes", Value: []byte("42"), Kind: KindInteger, Expected: int64(42)},
+		{Name: "int32", Value: int32(42), Kind: KindInteger, Expected: int64(42)},
+		{Name: "float_from_text", Value: "1.5", Kind: KindFloat, Expected: 1.5},
+		{Name: "decimal_trailing_zeros", Value: "1.500", Kind: KindDecimal, Expected: "1.5"},
+		{Name: "decimal_integral", Value: "10.00", Kind: KindDecimal, Expected: "10"},
+		{Name: "big_rat", Value: big.NewRat(3, 2), Kind: KindDecimal, Expected: "1.5"},
+		{Name: "pg_bool", Value: "t", Kind: KindBoolean, Expected: true},
+		{Name: "bytes", Value: []byte{0xde, 0xad}, Kind: KindBytes, Expected: []byte{0xde, 0xad}},
+		{Name: "time", Value: ts, Kind: KindTimestamp, Expected: ts},
+		{Name: "unparseable", Value: "NaN?", Kind: KindInteger, Expected: "NaN?"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(t, tt.Expected, normalizeValue(tt.Value, tt.Kind))
+		})
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	ts := time.Date(2022, 1, 2, 3, 4, 5, 600000000, time.FixedZone("CET", 3600))
+
+	testCases := []struct {
+		Name     string
+		Value    interface{}
+		Kind     ColumnKind
+		Expected string
+	}{
+		{Name: "null", Value: nil, Kind: KindString, Expected: "NULL"},
+		{Name: "int", Value: int64(-7), Kind: KindInteger, Expected: "-7"},
+		{Name: "float", Value: 1000000.0, Kind: KindFloat, Expected: "1000000"},
+		{Name: "float_small", Value: 0.25, Kind: KindFloat, Expected: "0.25"},
+		{Name: "float_huge", Value: 1e22, Kind: KindFloat, Expected: "1e+22"},
+		{Name: "bool", Value: false, Kind: KindBoolean, Expected: "false"},
+		{Name: "bytes", Value: []byte{0xde, 0xad}, Kind: KindBytes, Expected: `\xdead`},
+		{Name: "timestamp", Value: ts, Kind: KindTimestamp, Expected: "2022-01-02 02:04:05.6Z"},
+		{Name: "date", Value: ts, Kind: KindDate, Expected: "2022-01-02"},
+		{Name: "time", Value: ts, Kind: KindTime, Expected: "03:04:05.6"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(t, tt.Expected, formatValue(tt.Value, tt.Kind))
+		})
+	}
+}
+
+func TestResultSet_StringRows(t *testing.T) {
+	assert := assert.New(t)
+
+	rs := ResultSet{
+		Columns: []Column{
+			{Name: "id", Kind: KindInteger},
+			{Name: "name", Kind: KindString},
+		},
+		Rows: [][]interface{}{
+			{int64(1), "a"},
+			{int64(2), nil},
+		},
+	}
+
+	assert.Equal([]string{"id", "name"}, rs.Header())
+	assert.Equal([][]string{{"1", "a"}, {"2", "NULL"}}, rs.StringRows())
+	assert.False(rs.isBlank())
+}
+
+func TestResultSet_IsBlank(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(ResultSet{}.isBlank())
+	assert.True(ResultSet{Columns: []Column{{Name: "f"}}, Rows: [][]interface{}{{""}}}.isBlank())
+	assert.True(ResultSet{Columns: []Column{{Name: "f"}}, Rows: [][]interface{}{{nil}}}.isBlank())
+	assert.False(ResultSet{Columns: []Column{{Name: "f"}}, Rows: [][]interface{}{{int64(0)}}}.isBlank())
+}
@@ -13,11 +13,15 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"sort"
+	"strings"
 	"text/template"
 )
 
 var (
 	failureTemplate *template.Template
+	warningTemplate *template.Template
+	jobTemplate     *template.Template
 )
 
 func init() {
@@ -28,20 +32,49 @@ TARGET INITIALIZATION FAILURES:{{range $status := .}}{{if $status.Errors}}
 QUERY FAILURES:{{range $status := .}}{{range $step := $status.Steps}}{{range $query := $step.Queries}}{{if $query.Error}}
 * Query {{$query.Query.Name}} {{$query.Path}} (in step {{$step.Name}} @ target {{$status.Name}}), ERROR:
   - {{$query.Error}}{{end}}{{end}}{{end}}{{end}}
+`))
+	warningTemplate = template.Must(template.New("warning").Parse(`
+CHECK WARNINGS:{{range $status := .}}{{range $step := $status.Steps}}{{range $query := $step.Warnings}}
+* Check {{$query.Query.Name}} (in step {{$step.Name}} @ target {{$status.Name}}), WARNING:
+  - {{$query.Error}}{{end}}{{end}}{{end}}
+`))
+	jobTemplate = template.Must(template.New("job").Funcs(template.FuncMap{"labels": formatLabels}).Parse(`
+JOBS:{{range $status := .}}{{range $step := $status.Steps}}{{range $query := $step.Queries}}{{with $job := $query.Job}}{{if not $job.DryRun}}
+* Query {{$query.Query.Name}} (in step {{$step.Name}} @ target {{$status.Name}}), JOB {{$job.ID}}:
+  - location: {{$job.Location}}, priority: {{$job.Priority}}, labels: {{labels $job.Labels}}
+  - bytes processed: {{$job.BytesProcessed}}, bytes billed: {{$job.BytesBilled}}, cache hit: {{$job.CacheHit}}{{end}}{{end}}{{end}}{{end}}{{end}}
 `))
 }
 
+// review returns the exit code and the summary of a run, with the
+// secrets of the run redacted.
 func review(statuses []TargetStatus) (int, string) {
+	exitCode, message := reviewStatuses(statuses)
+	return exitCode, redactSecrets(message)
+}
+
+func reviewStatuses(statuses []TargetStatus) (int, string) {
 	exitCode, queryCount := getExitCodeAndQueryCount(statuses)
 
+	var details string
+	if hasJobs(statuses, false) {
+		details += getJobMessage(statuses)
+	}
+	if hasJobs(statuses, true) {
+		details += getEstimateMessage(statuses)
+	}
+	if hasWarnings(statuses) {
+		details += getWarningMessage(statuses)
+	}
+
 	if exitCode == 0 {
-		return exitCode, getSuccessMessage(queryCount, len(statuses))
+		return exitCode, getSuccessMessage(queryCount, len(statuses)) + details
 	} else if exitCode == 8 {
 		var message bytes.Buffer
 		message.WriteString("WARNING: No queries to run\n")
 		return exitCode, message.String()
 	} else {
-		return exitCode, getFailureMessage(statuses)
+		return exitCode, getFailureMessage(statuses) + details
 	}
 }
 
@@ -61,6 +94,121 @@ func getFailureMessage(statuses []TargetStatus) string {
 	return message.String()
 }
 
+// getWarningMessage lists the checks which failed with warn severity
+func getWarningMessage(statuses []TargetStatus) string {
+
+	var message bytes.Buffer
+	if err := warningTemplate.Execute(&message, statuses); err != nil {
+		return fmt.Sprintf("ERROR: executing warning message template itself failed: %s", err.Error())
+	}
+
+	return message.String()
+}
+
+// getJobMessage lists the jobs queries were run as
+func getJobMessage(statuses []TargetStatus) string {
+
+	var message bytes.Buffer
+	if err := jobTemplate.Execute(&message, statuses); err != nil {
+		return fmt.Sprintf("ERROR: executing job message template itself failed: %s", err.Error())
+	}
+
+	return message.String()
+}
+
+// formatLabels lists job labels as key=value, sorted by key
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return "none"
+	}
+
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+// getEstimateMessage totals the bytes processed and cost estimated by
+// dry-run jobs, per query, step and target
+func getEstimateMessage(statuses []TargetStatus) string {
+
+	var message bytes.Buffer
+	var totalBytes int64
+	var totalCost float64
+
+	message.WriteString("\nESTIMATES:")
+	for _, targetStatus := range statuses {
+		var steps bytes.Buffer
+		var targetBytes int64
+		var targetCost float64
+
+		for _, stepStatus := range targetStatus.Steps {
+			var queries bytes.Buffer
+			var stepBytes int64
+			var stepCost float64
+
+			for _, queryStatus := range stepStatus.Queries {
+				job := queryStatus.Job
+				if job == nil || !job.DryRun {
+					continue
+				}
+				fmt.Fprintf(&queries, "\n    - Query %s: %s", queryStatus.Query.Name, formatEstimate(job.BytesProcessed, job.EstimatedCost))
+				stepBytes += job.BytesProcessed
+				stepCost += job.EstimatedCost
+			}
+			if queries.Len() == 0 {
+				continue
+			}
+			fmt.Fprintf(&steps, "\n  - Step %s: %s%s", stepStatus.Name, formatEstimate(stepBytes, stepCost), queries.String())
+			targetBytes += stepBytes
+			targetCost += stepCost
+		}
+		if steps.Len() == 0 {
+			continue
+		}
+		fmt.Fprintf(&message, "\n* Target %s: %s%s", targetStatus.Name, formatEstimate(targetBytes, targetCost), steps.String())
+		totalBytes += targetBytes
+		totalCost += targetCost
+	}
+	fmt.Fprintf(&message, "\nTOTAL: %s\n", formatEstimate(totalBytes, totalCost))
+
+	return message.String()
+}
+
+// formatEstimate shows bytes processed and their estimated cost
+func formatEstimate(bytesProcessed int64, cost float64) string {
+	return fmt.Sprintf("%d bytes (%.2f GiB), estimated cost %.2f", bytesProcessed, float64(bytesProcessed)/(1<<30), cost)
+}
+
+// hasJobs returns whether any query reported a job, either run or
+// estimating the query
+func hasJobs(statuses []TargetStatus, dryRun bool) bool {
+	for _, targetStatus := range statuses {
+		for _, stepStatus := range targetStatus.Steps {
+			for _, queryStatus := range stepStatus.Queries {
+				if queryStatus.Job != nil && queryStatus.Job.DryRun == dryRun {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// hasWarnings returns whether any step reported a warning
+func hasWarnings(statuses []TargetStatus) bool {
+	for _, targetStatus := range statuses {
+		for _, stepStatus := range targetStatus.Steps {
+			if len(stepStatus.Warnings) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // getExitCodeAndQueryCount processes statuses and returns:
 // - 0 for no errors
 // - 5 for target initialization errors
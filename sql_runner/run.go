@@ -23,6 +23,14 @@ const (
 	postgresqlType = "postgresql"
 	snowflakeType  = "snowflake"
 	bigqueryType   = "bigquery"
+	mysqlType      = "mysql"
+	sqliteType     = "sqlite"
+	duckdbType     = "duckdb"
+	clickhouseType = "clickhouse"
+	mssqlType      = "mssql"
+	trinoType      = "trino"
+	genericType    = "generic"
+	pluginType     = "plugin"
 
 	errorUnsupportedDbType = "Database type is unsupported"
 	errorFromStepNotFound  = "The fromStep argument did not match any available steps"
@@ -42,9 +50,10 @@ type TargetStatus struct {
 
 // StepStatus reports on any errors from running a step.
 type StepStatus struct {
-	Name    string
-	Index   int
-	Queries []QueryStatus
+	Name     string
+	Index    int
+	Queries  []QueryStatus
+	Warnings []QueryStatus // Failed checks with warn severity
 }
 
 // QueryStatus reports ony any error from a query.
@@ -53,12 +62,26 @@ type QueryStatus struct {
 	Path     string
 	Affected int
 	Error    error
+	Job      *JobInfo // Set by targets running queries as jobs
+}
+
+// JobInfo describes the job a query was run as.
+type JobInfo struct {
+	ID, Location, Priority string
+	Labels                 map[string]string
+	BytesProcessed         int64
+	BytesBilled            int64
+	CacheHit               bool
+	DryRun                 bool    // Set for jobs estimating a query
+	EstimatedCost          float64 // Cost of the bytes processed
 }
 
 // ReadyStep contains a step that is ready for execution.
 type ReadyStep struct {
 	Name    string
 	Queries []ReadyQuery
+	Checks  []Check
+	Compare *ReadyCompare
 }
 
 // ReadyQuery contains a query that is ready for execution.
@@ -66,13 +89,17 @@ type ReadyQuery struct {
 	Script string
 	Name   string
 	Path   string
+	Assert *Assertion
+
+	Labels     map[string]string      // Job labels of BigQuery targets
+	Parameters map[string]interface{} // Query parameters of BigQuery targets
 }
 
 // Run runs a playbook of SQL scripts.
 //
 // Handles dispatch to the appropriate
 // database engine
-func Run(pb Playbook, sp SQLProvider, fromStep string, runQuery string, dryRun bool, fillTemplates bool, showQueryOutput bool) []TargetStatus {
+func Run(pb Playbook, sp SQLProvider, fromStep string, runQuery string, dryRun bool, validate bool, fillTemplates bool, showQueryOutput bool, progress Progress) []TargetStatus {
 
 	var steps []Step
 	var trimErr []TargetStatus
@@ -86,6 +113,8 @@ func Run(pb Playbook, sp SQLProvider, fromStep string, runQuery string, dryRun b
 		return trimErr
 	}
 
+	registerSensitiveVariables(pb)
+
 	// Prepare all SQL queries
 	readySteps, readyErr := loadSteps(steps, sp, pb.Variables, pb.Targets)
 	if readyErr != nil {
@@ -100,7 +129,16 @@ func Run(pb Playbook, sp SQLProvider, fromStep string, runQuery string, dryRun b
 				message.WriteString(fmt.Sprintf("Query name: %s\n", query.Name))
 				message.WriteString(fmt.Sprintf("Query path: %s\n", query.Path))
 				message.WriteString(query.Script)
-				log.Print(message.String())
+				log.Print(redactSecrets(message.String()))
+			}
+			if steps.Compare != nil {
+				query := steps.Compare.Query
+				var message bytes.Buffer
+				message.WriteString(fmt.Sprintf("Step name: %s\n", steps.Name))
+				message.WriteString(fmt.Sprintf("Compare name: %s\n", query.Name))
+				message.WriteString(fmt.Sprintf("Compare path: %s\n", query.Path))
+				message.WriteString(query.Script)
+				log.Print(redactSecrets(message.String()))
 			}
 		}
 		allStatuses := make([]TargetStatus, 0)
@@ -108,10 +146,11 @@ func Run(pb Playbook, sp SQLProvider, fromStep string, runQuery string, dryRun b
 	}
 
 	targetChan := make(chan TargetStatus, len(pb.Targets))
+	cc := newCompareCoordinator(readySteps)
 
 	// Route each target to the right db client and run
 	for _, tgt := range pb.Targets {
-		routeAndRun(tgt, readySteps, targetChan, dryRun, showQueryOutput)
+		routeAndRun(tgt, readySteps, cc, progress, targetChan, dryRun, validate, showQueryOutput)
 	}
 
 	// Compose statuses from each target run
@@ -226,9 +265,26 @@ func loadSteps(steps []Step, sp SQLProvider, variables map[string]interface{}, t
 				}
 				return nil, allStatuses
 			}
-			readyQueries[j] = ReadyQuery{Script: queryText, Name: query.Name, Path: queryPath}
+			readyQueries[j] = ReadyQuery{Script: queryText, Name: query.Name, Path: queryPath, Assert: query.Assert, Labels: query.Labels, Parameters: query.QueryParameters}
+		}
+		readySteps[i] = ReadyStep{Name: step.Name, Queries: readyQueries, Checks: step.Checks}
+
+		if step.Compare != nil {
+			cmp := *step.Compare
+			queryText, err := prepareQuery(cmp.File, sp, cmp.Template, variables)
+			queryPath := sp.ResolveKey(cmp.File)
+
+			if err != nil {
+				allStatuses := make([]TargetStatus, 0)
+				for _, tgt := range targets {
+					status := loadQueryFailed(tgt.Name, queryPath, err)
+					allStatuses = append(allStatuses, status)
+				}
+				return nil, allStatuses
+			}
+			query := ReadyQuery{Script: queryText, Name: cmp.Name, Path: queryPath}
+			readySteps[i].Compare = &ReadyCompare{Compare: cmp, Query: query}
 		}
-		readySteps[i] = ReadyStep{Name: step.Name, Queries: readyQueries}
 	}
 	return readySteps, nil
 }
@@ -246,43 +302,35 @@ func loadQueryFailed(targetName string, queryPath string, err error) TargetStatu
 // --- Running
 
 // Route to correct database client and run
-func routeAndRun(target Target, readySteps []ReadyStep, targetChan chan TargetStatus, dryRun bool, showQueryOutput bool) {
-	switch strings.ToLower(target.Type) {
-	case redshiftType, postgresType, postgresqlType:
-		go func(tgt Target) {
-			pg, err := NewPostgresTarget(tgt)
-			if err != nil {
-				targetChan <- newTargetFailure(tgt, err)
-				return
-			}
-			targetChan <- runSteps(pg, readySteps, dryRun, showQueryOutput)
-		}(target)
-	case snowflakeType:
-		go func(tgt Target) {
-			snfl, err := NewSnowflakeTarget(tgt)
-			if err != nil {
-				targetChan <- newTargetFailure(tgt, err)
-				return
-			}
-			targetChan <- runSteps(snfl, readySteps, dryRun, showQueryOutput)
-		}(target)
-	case bigqueryType:
-		go func(tgt Target) {
-			bq, err := NewBigQueryTarget(tgt)
-			if err != nil {
-				targetChan <- newTargetFailure(tgt, err)
-				return
-			}
-			targetChan <- runSteps(bq, readySteps, dryRun, showQueryOutput)
-		}(target)
-	default:
+func routeAndRun(target Target, readySteps []ReadyStep, cc *compareCoordinator, progress Progress, targetChan chan TargetStatus, dryRun bool, validate bool, showQueryOutput bool) {
+	constructor, ok := constructorFor(target)
+	if !ok {
+		cc.abandon(target.Name)
+		progress.TargetFinished(target.Name)
 		targetChan <- unsupportedDbType(target.Name, target.Type)
+		return
 	}
+
+	go func(tgt Target) {
+		// Secrets are only resolved now, so they are never in the playbook
+		resolved, err := resolveSecrets(tgt)
+		var database Db
+		if err == nil {
+			database, err = constructor(resolved)
+		}
+		if err != nil {
+			cc.abandon(tgt.Name)
+			progress.TargetFinished(tgt.Name)
+			targetChan <- newTargetFailure(tgt, err)
+			return
+		}
+		targetChan <- runSteps(database, readySteps, cc, progress, dryRun, validate, showQueryOutput)
+	}(target)
 }
 
 // Helper for an unrecognized database type
 func unsupportedDbType(targetName string, targetType string) TargetStatus {
-	errs := []error{fmt.Errorf("%s: %s", errorUnsupportedDbType, targetType)}
+	errs := []error{fmt.Errorf("%s: %s (supported: %s)", errorUnsupportedDbType, targetType, strings.Join(registeredTargetTypes(), ", "))}
 	return TargetStatus{
 		Name:   targetName,
 		Errors: errs,
@@ -305,20 +353,48 @@ func newTargetFailure(target Target, err error) TargetStatus {
 //
 // runSteps fails fast - we stop executing SQL on
 // this target when a step fails.
-func runSteps(database Db, steps []ReadyStep, dryRun bool, showQueryOutput bool) TargetStatus {
+func runSteps(database Db, steps []ReadyStep, cc *compareCoordinator, progress Progress, dryRun bool, validate bool, showQueryOutput bool) TargetStatus {
 
 	allStatuses := make([]StepStatus, len(steps))
+	dbName := database.GetTarget().Name
+	defer progress.TargetFinished(dbName)
+	defer cc.abandon(dbName)
+	defer closeDb(database)
+
+	if dryRun {
+		checkConnection(database)
+		if _, ok := database.(Validator); validate && !ok {
+			log.Printf("WARNING: Target %s does not support -validate, its queries are not validated.", dbName)
+		}
+	}
 
 FailFast:
 	for i, stp := range steps {
 		stpIndex := i + 1
-		status := runQueries(database, stpIndex, stp.Name, stp.Queries, dryRun, showQueryOutput)
+		if i > 0 && !dryRun {
+			checkHealth(database)
+		}
+		progress.StepStarted(dbName, stpIndex, len(steps), stp.Name)
+		status := runQueries(database, progress, stpIndex, stp.Name, stp.Queries, dryRun, validate, showQueryOutput)
+		if len(stp.Checks) > 0 {
+			checked, warnings := runChecks(database, progress, stp.Name, stp.Checks, dryRun)
+			status.Queries = append(status.Queries, checked...)
+			status.Warnings = warnings
+		}
+		if stp.Compare != nil && !hasQueryErrors(status) {
+			if stp.Compare.reportsFor(dbName, dryRun) {
+				progress.QueryStarted(dbName, stp.Compare.Query.Name)
+			}
+			compared := runCompareStep(cc, database, i, stp.Name, *stp.Compare, dryRun)
+			for _, qry := range compared {
+				progress.QueryFinished(dbName, qry)
+			}
+			status.Queries = append(status.Queries, compared...)
+		}
 		allStatuses = append(allStatuses, status)
 
-		for _, qry := range status.Queries {
-			if qry.Error != nil {
-				break FailFast
-			}
+		if hasQueryErrors(status) {
+			break FailFast
 		}
 	}
 	return TargetStatus{
@@ -328,12 +404,41 @@ FailFast:
 	}
 }
 
+// checkConnection logs whether a target can be connected to,
+// once per dry run.
+func checkConnection(database Db) {
+	name := database.GetTarget().Name
+	if database.IsConnectable() {
+		log.Printf("SUCCESS: Able to connect to target database, %s.", name)
+	} else {
+		log.Printf("ERROR: Cannot connect to target database, %s.", name)
+	}
+}
+
+// checkHealth checks the connections of a target between steps, so
+// that those which died during a long step are replaced before the
+// next one runs.
+func checkHealth(database Db) {
+	if checker, ok := database.(HealthChecker); ok {
+		if err := checker.CheckHealth(); err != nil {
+			log.Printf("WARNING: Target %s failed its health check: %s", database.GetTarget().Name, err)
+		}
+	}
+}
+
+// closeDb closes the clients of a target once its steps are done.
+func closeDb(database Db) {
+	if err := database.Close(); err != nil {
+		log.Printf("WARNING: Failed to close target %s: %s", database.GetTarget().Name, err)
+	}
+}
+
 // Handles running N queries in parallel.
 //
 // runQueries composes failures across the queries
 // for a given step: if one query fails, the others
 // will still complete.
-func runQueries(database Db, stepIndex int, stepName string, queries []ReadyQuery, dryRun bool, showQueryOutput bool) StepStatus {
+func runQueries(database Db, progress Progress, stepIndex int, stepName string, queries []ReadyQuery, dryRun bool, validate bool, showQueryOutput bool) StepStatus {
 
 	queryChan := make(chan QueryStatus, len(queries))
 	dbName := database.GetTarget().Name
@@ -342,6 +447,15 @@ func runQueries(database Db, stepIndex int, stepName string, queries []ReadyQuer
 	for _, query := range queries {
 		go func(qry ReadyQuery) {
 			log.Printf("EXECUTING %s (in step %s @ %s): %s", qry.Name, stepName, dbName, qry.Path)
+			progress.QueryStarted(dbName, qry.Name)
+			if qry.Assert != nil && !dryRun {
+				queryChan <- runAssertion(database, qry, showQueryOutput)
+				return
+			}
+			if validator, ok := database.(Validator); ok && dryRun && validate {
+				queryChan <- validator.ValidateQuery(qry)
+				return
+			}
 			queryChan <- database.RunQuery(qry, dryRun, showQueryOutput)
 		}(query)
 	}
@@ -356,6 +470,7 @@ func runQueries(database Db, stepIndex int, stepName string, queries []ReadyQuer
 			} else {
 				log.Printf("SUCCESS: %s (step %s @ target %s), ROWS AFFECTED: %d\n", status.Query.Name, stepName, dbName, status.Affected)
 			}
+			progress.QueryFinished(dbName, status)
 			allStatuses = append(allStatuses, status)
 		}
 	}
@@ -366,3 +481,13 @@ func runQueries(database Db, stepIndex int, stepName string, queries []ReadyQuer
 		Queries: allStatuses,
 	}
 }
+
+// Helper to check whether any query of a step failed
+func hasQueryErrors(status StepStatus) bool {
+	for _, qry := range status.Queries {
+		if qry.Error != nil {
+			return true
+		}
+	}
+	return false
+}
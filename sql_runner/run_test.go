@@ -11,8 +11,10 @@
 package main
 
 import (
+	"errors"
 	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
@@ -191,3 +193,142 @@ func checkError(err error, errString string, exact bool) bool {
 
 	return err.Error() == errString
 }
+
+func TestRun_SQLite(t *testing.T) {
+	assert := assert.New(t)
+
+	pbp := NewYAMLFilePlaybookProvider("../integration/resources/good-sqlite.yml", nil)
+	pb, err := pbp.GetPlaybook()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp := NewFileSQLProvider("../integration/resources")
+
+	statuses := Run(*pb, sp, "", "", false, false, false, false, lineProgress{})
+	code, message := review(statuses)
+	assert.Equal(0, code, message)
+	if assert.Equal(1, len(statuses)) {
+		assert.Equal("My SQLite database", statuses[0].Name)
+	}
+}
+
+func TestRun_Generic(t *testing.T) {
+	assert := assert.New(t)
+
+	pbp := NewYAMLFilePlaybookProvider("../integration/resources/good-generic.yml", nil)
+	pb, err := pbp.GetPlaybook()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp := NewFileSQLProvider("../integration/resources")
+
+	statuses := Run(*pb, sp, "", "", false, false, false, false, lineProgress{})
+	code, message := review(statuses)
+	assert.Equal(0, code, message)
+
+	pb.Targets[0].Type = "oracle"
+	statuses = Run(*pb, sp, "", "", false, false, false, false, lineProgress{})
+	code, _ = review(statuses)
+	assert.Equal(5, code)
+}
+
+// validatingDb counts connection checks and rejects queries named bad
+// on validation.
+type validatingDb struct {
+	fakeDb
+	connects  *atomic.Int32
+	validated *atomic.Int32
+}
+
+func (v validatingDb) IsConnectable() bool {
+	v.connects.Add(1)
+	return true
+}
+
+func (v validatingDb) ValidateQuery(query ReadyQuery) QueryStatus {
+	v.validated.Add(1)
+	if query.Name == "bad" {
+		return QueryStatus{Query: query, Path: query.Path, Error: errors.New(`statement 1: syntax error at or near "TABLE"`)}
+	}
+	return QueryStatus{Query: query, Path: query.Path}
+}
+
+func TestRunSteps_DryRun(t *testing.T) {
+	assert := assert.New(t)
+
+	steps := []ReadyStep{
+		{Name: "load", Queries: []ReadyQuery{{Name: "a"}, {Name: "b"}}},
+		{Name: "report", Queries: []ReadyQuery{{Name: "bad", Path: "bad.sql"}}},
+	}
+	database := validatingDb{fakeDb{target: Target{Name: "db"}}, &atomic.Int32{}, &atomic.Int32{}}
+
+	// Connectivity is checked once per target
+	status := runSteps(database, steps, newCompareCoordinator(steps), lineProgress{}, true, false, false)
+	code, message := review([]TargetStatus{status})
+	assert.Equal(0, code, message)
+	assert.Equal(int32(1), database.connects.Load())
+	assert.Equal(int32(0), database.validated.Load())
+
+	status = runSteps(database, steps, newCompareCoordinator(steps), lineProgress{}, true, true, false)
+	code, message = review([]TargetStatus{status})
+	assert.Equal(6, code)
+	assert.Contains(message, "* Query bad bad.sql (in step report @ target db), ERROR:\n  - statement 1: syntax error at or near \"TABLE\"")
+	assert.Equal(int32(2), database.connects.Load())
+	assert.Equal(int32(3), database.validated.Load())
+}
+
+// pooledDb counts health checks and closes, failing its health checks
+// when unhealthy.
+type pooledDb struct {
+	fakeDb
+	checks    *atomic.Int32
+	closes    *atomic.Int32
+	unhealthy bool
+}
+
+func (p pooledDb) CheckHealth() error {
+	p.checks.Add(1)
+	if p.unhealthy {
+		return errors.New("connection reset by peer")
+	}
+	return nil
+}
+
+func (p pooledDb) Close() error {
+	p.closes.Add(1)
+	return nil
+}
+
+func TestRunSteps_HealthAndClose(t *testing.T) {
+	steps := []ReadyStep{
+		{Name: "load", Queries: []ReadyQuery{{Name: "a"}, {Name: "b"}}},
+		{Name: "model", Queries: []ReadyQuery{{Name: "c"}}},
+		{Name: "report", Queries: []ReadyQuery{{Name: "d"}}},
+	}
+
+	testCases := []struct {
+		Name      string
+		DryRun    bool
+		Unhealthy bool
+		Checks    int32
+	}{
+		{"run", false, false, 2},
+		{"unhealthy", false, true, 2},
+		{"dry run", true, false, 0},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			database := pooledDb{fakeDb{target: Target{Name: "db"}}, &atomic.Int32{}, &atomic.Int32{}, tt.Unhealthy}
+			status := runSteps(database, steps, newCompareCoordinator(steps), lineProgress{}, tt.DryRun, false, false)
+
+			// Failed health checks are logged, and the steps still run
+			code, message := review([]TargetStatus{status})
+			assert.Equal(0, code, message)
+			assert.Equal(tt.Checks, database.checks.Load())
+			assert.Equal(int32(1), database.closes.Load())
+		})
+	}
+}
@@ -15,28 +15,33 @@ import (
 	"github.com/go-pg/pg/v10/types"
 )
 
-// Results information.
+// Postgres type OIDs which need decoding beyond plain text
+const (
+	pgBoolOid        = 16
+	pgByteaOid       = 17
+	pgInt8Oid        = 20
+	pgInt2Oid        = 21
+	pgInt4Oid        = 23
+	pgOidOid         = 26
+	pgFloat4Oid      = 700
+	pgFloat8Oid      = 701
+	pgDateOid        = 1082
+	pgTimeOid        = 1083
+	pgTimestampOid   = 1114
+	pgTimestamptzOid = 1184
+	pgNumericOid     = 1700
+)
+
+// Results scans the output of a go-pg query into a ResultSet.
 type Results struct {
-	results  [][]string
-	columns  []string
-	elements int
-	rows     int
+	set ResultSet
 }
 
 var _ orm.HooklessModel = (*Results)(nil)
 
 // Init initializes Results.
 func (results *Results) Init() error {
-	results.elements = 0
-	results.rows = 0
-
-	if s := results; len(s.results) >= 0 {
-		results.results = (s.results)[:0]
-	}
-
-	if s := results; len(s.columns) >= 0 {
-		results.columns = (s.columns)[:0]
-	}
+	results.set = ResultSet{Rows: make([][]interface{}, 0)}
 	return nil
 }
 
@@ -52,24 +57,75 @@ func (Results) AddColumnScanner(_ orm.ColumnScanner) error {
 
 // ScanColumn implements ColumnScanner interface.
 func (results *Results) ScanColumn(col types.ColumnInfo, rd types.Reader, n int) error {
-	tmp, err := rd.ReadFullTemp()
+	if col.Index == 0 {
+		results.set.Rows = append(results.set.Rows, make([]interface{}, 0, len(results.set.Columns)))
+	}
+
+	curRow := len(results.set.Rows) - 1
+	kind := pgColumnKind(col.DataType)
+	if curRow == 0 {
+		results.set.Columns = append(results.set.Columns, Column{Name: col.Name, Kind: kind})
+	}
+
+	value, err := scanPgValue(col.DataType, kind, rd, n)
 	if err != nil {
 		return err
 	}
 
-	curRow := len(results.results) - 1
+	results.set.Rows[curRow] = append(results.set.Rows[curRow], value)
+	return nil
+}
+
+// ResultSet returns the scanned rows.
+func (results *Results) ResultSet() ResultSet {
+	return results.set
+}
+
+// scanPgValue decodes a single text-format column value.
+// A length of -1 denotes NULL.
+func scanPgValue(oid int32, kind ColumnKind, rd types.Reader, n int) (interface{}, error) {
+	if n == -1 {
+		return nil, nil
+	}
+
+	if oid == pgByteaOid {
+		return types.ScanBytes(rd, n)
+	}
 
-	if col.Index == 0 {
-		results.results = append(results.results, []string{})
-		curRow = len(results.results) - 1
-		results.rows++
+	tmp, err := rd.ReadFullTemp()
+	if err != nil {
+		return nil, err
 	}
 
-	if curRow == 0 {
-		results.columns = append(results.columns, col.Name)
+	if (kind == KindDate || kind == KindTimestamp) && len(tmp) >= len(dateLayout) {
+		// Special values such as 'infinity' are kept as text
+		if tm, err := types.ParseTime(tmp); err == nil {
+			return tm, nil
+		}
 	}
+	return normalizeValue(string(tmp), kind), nil
+}
 
-	results.elements++
-	results.results[curRow] = append(results.results[curRow], string(tmp))
-	return nil
+// pgColumnKind maps a Postgres type OID to a ColumnKind.
+func pgColumnKind(oid int32) ColumnKind {
+	switch oid {
+	case pgInt2Oid, pgInt4Oid, pgInt8Oid, pgOidOid:
+		return KindInteger
+	case pgFloat4Oid, pgFloat8Oid:
+		return KindFloat
+	case pgNumericOid:
+		return KindDecimal
+	case pgBoolOid:
+		return KindBoolean
+	case pgDateOid:
+		return KindDate
+	case pgTimeOid:
+		return KindTime
+	case pgTimestampOid, pgTimestamptzOid:
+		return KindTimestamp
+	case pgByteaOid:
+		return KindBytes
+	default:
+		return KindString
+	}
 }
@@ -0,0 +1,275 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"google.golang.org/api/option"
+	secretmanager "google.golang.org/api/secretmanager/v1"
+)
+
+// Schemes of secret references
+const (
+	secretEnv   = "env"
+	secretFile  = "file"
+	secretVault = "vault"
+	secretAwsSm = "aws-sm"
+	secretGcpSm = "gcp-sm"
+)
+
+// secretReference matches target values which reference a secret,
+// rather than holding it.
+var secretReference = regexp.MustCompile(`^(env|file|vault|aws-sm|gcp-sm):(\S+)$`)
+
+// secretExempt are the target fields never holding secrets, which are
+// left as is.
+var secretExempt = map[string]bool{"name": true, "type": true}
+
+// fileURIFields may hold file: URIs of embedded databases, such as
+// SQLite, so their file: values are not references to secret files.
+var fileURIFields = map[string]bool{"path": true, "dsn": true}
+
+// gcpSecretOptions are the client options of Google Secret Manager,
+// set by tests.
+var gcpSecretOptions []option.ClientOption
+
+// secretResolver fetches the secret of a reference, which is given
+// without its scheme.
+type secretResolver func(target Target, ref string) (string, error)
+
+// secretResolvers maps the schemes of secret references to their
+// resolvers.
+var secretResolvers = map[string]secretResolver{
+	secretEnv:   resolveEnvSecret,
+	secretFile:  resolveFileSecret,
+	secretVault: resolveVaultSecret,
+	secretAwsSm: resolveAwsSecret,
+	secretGcpSm: resolveGcpSecret,
+}
+
+// resolveSecrets returns a target whose secret references, in any of
+// its string fields, nested blocks and string maps, are replaced by
+// their secrets. It is called just before connecting, so that secrets
+// never end up in the playbook. Errors name the reference, never the
+// secret.
+func resolveSecrets(target Target) (Target, error) {
+	resolved := target
+	err := resolveSecretFields(target, reflect.ValueOf(&resolved).Elem(), "")
+	return resolved, err
+}
+
+// resolveSecretFields resolves the fields of a struct in place. Blocks
+// and maps are copied first, as they are shared with the playbook.
+func resolveSecretFields(target Target, v reflect.Value, prefix string) error {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name := prefix + yamlFieldName(field)
+		if !field.IsExported() || secretExempt[name] {
+			continue
+		}
+
+		fv := v.Field(i)
+		switch fv.Kind() {
+		case reflect.String:
+			secret, err := resolveSecret(target, name, fv.String())
+			if err != nil {
+				return err
+			}
+			fv.SetString(secret)
+		case reflect.Ptr:
+			if fv.IsNil() || fv.Elem().Kind() != reflect.Struct {
+				continue
+			}
+			block := reflect.New(fv.Elem().Type())
+			block.Elem().Set(fv.Elem())
+			if err := resolveSecretFields(target, block.Elem(), name+"."); err != nil {
+				return err
+			}
+			fv.Set(block)
+		case reflect.Map:
+			if fv.IsNil() || fv.Type().Elem().Kind() != reflect.String {
+				continue
+			}
+			values := reflect.MakeMapWithSize(fv.Type(), fv.Len())
+			iter := fv.MapRange()
+			for iter.Next() {
+				secret, err := resolveSecret(target, fmt.Sprintf("%s.%s", name, iter.Key()), iter.Value().String())
+				if err != nil {
+					return err
+				}
+				values.SetMapIndex(iter.Key(), reflect.ValueOf(secret).Convert(fv.Type().Elem()))
+			}
+			fv.Set(values)
+		}
+	}
+	return nil
+}
+
+// yamlFieldName returns the playbook name of a field.
+func yamlFieldName(field reflect.StructField) string {
+	if tag := strings.Split(field.Tag.Get("yaml"), ",")[0]; tag != "" {
+		return tag
+	}
+	return strings.ToLower(field.Name)
+}
+
+// resolveSecret returns the secret of a value which references one,
+// or else the value itself.
+func resolveSecret(target Target, field string, value string) (string, error) {
+	match := secretReference.FindStringSubmatch(value)
+	if match == nil || (match[1] == secretFile && fileURIFields[field]) {
+		return value, nil
+	}
+
+	secret, err := secretResolvers[match[1]](target, match[2])
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve %s of target %s from %s: %s", field, target.Name, value, err)
+	}
+	registerSecret(secret)
+	return secret, nil
+}
+
+// resolveEnvSecret reads a secret from an environment variable.
+func resolveEnvSecret(target Target, name string) (string, error) {
+	secret, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("variable is not set")
+	}
+	return secret, nil
+}
+
+// resolveFileSecret reads a secret from a file, without its trailing
+// newline.
+func resolveFileSecret(target Target, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// resolveVaultSecret reads a key of a Vault secret, given as path#key.
+func resolveVaultSecret(target Target, ref string) (string, error) {
+	path, key, ok := splitSecretKey(ref)
+	if !ok {
+		return "", fmt.Errorf("expected vault:path#key")
+	}
+
+	client, err := newVaultClient(VaultAuth{})
+	if err != nil {
+		return "", err
+	}
+	data, err := client.read(path)
+	if err != nil {
+		return "", err
+	}
+	return secretKey(data, key)
+}
+
+// resolveAwsSecret reads a secret of AWS Secrets Manager, given as its
+// arn or name, with the AWS settings of the target. A #key suffix reads
+// a key of a JSON secret.
+func resolveAwsSecret(target Target, ref string) (string, error) {
+	id, key, keyed := splitSecretKey(ref)
+
+	sess, err := awsSession(target)
+	if err != nil {
+		return "", err
+	}
+	out, err := secretsmanager.New(sess).GetSecretValue(&secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(id),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	secret := aws.StringValue(out.SecretString)
+	if out.SecretString == nil {
+		secret = string(out.SecretBinary)
+	}
+	if !keyed {
+		return secret, nil
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(secret), &data); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object")
+	}
+	return secretKey(data, key)
+}
+
+// resolveGcpSecret reads a secret of Google Secret Manager, given as a
+// resource name, or a secret name in the project of the target. The
+// latest version is read unless the name has one.
+func resolveGcpSecret(target Target, name string) (string, error) {
+	if !strings.HasPrefix(name, "projects/") {
+		if target.Project == "" {
+			return "", fmt.Errorf("expected gcp-sm:projects/<project>/secrets/<name>, or a target project")
+		}
+		name = fmt.Sprintf("projects/%s/secrets/%s", target.Project, name)
+	}
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+
+	ctx := context.Background()
+	service, err := secretmanager.NewService(ctx, gcpSecretOptions...)
+	if err != nil {
+		return "", err
+	}
+	resp, err := service.Projects.Secrets.Versions.Access(name).Context(ctx).Do()
+	if err != nil {
+		return "", err
+	}
+	if resp.Payload == nil {
+		return "", fmt.Errorf("secret has no payload")
+	}
+	secret, err := base64.StdEncoding.DecodeString(resp.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("unable to decode secret payload")
+	}
+	return string(secret), nil
+}
+
+// splitSecretKey splits a reference of the form name#key.
+func splitSecretKey(ref string) (string, string, bool) {
+	i := strings.LastIndex(ref, "#")
+	if i <= 0 || i == len(ref)-1 {
+		return ref, "", false
+	}
+	return ref[:i], ref[i+1:], true
+}
+
+// secretKey returns a key of a secret holding several values.
+func secretKey(data map[string]interface{}, key string) (string, error) {
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("secret has no key %s", key)
+	}
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64, bool:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("key %s of secret is not a string", key)
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/option"
+)
+
+func TestResolveSecrets(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("SQL_RUNNER_TEST_PASSWORD", "s3cret")
+
+	file := filepath.Join(t.TempDir(), "passphrase")
+	if err := os.WriteFile(file, []byte("hunter2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	target := Target{
+		Name:      "env:NAME",
+		Type:      postgresType,
+		Host:      "localhost",
+		Username:  "sql_runner",
+		Password:  "env:SQL_RUNNER_TEST_PASSWORD",
+		Path:      "file:lite?mode=memory",
+		Dsn:       "file:generic?mode=memory&cache=shared",
+		SshTunnel: &SshTunnel{Host: "bastion", KeyPassphrase: "file:" + file},
+		Params:    map[string]string{"TIMEZONE": "UTC", "token": "env:SQL_RUNNER_TEST_PASSWORD"},
+	}
+
+	resolved, err := resolveSecrets(target)
+	assert.Nil(err)
+	assert.Equal("s3cret", resolved.Password)
+	assert.Equal("hunter2", resolved.SshTunnel.KeyPassphrase)
+	assert.Equal(map[string]string{"TIMEZONE": "UTC", "token": "s3cret"}, resolved.Params)
+
+	// Names, types and file: URIs of embedded databases are left as is
+	assert.Equal("env:NAME", resolved.Name)
+	assert.Equal(target.Path, resolved.Path)
+	assert.Equal(target.Dsn, resolved.Dsn)
+	assert.Equal("localhost", resolved.Host)
+
+	// The playbook keeps its references
+	assert.Equal("file:"+file, target.SshTunnel.KeyPassphrase)
+	assert.Equal("env:SQL_RUNNER_TEST_PASSWORD", target.Params["token"])
+}
+
+func TestResolveSecrets_Error(t *testing.T) {
+	t.Setenv("SQL_RUNNER_TEST_UNSET", "")
+	os.Unsetenv("SQL_RUNNER_TEST_UNSET")
+
+	testCases := []struct {
+		Name      string
+		Input     Target
+		ErrString string
+	}{
+		{
+			Name:      "unset_env",
+			Input:     Target{Name: "pg", Password: "env:SQL_RUNNER_TEST_UNSET"},
+			ErrString: "cannot resolve password of target pg from env:SQL_RUNNER_TEST_UNSET: variable is not set",
+		},
+		{
+			Name:      "missing_file",
+			Input:     Target{Name: "pg", SshTunnel: &SshTunnel{KeyPassphrase: "file:/nonexistent/passphrase"}},
+			ErrString: "cannot resolve ssh_tunnel.key_passphrase of target pg from file:/nonexistent/passphrase: open /nonexistent/passphrase: no such file or directory",
+		},
+		{
+			Name:      "vault_without_key",
+			Input:     Target{Name: "pg", Password: "vault:secret/data/pg"},
+			ErrString: "cannot resolve password of target pg from vault:secret/data/pg: expected vault:path#key",
+		},
+		{
+			Name:      "gcp_without_project",
+			Input:     Target{Name: "bq", CredentialsEnv: "gcp-sm:credentials"},
+			ErrString: "cannot resolve credentials_env of target bq from gcp-sm:credentials: expected gcp-sm:projects/<project>/secrets/<name>, or a target project",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			_, err := resolveSecrets(tt.Input)
+			assert.EqualError(t, err, tt.ErrString)
+		})
+	}
+}
+
+func TestResolveSecrets_Vault(t *testing.T) {
+	assert := assert.New(t)
+	fv := newFakeVault(t)
+	fv.secrets["sql-runner"] = map[string]interface{}{"username": "runner", "password": "s3cret", "port": 5432.0}
+
+	resolved, err := resolveSecrets(Target{
+		Name:     "pg",
+		Username: "vault:secret/data/sql-runner#username",
+		Password: "vault:kv/sql-runner#password",
+		Port:     "vault:secret/data/sql-runner#port",
+	})
+	assert.Nil(err)
+	assert.Equal("runner", resolved.Username)
+	assert.Equal("s3cret", resolved.Password)
+	assert.Equal("5432", resolved.Port)
+
+	_, err = resolveSecrets(Target{Name: "pg", Password: "vault:secret/data/sql-runner#missing"})
+	assert.EqualError(err, "cannot resolve password of target pg from vault:secret/data/sql-runner#missing: secret has no key missing")
+}
+
+func TestResolveSecrets_AwsSecretsManager(t *testing.T) {
+	assert := assert.New(t)
+	fa := newFakeAWS(t, 0)
+	arn := "arn:aws:secretsmanager:eu-west-1:123456789012:secret:sql-runner-AbCdEf"
+	fa.secrets = map[string]string{
+		arn:          `{"username": "runner", "password": "s3cret"}`,
+		"sql-runner": "plain",
+	}
+
+	target := fa.target()
+	target.Username = "aws-sm:" + arn + "#username"
+	target.Password = "aws-sm:" + arn + "#password"
+	target.Params = map[string]string{"plain": "aws-sm:sql-runner"}
+
+	resolved, err := resolveSecrets(target)
+	assert.Nil(err)
+	assert.Equal("runner", resolved.Username)
+	assert.Equal("s3cret", resolved.Password)
+	assert.Equal("plain", resolved.Params["plain"])
+
+	target.Password = "aws-sm:missing"
+	_, err = resolveSecrets(target)
+	if assert.NotNil(err) {
+		assert.Contains(err.Error(), "cannot resolve password of target redshift from aws-sm:missing: ResourceNotFoundException")
+	}
+}
+
+func TestResolveSecrets_GcpSecretManager(t *testing.T) {
+	assert := assert.New(t)
+
+	var names []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/"), ":access")
+		names = append(names, name)
+		w.Header().Set("Content-Type", "application/json")
+		if !strings.HasPrefix(name, "projects/sql-runner/secrets/credentials/") {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{"code": 404, "message": "Secret not found"}})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"name":    name,
+			"payload": map[string]string{"data": base64.StdEncoding.EncodeToString([]byte(`{"type": "service_account"}`))},
+		})
+	}))
+	defer server.Close()
+	gcpSecretOptions = []option.ClientOption{option.WithEndpoint(server.URL + "/"), option.WithoutAuthentication()}
+	defer func() { gcpSecretOptions = nil }()
+
+	resolved, err := resolveSecrets(Target{Name: "bq", Project: "sql-runner", CredentialsEnv: "gcp-sm:credentials"})
+	assert.Nil(err)
+	assert.Equal(`{"type": "service_account"}`, resolved.CredentialsEnv)
+
+	_, err = resolveSecrets(Target{Name: "bq", CredentialsEnv: "gcp-sm:projects/sql-runner/secrets/credentials/versions/3"})
+	assert.Nil(err)
+	assert.Equal([]string{
+		"projects/sql-runner/secrets/credentials/versions/latest",
+		"projects/sql-runner/secrets/credentials/versions/3",
+	}, names)
+
+	_, err = resolveSecrets(Target{Name: "bq", Project: "sql-runner", CredentialsEnv: "gcp-sm:missing"})
+	if assert.NotNil(err) {
+		assert.Contains(err.Error(), "Secret not found")
+	}
+}
+
+func TestRun_Secrets(t *testing.T) {
+	assert := assert.New(t)
+
+	pbp := NewYAMLFilePlaybookProvider("../integration/resources/good-generic.yml", nil)
+	pb, err := pbp.GetPlaybook()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp := NewFileSQLProvider("../integration/resources")
+
+	t.Setenv("SQL_RUNNER_TEST_DSN", pb.Targets[0].Dsn)
+	pb.Targets[0].Dsn = "env:SQL_RUNNER_TEST_DSN"
+	statuses := Run(*pb, sp, "", "", false, false, false, false, lineProgress{})
+	code, message := review(statuses)
+	assert.Equal(0, code, message)
+
+	os.Unsetenv("SQL_RUNNER_TEST_DSN")
+	statuses = Run(*pb, sp, "", "", false, false, false, false, lineProgress{})
+	code, message = review(statuses)
+	assert.Equal(5, code)
+	assert.Contains(message, "cannot resolve dsn of target My generic database from env:SQL_RUNNER_TEST_DSN: variable is not set")
+}
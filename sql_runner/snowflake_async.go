@@ -0,0 +1,363 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	sf "github.com/snowflakedb/gosnowflake"
+)
+
+// Polling of Snowflake queries, unless configured otherwise
+const (
+	snowflakeDefaultPollInterval    = 5 * time.Second
+	snowflakeDefaultMaxPollInterval = 60 * time.Second
+	snowflakeDefaultBackoff         = 2.0
+	snowflakeDefaultTimeout         = 24 * time.Hour
+	snowflakeMaxPollFailures        = 5 // Polls in a row which may fail before giving up
+)
+
+// SnowflakeAsync represents the asynchronous execution of the queries
+// of a Snowflake target, which are submitted and then polled for.
+type SnowflakeAsync struct {
+	PollInterval    int     `yaml:"poll_interval"`     // Seconds before the first poll
+	MaxPollInterval int     `yaml:"max_poll_interval"` // Seconds the interval grows up to
+	Backoff         float64 // Growth of the interval after each poll
+	Timeout         int     // Seconds to wait for a query, a day if unset
+	StateFile       string  `yaml:"state_file"` // Saves the ids of running queries, to resume them
+}
+
+// snowflakePolling is the validated polling of a target.
+type snowflakePolling struct {
+	interval, maxInterval time.Duration
+	backoff               float64
+	timeout               time.Duration
+}
+
+// newSnowflakePolling validates the async settings of a target,
+// defaulting those left unset. Targets without async settings poll
+// with the defaults.
+func newSnowflakePolling(settings *SnowflakeAsync) (snowflakePolling, error) {
+	polling := snowflakePolling{
+		interval:    snowflakeDefaultPollInterval,
+		maxInterval: snowflakeDefaultMaxPollInterval,
+		backoff:     snowflakeDefaultBackoff,
+		timeout:     snowflakeDefaultTimeout,
+	}
+	if settings == nil {
+		return polling, nil
+	}
+
+	if settings.PollInterval < 0 || settings.MaxPollInterval < 0 || settings.Timeout < 0 {
+		return polling, fmt.Errorf("async poll_interval, max_poll_interval and timeout cannot be negative")
+	}
+	if settings.Backoff != 0 && settings.Backoff < 1 {
+		return polling, fmt.Errorf("async backoff must be at least 1")
+	}
+
+	if settings.PollInterval > 0 {
+		polling.interval = time.Duration(settings.PollInterval) * time.Second
+	}
+	if settings.MaxPollInterval > 0 {
+		polling.maxInterval = time.Duration(settings.MaxPollInterval) * time.Second
+	}
+	if polling.maxInterval < polling.interval {
+		polling.maxInterval = polling.interval
+	}
+	if settings.Backoff != 0 {
+		polling.backoff = settings.Backoff
+	}
+	if settings.Timeout > 0 {
+		polling.timeout = time.Duration(settings.Timeout) * time.Second
+	}
+	return polling, nil
+}
+
+// snowflakeStatusConn is a connection checking the status of queries.
+type snowflakeStatusConn interface {
+	GetQueryStatus(ctx context.Context, queryID string) (*sf.SnowflakeQueryStatus, error)
+	Close() error
+}
+
+// errSnowflakeTimeout is returned when a query outlives the timeout.
+var errSnowflakeTimeout = errors.New("timed out")
+
+// poll waits for a query to complete, returning its error if it failed.
+// The connection is reopened after errors which are not about the
+// query, so that polling survives reconnects, unless polling keeps
+// failing.
+func (sp snowflakePolling) poll(queryID string, connect func() (snowflakeStatusConn, error)) error {
+	deadline := time.Now().Add(sp.timeout)
+	interval := sp.interval
+	failures := 0
+
+	var conn snowflakeStatusConn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	for {
+		if conn == nil {
+			c, err := connect()
+			if err != nil {
+				failures++
+				if failures >= snowflakeMaxPollFailures {
+					return errors.Wrap(err, fmt.Sprintf("cannot connect to poll for query %s", queryID))
+				}
+				log.Printf("WARNING: Cannot connect to poll for query %s, retrying: %s", queryID, err)
+			} else {
+				conn = c
+			}
+		}
+
+		if conn != nil {
+			status, err := conn.GetQueryStatus(context.Background(), queryID)
+			var sfErr *sf.SnowflakeError
+			switch {
+			case err == nil && status != nil && status.ErrorCode != "":
+				return errors.New(status.ErrorMessage)
+			case err == nil:
+				return nil
+			case isSnowflakeQueryRunningError(err):
+				failures = 0
+			case errors.As(err, &sfErr) && sfErr.Number == sf.ErrQueryStatus && len(sfErr.MessageArgs) > 0:
+				// The query failed with an error code
+				return err
+			case errors.As(err, &sfErr) && sfErr.Number != sf.ErrQueryStatus:
+				// The query id is malformed or the query failed
+				return err
+			default:
+				// No status, as for unknown query ids and expired
+				// sessions, or the connection was lost
+				failures++
+				if failures >= snowflakeMaxPollFailures {
+					return errors.Wrap(err, fmt.Sprintf("cannot poll for query %s", queryID))
+				}
+				log.Printf("WARNING: Cannot poll for query %s, reconnecting: %s", queryID, err)
+				conn.Close()
+				conn = nil
+			}
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			return errSnowflakeTimeout
+		}
+		time.Sleep(interval)
+		interval = time.Duration(float64(interval) * sp.backoff)
+		if interval > sp.maxInterval {
+			interval = sp.maxInterval
+		}
+	}
+}
+
+// snowflakeStateMu guards the state files of all targets.
+var snowflakeStateMu sync.Mutex
+
+// snowflakeState saves the ids of the queries submitted to a target
+// in a JSON file, so that a later run resumes waiting on them instead
+// of submitting them again. A query is keyed by its target, name and
+// script, so that a changed script is submitted anew.
+type snowflakeState struct {
+	path string
+}
+
+// snowflakeStateKey identifies a query of a target.
+func snowflakeStateKey(target string, query ReadyQuery) string {
+	sum := sha256.Sum256([]byte(query.Script))
+	return fmt.Sprintf("%s/%s/%s", target, query.Name, hex.EncodeToString(sum[:8]))
+}
+
+// read returns the saved query ids.
+func (ss snowflakeState) read() (map[string]string, error) {
+	ids := make(map[string]string)
+	data, err := os.ReadFile(ss.path)
+	if os.IsNotExist(err) {
+		return ids, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read async state_file: %s", err)
+	}
+	if err := json.Unmarshal(data, &ids); err != nil {
+		return nil, fmt.Errorf("unable to parse async state_file: %s", err)
+	}
+	return ids, nil
+}
+
+// load returns the saved id of a query, if any.
+func (ss snowflakeState) load(key string) (string, error) {
+	snowflakeStateMu.Lock()
+	defer snowflakeStateMu.Unlock()
+
+	ids, err := ss.read()
+	if err != nil {
+		return "", err
+	}
+	return ids[key], nil
+}
+
+// save sets the id of a query, removing it when empty. The file is
+// replaced atomically.
+func (ss snowflakeState) save(key string, queryID string) error {
+	snowflakeStateMu.Lock()
+	defer snowflakeStateMu.Unlock()
+
+	ids, err := ss.read()
+	if err != nil {
+		return err
+	}
+	if queryID == "" {
+		delete(ids, key)
+	} else {
+		ids[key] = queryID
+	}
+
+	data, err := json.MarshalIndent(ids, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(ss.path), filepath.Base(ss.path)+".*")
+	if err != nil {
+		return fmt.Errorf("unable to write async state_file: %s", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to write async state_file: %s", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to write async state_file: %s", err)
+	}
+	if err := os.Rename(tmp.Name(), ss.path); err != nil {
+		return fmt.Errorf("unable to write async state_file: %s", err)
+	}
+	return nil
+}
+
+// runAsync submits a query in async mode and polls until it completes.
+// A query saved in the state file by an earlier run is waited on
+// instead, if it is still running or has succeeded. Rows affected are
+// not reported in async mode.
+func (sft SnowflakeTarget) runAsync(query ReadyQuery) QueryStatus {
+	if len(strings.TrimSpace(query.Script)) == 0 {
+		return QueryStatus{Query: query, Path: query.Path}
+	}
+
+	var state *snowflakeState
+	key := snowflakeStateKey(sft.Name, query)
+	if sft.Async.StateFile != "" {
+		state = &snowflakeState{sft.Async.StateFile}
+
+		queryID, err := state.load(key)
+		if err != nil {
+			log.Printf("ERROR: %s.", err)
+			return QueryStatus{Query: query, Path: query.Path, Error: err}
+		}
+		if queryID != "" {
+			log.Printf("RESUMING %s on Snowflake query %s", query.Name, queryID)
+			err := sft.wait(queryID, state, key)
+			if err == nil || err == errSnowflakeTimeout {
+				return QueryStatus{Query: query, Path: query.Path, Error: sft.asyncError(queryID, err, state)}
+			}
+			log.Printf("WARNING: Snowflake query %s of %s did not succeed, submitting it again: %s", queryID, query.Name, err)
+		}
+	}
+
+	queryIDChannel := make(chan string, 1)
+	ctx := sf.WithQueryIDChan(sf.WithAsyncMode(context.Background()), queryIDChannel)
+	ctx, err := sf.WithMultiStatement(ctx, 0)
+	if err != nil {
+		log.Printf("ERROR: Could not initialise query script.")
+		return QueryStatus{Query: query, Path: query.Path, Error: err}
+	}
+
+	if _, err := sft.Client.ExecContext(ctx, query.Script); err != nil {
+		log.Printf("ERROR: %s.", err)
+		return QueryStatus{Query: query, Path: query.Path, Error: err}
+	}
+
+	var queryID string
+	select {
+	case queryID = <-queryIDChannel:
+	default:
+		err := fmt.Errorf("no query id was returned for %s", query.Name)
+		log.Printf("ERROR: %s.", err)
+		return QueryStatus{Query: query, Path: query.Path, Error: err}
+	}
+	log.Printf("SUBMITTED %s as Snowflake query %s", query.Name, queryID)
+
+	if state != nil {
+		if err := state.save(key, queryID); err != nil {
+			log.Printf("WARNING: %s, the query cannot be resumed.", err)
+		}
+	}
+
+	err = sft.wait(queryID, state, key)
+	return QueryStatus{Query: query, Path: query.Path, Error: sft.asyncError(queryID, err, state)}
+}
+
+// wait polls for a query, forgetting it in the state file once it
+// completed. Queries which timed out are kept, to be resumed.
+func (sft SnowflakeTarget) wait(queryID string, state *snowflakeState, key string) error {
+	err := sft.polling.poll(queryID, sft.statusConn)
+	if err != errSnowflakeTimeout && state != nil {
+		if err := state.save(key, ""); err != nil {
+			log.Printf("WARNING: %s.", err)
+		}
+	}
+	return err
+}
+
+// asyncError describes the outcome of a query. Queries which timed out
+// are cancelled, unless they are resumed by the next run.
+func (sft SnowflakeTarget) asyncError(queryID string, err error, state *snowflakeState) error {
+	switch {
+	case err == nil:
+		return nil
+	case err == errSnowflakeTimeout && state != nil:
+		err = fmt.Errorf("QueryID: %s: still running after %s, it is resumed by the next run", queryID, sft.polling.timeout)
+	case err == errSnowflakeTimeout:
+		if _, cancelErr := sft.Client.Exec("SELECT SYSTEM$CANCEL_QUERY(?)", queryID); cancelErr != nil {
+			log.Printf("WARNING: Cannot cancel Snowflake query %s: %s", queryID, cancelErr)
+		}
+		err = fmt.Errorf("QueryID: %s: cancelled, still running after %s", queryID, sft.polling.timeout)
+	default:
+		err = errors.Wrap(err, fmt.Sprintf("QueryID: %s", queryID))
+	}
+	log.Printf("ERROR: %s.", err)
+	return err
+}
+
+// statusConn opens a connection to poll for queries.
+func (sft SnowflakeTarget) statusConn() (snowflakeStatusConn, error) {
+	conn, err := sft.Client.Driver().Open(sft.Dsn)
+	if err != nil {
+		return nil, err
+	}
+	statusConn, ok := conn.(snowflakeStatusConn)
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("connection cannot poll for queries")
+	}
+	return statusConn, nil
+}
@@ -0,0 +1,189 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	sf "github.com/snowflakedb/gosnowflake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSnowflakePolling(t *testing.T) {
+	assert := assert.New(t)
+
+	polling, err := newSnowflakePolling(nil)
+	assert.Nil(err)
+	assert.Equal(snowflakePolling{5 * time.Second, 60 * time.Second, 2, 24 * time.Hour}, polling)
+
+	polling, err = newSnowflakePolling(&SnowflakeAsync{PollInterval: 90, Backoff: 1.5, Timeout: 600})
+	assert.Nil(err)
+	assert.Equal(snowflakePolling{90 * time.Second, 90 * time.Second, 1.5, 600 * time.Second}, polling)
+
+	testCases := []struct {
+		Name      string
+		Input     SnowflakeAsync
+		ErrString string
+	}{
+		{"negative interval", SnowflakeAsync{PollInterval: -1}, "async poll_interval, max_poll_interval and timeout cannot be negative"},
+		{"negative timeout", SnowflakeAsync{Timeout: -1}, "async poll_interval, max_poll_interval and timeout cannot be negative"},
+		{"shrinking backoff", SnowflakeAsync{Backoff: 0.5}, "async backoff must be at least 1"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			_, err := newSnowflakePolling(&tt.Input)
+			assert.EqualError(err, tt.ErrString)
+		})
+	}
+}
+
+// fakeStatusConn replies to status checks with the next of its errors,
+// succeeding once they are used up.
+type fakeStatusConn struct {
+	errs   *[]error
+	closed *int
+}
+
+func (fc fakeStatusConn) GetQueryStatus(ctx context.Context, queryID string) (*sf.SnowflakeQueryStatus, error) {
+	if len(*fc.errs) == 0 {
+		return &sf.SnowflakeQueryStatus{}, nil
+	}
+	err := (*fc.errs)[0]
+	*fc.errs = (*fc.errs)[1:]
+	return nil, err
+}
+
+func (fc fakeStatusConn) Close() error {
+	*fc.closed++
+	return nil
+}
+
+func TestSnowflakePolling_Poll(t *testing.T) {
+	running := &sf.SnowflakeError{Number: sf.ErrQueryIsRunning}
+	noStatus := &sf.SnowflakeError{Number: sf.ErrQueryStatus}
+	failed := &sf.SnowflakeError{Number: sf.ErrQueryStatus, Message: "SQL compilation error", MessageArgs: []interface{}{"002003"}}
+	lost := errors.New("connection reset by peer")
+	badID := &sf.SnowflakeError{Number: sf.ErrQueryIDFormat, Message: "invalid query ID format"}
+
+	polling := snowflakePolling{time.Millisecond, 4 * time.Millisecond, 2, time.Minute}
+
+	testCases := []struct {
+		Name       string
+		Polling    snowflakePolling
+		Errs       []error
+		Connects   int
+		ErrString  string
+		TimedOut   bool
+		FailsFirst bool
+	}{
+		{"succeeds", polling, []error{running, running}, 1, "", false, false},
+		{"reconnects", polling, []error{running, lost, running, noStatus, running, lost}, 4, "", false, false},
+		{"retries connecting", polling, []error{running}, 2, "", false, true},
+		{"fails", polling, []error{running, failed}, 1, "SQL compilation error", false, false},
+		{"bad query id", polling, []error{badID}, 1, "invalid query ID format", false, false},
+		{"unknown query", polling, []error{running, noStatus, noStatus, noStatus, noStatus, noStatus}, 5, "cannot poll for query 01b2c3d4", false, false},
+		{"keeps losing connection", polling, []error{lost, lost, running, lost, lost, lost, lost, lost}, 7, "cannot poll for query 01b2c3d4", false, false},
+		{"times out", snowflakePolling{time.Millisecond, 2 * time.Millisecond, 2, 20 * time.Millisecond}, make([]error, 0, 100), 1, "", true, false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			errs := tt.Errs
+			if tt.TimedOut {
+				for i := 0; i < cap(errs); i++ {
+					errs = append(errs, running)
+				}
+			}
+			connects, closed := 0, 0
+			connect := func() (snowflakeStatusConn, error) {
+				connects++
+				if tt.FailsFirst && connects == 1 {
+					return nil, errors.New("no route to host")
+				}
+				return fakeStatusConn{&errs, &closed}, nil
+			}
+
+			err := tt.Polling.poll("01b2c3d4", connect)
+			switch {
+			case tt.TimedOut:
+				assert.Equal(errSnowflakeTimeout, err)
+			case tt.ErrString != "":
+				assert.ErrorContains(err, tt.ErrString)
+				assert.Equal(tt.Connects, connects)
+			default:
+				assert.Nil(err)
+				assert.Equal(tt.Connects, connects)
+			}
+			// Every connection opened is closed
+			if tt.FailsFirst {
+				assert.Equal(connects-1, closed)
+			} else {
+				assert.Equal(connects, closed)
+			}
+		})
+	}
+}
+
+func TestSnowflakeState(t *testing.T) {
+	assert := assert.New(t)
+
+	state := snowflakeState{filepath.Join(t.TempDir(), "state.json")}
+	query := ReadyQuery{Script: "SELECT 1;", Name: "one"}
+	key := snowflakeStateKey("snowflake", query)
+
+	queryID, err := state.load(key)
+	assert.Nil(err)
+	assert.Equal("", queryID)
+
+	assert.Nil(state.save(key, "01b2c3d4"))
+	assert.Nil(state.save(snowflakeStateKey("snowflake", ReadyQuery{Script: "SELECT 2;", Name: "one"}), "01b2c3d5"))
+
+	// A restarted run finds the query, unless its script changed
+	queryID, err = (snowflakeState{state.path}).load(key)
+	assert.Nil(err)
+	assert.Equal("01b2c3d4", queryID)
+
+	assert.Nil(state.save(key, ""))
+	queryID, err = state.load(key)
+	assert.Nil(err)
+	assert.Equal("", queryID)
+
+	queryID, err = state.load(snowflakeStateKey("snowflake", ReadyQuery{Script: "SELECT 2;", Name: "one"}))
+	assert.Nil(err)
+	assert.Equal("01b2c3d5", queryID)
+}
+
+func TestNewSnowflakeTarget_Async(t *testing.T) {
+	pb, err := parsePlaybookYaml([]byte(`
+targets:
+  - name: snowflake
+    type: snowflake
+    async:
+      poll_interval: 10
+      max_poll_interval: 120
+      backoff: 1.5
+      timeout: 3600
+      state_file: /tmp/sql-runner.json
+steps: []
+`), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, &SnowflakeAsync{10, 120, 1.5, 3600, "/tmp/sql-runner.json"}, pb.Targets[0].Async)
+
+	_, err = NewSnowflakeTarget(Target{Name: "snowflake", Type: snowflakeType, Account: "acme", Username: "runner", Password: "secret", Async: &SnowflakeAsync{Backoff: 0.5}})
+	assert.EqualError(t, err, "async backoff must be at least 1")
+}
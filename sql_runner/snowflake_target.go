@@ -15,14 +15,16 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"database/sql"
+	"database/sql/driver"
 	"encoding/pem"
 	"fmt"
 	"log"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/olekukonko/tablewriter"
 	"github.com/pkg/errors"
 	sf "github.com/snowflakedb/gosnowflake"
 )
@@ -32,13 +34,27 @@ const (
 	snowplowAppName = `Snowplow_OSS`
 	loginTimeout    = 5 * time.Second                // by default is 60
 	multiStmtName   = "multiple statement execution" // https://github.com/snowflakedb/gosnowflake/blob/e909f00ff624a7e60d4f91718f6adc92cbd0d80f/connection.go#L57-L61
+
+	// Whether the external browser authentication may be used. It is
+	// disabled by default, as it blocks unattended runs
+	snowflakeBrowserDisabled = "disabled"
+	snowflakeBrowserEnforced = "enforced"
 )
 
+// snowflakeSession matches the statements which only change the
+// session, which ValidateQuery runs so that later statements see them.
+var snowflakeSession = regexp.MustCompile(`(?is)^(use|set|unset|alter\s+session)\b`)
+
+// snowflakeCreate captures the name of the object a CREATE statement
+// creates.
+var snowflakeCreate = regexp.MustCompile(`(?is)^create\s+(or\s+replace\s+)?(\w+\s+)*?(table|view|schema|database|sequence|stage|stream|task|function|procedure|pipe|file\s+format)\s+(if\s+not\s+exists\s+)?([\w$."]+)`)
+
 // SnowflakeTarget represents Snowflake as target.
 type SnowflakeTarget struct {
 	Target
-	Client *sql.DB
-	Dsn    string
+	Client  *sql.DB
+	Dsn     string
+	polling snowflakePolling
 }
 
 // IsConnectable tests connection to determine whether the Snowflake target is
@@ -84,11 +100,100 @@ func parsePrivateKey(path string, passphrase string) (*rsa.PrivateKey, error) {
 	return privateKey, nil
 }
 
+func init() {
+	registerTarget(NewSnowflakeTarget, snowflakeType)
+	registerTargetValidator(validateSnowflakeTarget, snowflakeType)
+}
+
 // NewSnowflakeTarget returns a ptr to a SnowflakeTarget.
 func NewSnowflakeTarget(target Target) (*SnowflakeTarget, error) {
-	params := make(map[string]*string)
-	if target.QueryTag != "" {
-		params["QUERY_TAG"] = &target.QueryTag
+	config, err := snowflakeConfig(target)
+	if err != nil {
+		return nil, err
+	}
+
+	polling, err := newSnowflakePolling(target.Async)
+	if err != nil {
+		return nil, err
+	}
+
+	configStr, err := sf.DSN(config)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("snowflake", configStr)
+	if err != nil {
+		return nil, err
+	}
+	if err := configurePool(db, target); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SnowflakeTarget{target, db, configStr, polling}, nil
+}
+
+// validateSnowflakeTarget checks the options of a Snowflake target,
+// which must use exactly one of a password, a key pair, an OAuth token
+// or, when enforced, an external browser. Options which are secret
+// references are checked once resolved.
+func validateSnowflakeTarget(target Target) error {
+	if target.Account == "" && target.Host == "" {
+		return fmt.Errorf("missing target account or host")
+	}
+	if _, err := snowflakeParams(target); err != nil {
+		return err
+	}
+	if target.Port != "" && !secretReference.MatchString(target.Port) {
+		if port, err := strconv.Atoi(target.Port); err != nil || port <= 0 || port > 65535 {
+			return fmt.Errorf("invalid target port %q", target.Port)
+		}
+	}
+
+	browser := false
+	switch strings.ToLower(target.ExternalBrowser) {
+	case "", snowflakeBrowserDisabled:
+	case snowflakeBrowserEnforced:
+		browser = true
+	default:
+		if !secretReference.MatchString(target.ExternalBrowser) {
+			return fmt.Errorf("unsupported external_browser %q (supported: %s, %s)",
+				target.ExternalBrowser, snowflakeBrowserDisabled, snowflakeBrowserEnforced)
+		}
+	}
+
+	if target.OauthTokenFile != "" && target.OauthTokenEnv != "" {
+		return fmt.Errorf("oauth_token_file and oauth_token_env are mutually exclusive")
+	}
+	token := target.OauthTokenFile != "" || target.OauthTokenEnv != ""
+	methods := 0
+	for _, set := range []bool{target.Password != "", target.PrivateKeyPath != "", token, browser} {
+		if set {
+			methods++
+		}
+	}
+	if methods > 1 {
+		return fmt.Errorf("only one of password, private_key_path, oauth token or external_browser enforced may be used")
+	}
+	if target.Username == "" && !token {
+		return fmt.Errorf("missing target username")
+	}
+
+	_, err := newSnowflakePolling(target.Async)
+	return err
+}
+
+// snowflakeConfig validates a Snowflake target and returns its driver
+// configuration.
+func snowflakeConfig(target Target) (*sf.Config, error) {
+	if err := validateSnowflakeTarget(target); err != nil {
+		return nil, err
+	}
+
+	params, err := snowflakeParams(target)
+	if err != nil {
+		return nil, err
 	}
 
 	config := &sf.Config{
@@ -96,21 +201,25 @@ func NewSnowflakeTarget(target Target) (*SnowflakeTarget, error) {
 		Account:      target.Account,
 		User:         target.Username,
 		Database:     target.Database,
+		Schema:       target.Schema,
 		Warehouse:    target.Warehouse,
+		Role:         target.Role,
 		LoginTimeout: loginTimeout,
 		Params:       params,
 	}
 
-	// Set authentication method based on available credentials
-	if target.PrivateKeyPath != "" {
-		config.Authenticator = sf.AuthTypeJwt
-		privateKey, err := parsePrivateKey(target.PrivateKeyPath, target.PrivateKeyPassphrase)
-		if err != nil {
-			return nil, err
-		}
-		config.PrivateKey = privateKey
-	} else {
-		config.Password = target.Password
+	// Private link and proxies are reached on a custom host and port
+	if target.Host != "" {
+		config.Host = target.Host
+		config.Protocol = "https"
+	}
+	if target.Port != "" {
+		// Validated above
+		config.Port, _ = strconv.Atoi(target.Port)
+	}
+
+	if err := setSnowflakeAuthenticator(target, config); err != nil {
+		return nil, err
 	}
 
 	if envAppName := os.Getenv(`SNOWPLOW_SQL_RUNNER_SNOWFLAKE_APP_NAME`); envAppName != `` {
@@ -119,17 +228,80 @@ func NewSnowflakeTarget(target Target) (*SnowflakeTarget, error) {
 		config.Application = snowplowAppName
 	}
 
-	configStr, err := sf.DSN(config)
-	if err != nil {
-		return nil, err
+	return config, nil
+}
+
+// snowflakeParams returns the session parameters of a target, from
+// query_tag and params. Names are case insensitive, so each may only
+// be set once.
+func snowflakeParams(target Target) (map[string]*string, error) {
+	params := make(map[string]*string)
+	if target.QueryTag != "" {
+		params["QUERY_TAG"] = &target.QueryTag
 	}
 
-	db, err := sql.Open("snowflake", configStr)
+	for name, value := range target.Params {
+		key := strings.ToUpper(name)
+		if _, ok := params[key]; ok {
+			return nil, fmt.Errorf("session parameter %s is set more than once", key)
+		}
+		params[key] = &value
+	}
+	return params, nil
+}
+
+// setSnowflakeAuthenticator sets the authentication of a validated
+// target.
+func setSnowflakeAuthenticator(target Target, config *sf.Config) error {
+	token, err := snowflakeOAuthToken(target)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return &SnowflakeTarget{target, db, configStr}, nil
+	switch {
+	case target.PrivateKeyPath != "":
+		config.Authenticator = sf.AuthTypeJwt
+		privateKey, err := parsePrivateKey(target.PrivateKeyPath, target.PrivateKeyPassphrase)
+		if err != nil {
+			return err
+		}
+		config.PrivateKey = privateKey
+	case token != "":
+		config.Authenticator = sf.AuthTypeOAuth
+		config.Token = token
+	case strings.EqualFold(target.ExternalBrowser, snowflakeBrowserEnforced):
+		config.Authenticator = sf.AuthTypeExternalBrowser
+	default:
+		config.Password = target.Password
+	}
+	return nil
+}
+
+// snowflakeOAuthToken reads the OAuth token of a target from its file
+// or environment variable, if it has one.
+func snowflakeOAuthToken(target Target) (string, error) {
+	switch {
+	case target.OauthTokenFile != "" && target.OauthTokenEnv != "":
+		return "", fmt.Errorf("oauth_token_file and oauth_token_env are mutually exclusive")
+	case target.OauthTokenFile != "":
+		token, err := os.ReadFile(target.OauthTokenFile)
+		if err != nil {
+			return "", fmt.Errorf("unable to read oauth_token_file: %s", err)
+		}
+		if strings.TrimSpace(string(token)) == "" {
+			return "", fmt.Errorf("oauth_token_file %s is empty", target.OauthTokenFile)
+		}
+		registerSecret(strings.TrimSpace(string(token)))
+		return strings.TrimSpace(string(token)), nil
+	case target.OauthTokenEnv != "":
+		token := strings.TrimSpace(os.Getenv(target.OauthTokenEnv))
+		if token == "" {
+			return "", fmt.Errorf("oauth_token_env %s is not set", target.OauthTokenEnv)
+		}
+		registerSecret(token)
+		return token, nil
+	}
+	return "", nil
 }
 
 // GetTarget returns the Target field of SnowflakeTarget.
@@ -137,19 +309,26 @@ func (sft SnowflakeTarget) GetTarget() Target {
 	return sft.Target
 }
 
+// Close closes the client of the Snowflake target.
+func (sft SnowflakeTarget) Close() error {
+	return sft.Client.Close()
+}
+
+// CheckHealth replaces dead pooled connections of the Snowflake target.
+func (sft SnowflakeTarget) CheckHealth() error {
+	return checkSQLHealth(sft.Client, sft.Target)
+}
+
 // RunQuery runs a query against the target
 func (sft SnowflakeTarget) RunQuery(query ReadyQuery, dryRun bool, showQueryOutput bool) QueryStatus {
 	var affected int64 = 0
 	var err error
 
 	if dryRun {
-		if sft.IsConnectable() {
-			log.Printf("SUCCESS: Able to connect to target database, %s\n.", sft.Account)
-		} else {
-			log.Printf("ERROR: Cannot connect to target database, %s\n.", sft.Account)
-		}
-
-		return QueryStatus{query, query.Path, 0, nil}
+		return QueryStatus{Query: query, Path: query.Path}
+	}
+	if sft.Async != nil && !showQueryOutput {
+		return sft.runAsync(query)
 	}
 
 	// Enable grabbing the queryID
@@ -164,30 +343,22 @@ func (sft SnowflakeTarget) RunQuery(query ReadyQuery, dryRun bool, showQueryOutp
 	ctx, err := sf.WithMultiStatement(ctxWithQueryIDChan, 0)
 	if err != nil {
 		log.Printf("ERROR: Could not initialise query script.")
-		return QueryStatus{query, query.Path, 0, err}
+		return QueryStatus{Query: query, Path: query.Path, Error: err}
 	}
 	script := query.Script
 
 	if len(strings.TrimSpace(script)) > 0 {
 		if showQueryOutput {
-			rows, err := sft.Client.QueryContext(ctx, script)
+			sets, err := sft.queryResults(ctx, script)
 			if err != nil {
-				return QueryStatus{query, query.Path, int(affected), err}
+				log.Printf("ERROR: %s.", err)
+				return QueryStatus{Query: query, Path: query.Path, Affected: int(affected), Error: err}
 			}
-			defer rows.Close()
 
-			err = printSfTable(rows)
+			err = printTables(sets)
 			if err != nil {
 				log.Printf("ERROR: %s.", err)
-				return QueryStatus{query, query.Path, int(affected), err}
-			}
-
-			for rows.NextResultSet() {
-				err = printSfTable(rows)
-				if err != nil {
-					log.Printf("ERROR: %s.", err)
-					return QueryStatus{query, query.Path, int(affected), err}
-				}
+				return QueryStatus{Query: query, Path: query.Path, Affected: int(affected), Error: err}
 			}
 		} else {
 			res, err := sft.Client.ExecContext(ctx, script)
@@ -196,113 +367,134 @@ func (sft SnowflakeTarget) RunQuery(query ReadyQuery, dryRun bool, showQueryOutp
 				queryID := <-goroutineQIDChannel
 				if isSnowflakeUnknownError(err) {
 					log.Println("INFO: Encountered -1 status. Polling for query result with queryID: ", queryID)
-					pollResult := pollForQueryStatus(sft, queryID)
-					return QueryStatus{query, query.Path, int(affected), pollResult}
+					pollResult := sft.polling.poll(queryID, sft.statusConn)
+					return QueryStatus{Query: query, Path: query.Path, Affected: int(affected), Error: errors.Wrap(pollResult, fmt.Sprintf("QueryID: %s", queryID))}
 				}
 
-				return QueryStatus{query, query.Path, int(affected), errors.Wrap(err, fmt.Sprintf("QueryID: %s", queryID))}
+				return QueryStatus{Query: query, Path: query.Path, Affected: int(affected), Error: errors.Wrap(err, fmt.Sprintf("QueryID: %s", queryID))}
 			}
 			aff, _ := res.RowsAffected()
 			affected += aff
 		}
 	}
 
-	return QueryStatus{query, query.Path, int(affected), err}
+	return QueryStatus{Query: query, Path: query.Path, Affected: int(affected), Error: err}
 }
 
-func printSfTable(rows *sql.Rows) error {
-	outputBuffer := make([][]string, 0, 10)
-	cols, err := rows.Columns()
+// ValidateQuery compiles each statement of a query in describe-only
+// mode, which reports syntax errors and missing objects without
+// running it. Session statements, such as USE, are run instead on the
+// one connection the script is validated on. DDL cannot be rolled
+// back, so statements using objects created earlier in the script are
+// skipped.
+func (sft SnowflakeTarget) ValidateQuery(query ReadyQuery) QueryStatus {
+	ctx := context.Background()
+	conn, err := sft.Client.Conn(ctx)
 	if err != nil {
-		return errors.New("Unable to read columns")
+		return QueryStatus{Query: query, Path: query.Path, Error: err}
 	}
-
-	// check to prevent rows.Next() on multi-statement
-	// see also: https://github.com/snowflakedb/gosnowflake/issues/365
-	for _, c := range cols {
-		if c == multiStmtName {
-			return errors.New("Unable to showQueryOutput for multi-statement queries")
+	defer conn.Close()
+	// Session statements change the connection, so it is not reused
+	defer conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	describeOnly := sf.WithDescribeOnly(ctx)
+
+	statements := splitStatements(query.Script, snowflakeDialect)
+	var created []string
+	validated := 0
+	for i, statement := range statements {
+		trimmed := trimLeadingComments(statement)
+		if object := snowflakeDependency(trimmed, created); object != "" {
+			log.Printf("INFO: Skipping statement %d of %s, which uses %s created earlier in the script", i+1, query.Name, object)
+			continue
 		}
-	}
-
-	vals := make([]interface{}, len(cols))
-	rawResult := make([][]byte, len(cols))
-	for i := range rawResult {
-		vals[i] = &rawResult[i]
-	}
 
-	for rows.Next() {
-		err = rows.Scan(vals...)
-		if err != nil {
-			return errors.New("Unable to read row")
+		runCtx := describeOnly
+		if snowflakeSession.MatchString(trimmed) {
+			runCtx = ctx
 		}
-
-		if len(vals) > 0 {
-			outputBuffer = append(outputBuffer, stringify(rawResult))
+		if _, err := conn.ExecContext(runCtx, statement); err != nil {
+			log.Printf("ERROR: %s.", err)
+			return QueryStatus{Query: query, Path: query.Path, Error: fmt.Errorf("statement %d: %s", i+1, err)}
+		}
+		if parts := snowflakeCreate.FindStringSubmatch(trimmed); parts != nil {
+			created = append(created, snowflakeObjectName(parts[5]))
 		}
+		validated++
 	}
 
-	if len(outputBuffer) > 0 {
-		log.Printf("QUERY OUTPUT:\n")
-		table := tablewriter.NewWriter(os.Stdout)
-		table.SetHeader(cols)
-		table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
-		table.SetCenterSeparator("|")
-
-		for _, row := range outputBuffer {
-			table.Append(row)
-		}
+	log.Printf("VALIDATED %d of %d statements of %s", validated, len(statements), query.Name)
+	return QueryStatus{Query: query, Path: query.Path}
+}
 
-		table.Render() // Send output
-	}
-	return nil
+// snowflakeObjectName returns the unqualified, unquoted name of an
+// object.
+func snowflakeObjectName(name string) string {
+	name = name[strings.LastIndex(name, ".")+1:]
+	return strings.Trim(name, `"`)
 }
 
-func stringify(row [][]byte) []string {
-	var line []string
-	for _, element := range row {
-		line = append(line, fmt.Sprint(string(element)))
+// snowflakeDependency returns the first of the created objects which a
+// statement uses, or an empty string.
+func snowflakeDependency(statement string, created []string) string {
+	for _, object := range created {
+		uses := regexp.MustCompile(`(?i)(^|[^\w$])` + regexp.QuoteMeta(object) + `($|[^\w$])`)
+		if uses.MatchString(statement) {
+			return object
+		}
 	}
-	return line
+	return ""
 }
 
-// getQueryID reads from queryIDch and writes to goroutineCh.
-// If goroutineCh is unbuffered (as is being used above), it blocks.
-func getQueryID(goroutineCh chan string, queryIDch chan string) {
-	queryID := <-queryIDch
-	goroutineCh <- queryID
+// FetchResults runs a query against the target and returns its output.
+func (sft SnowflakeTarget) FetchResults(query ReadyQuery) ([]ResultSet, error) {
+	ctx, err := sf.WithMultiStatement(context.Background(), 0)
+	if err != nil {
+		return nil, err
+	}
+	return sft.queryResults(ctx, query.Script)
 }
 
-// Blocking function to poll for the true status of a query which didn't return a result.
-func pollForQueryStatus(sft SnowflakeTarget, queryID string) error {
-	// Get the snoflake driver and open a connection
-	sfd := sft.Client.Driver()
-	conn, err := sfd.Open(sft.Dsn)
+// queryResults reads every result set produced by a script.
+func (sft SnowflakeTarget) queryResults(ctx context.Context, script string) ([]ResultSet, error) {
+	rows, err := sft.Client.QueryContext(ctx, script)
 	if err != nil {
-		return errors.Wrap(err, "Failed to open connection to poll for query result.")
+		return nil, err
 	}
-	// Poll Snowflake for actual query status
+	defer rows.Close()
+
+	sets := make([]ResultSet, 0, 1)
 	for {
-		qStatus, err := conn.(sf.SnowflakeConnection).GetQueryStatus(context.Background(), queryID)
+		cols, err := rows.Columns()
+		if err != nil {
+			return nil, errors.New("Unable to read columns")
+		}
 
-		switch {
-		case err != nil && isSnowflakeQueryRunningError(err):
-			break
-		case err != nil:
-			// Any other error is genuine, return the error.
-			return err
-		case qStatus != nil && qStatus.ErrorCode == "":
-			// A non-nil qStatus means the query completed. If the ErrorCode field is empty string, we have no error.
-			return nil
-		case qStatus != nil:
-			// If qStatus is non-nil but has a non-zero error code, return the relevant info as an error.
-			return errors.New(qStatus.ErrorMessage)
-		default:
+		// check to prevent rows.Next() on multi-statement
+		// see also: https://github.com/snowflakedb/gosnowflake/issues/365
+		for _, c := range cols {
+			if c == multiStmtName {
+				return nil, errors.New("Unable to showQueryOutput for multi-statement queries")
+			}
+		}
+
+		rs, err := resultSetFromRows(rows, nil)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, rs)
+
+		if !rows.NextResultSet() {
 			break
 		}
-		// Give it a minute before polling again.
-		time.Sleep(60 * time.Second)
 	}
+	return sets, rows.Err()
+}
+
+// getQueryID reads from queryIDch and writes to goroutineCh.
+// If goroutineCh is unbuffered (as is being used above), it blocks.
+func getQueryID(goroutineCh chan string, queryIDch chan string) {
+	queryID := <-queryIDch
+	goroutineCh <- queryID
 }
 
 // isSnowflakeErrorCode returns whether its error argument is sf.SnowflakeError
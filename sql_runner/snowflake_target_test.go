@@ -19,6 +19,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	sf "github.com/snowflakedb/gosnowflake"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -131,3 +132,164 @@ func TestParsePrivateKey(t *testing.T) {
 		})
 	}
 }
+
+func TestSnowflakeConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("file-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SNOWFLAKE_TEST_TOKEN", "env-token")
+
+	config, err := snowflakeConfig(Target{
+		Account:   "snowplow",
+		Username:  "snowplow",
+		Password:  "secret",
+		Database:  "sql_runner_tests",
+		Schema:    "atomic",
+		Warehouse: "loader",
+		Role:      "transformer",
+		QueryTag:  "sql-runner",
+		Params:    map[string]string{"timezone": "UTC", "STATEMENT_TIMEOUT_IN_SECONDS": "3600"},
+	})
+	assert.Nil(err)
+	if assert.NotNil(config) {
+		assert.Equal("atomic", config.Schema)
+		assert.Equal("transformer", config.Role)
+		assert.Equal("secret", config.Password)
+		assert.Equal(sf.AuthTypeSnowflake, config.Authenticator)
+		assert.Len(config.Params, 3)
+		assert.Equal("sql-runner", *config.Params["QUERY_TAG"])
+		assert.Equal("UTC", *config.Params["TIMEZONE"])
+		assert.Equal("", config.Host)
+	}
+
+	config, err = snowflakeConfig(Target{
+		Account:        "snowplow",
+		Host:           "snowplow.privatelink.snowflakecomputing.com",
+		Port:           "8443",
+		OauthTokenFile: tokenFile,
+	})
+	assert.Nil(err)
+	if assert.NotNil(config) {
+		assert.Equal(sf.AuthTypeOAuth, config.Authenticator)
+		assert.Equal("file-token", config.Token)
+		assert.Equal("snowplow.privatelink.snowflakecomputing.com", config.Host)
+		assert.Equal(8443, config.Port)
+		assert.Equal("https", config.Protocol)
+	}
+
+	config, err = snowflakeConfig(Target{Account: "snowplow", Username: "snowplow", OauthTokenEnv: "SNOWFLAKE_TEST_TOKEN"})
+	assert.Nil(err)
+	if assert.NotNil(config) {
+		assert.Equal(sf.AuthTypeOAuth, config.Authenticator)
+		assert.Equal("env-token", config.Token)
+	}
+	assert.Equal(redactedSecret+" "+redactedSecret, redactSecrets("file-token env-token"))
+
+	config, err = snowflakeConfig(Target{Account: "snowplow", Username: "snowplow", ExternalBrowser: "Enforced"})
+	assert.Nil(err)
+	if assert.NotNil(config) {
+		assert.Equal(sf.AuthTypeExternalBrowser, config.Authenticator)
+	}
+}
+
+func TestSnowflakeConfig_Error(t *testing.T) {
+	t.Setenv("SNOWFLAKE_TEST_TOKEN", "")
+
+	testCases := []struct {
+		Name      string
+		Input     Target
+		ErrString string
+	}{
+		{
+			Name:      "missing_account",
+			Input:     Target{Username: "snowplow"},
+			ErrString: "missing target account or host",
+		},
+		{
+			Name:      "missing_username",
+			Input:     Target{Account: "snowplow", Password: "secret"},
+			ErrString: "missing target username",
+		},
+		{
+			Name:      "invalid_port",
+			Input:     Target{Account: "snowplow", Username: "snowplow", Port: "https"},
+			ErrString: `invalid target port "https"`,
+		},
+		{
+			Name:      "duplicate_param",
+			Input:     Target{Account: "snowplow", Username: "snowplow", QueryTag: "a", Params: map[string]string{"query_tag": "b"}},
+			ErrString: "session parameter QUERY_TAG is set more than once",
+		},
+		{
+			Name:      "unsupported_external_browser",
+			Input:     Target{Account: "snowplow", Username: "snowplow", ExternalBrowser: "allowed"},
+			ErrString: `unsupported external_browser "allowed" (supported: disabled, enforced)`,
+		},
+		{
+			Name:      "password_and_external_browser",
+			Input:     Target{Account: "snowplow", Username: "snowplow", Password: "secret", ExternalBrowser: "enforced"},
+			ErrString: "only one of password, private_key_path, oauth token or external_browser enforced may be used",
+		},
+		{
+			Name:      "token_file_and_env",
+			Input:     Target{Account: "snowplow", OauthTokenFile: "token", OauthTokenEnv: "SNOWFLAKE_TEST_TOKEN"},
+			ErrString: "oauth_token_file and oauth_token_env are mutually exclusive",
+		},
+		{
+			Name:      "token_env_not_set",
+			Input:     Target{Account: "snowplow", OauthTokenEnv: "SNOWFLAKE_TEST_TOKEN"},
+			ErrString: "oauth_token_env SNOWFLAKE_TEST_TOKEN is not set",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			result, err := snowflakeConfig(tt.Input)
+			assert.Nil(result)
+			if assert.NotNil(err) {
+				assert.Equal(tt.ErrString, err.Error())
+			}
+		})
+	}
+}
+
+func TestSnowflakeValidation(t *testing.T) {
+	testCases := []struct {
+		Statement string
+		Session   bool
+		Created   string
+	}{
+		{"USE SCHEMA s", true, ""},
+		{"alter session set timezone = 'UTC'", true, ""},
+		{"SET x = 1", true, ""},
+		{"SELECT 1", false, ""},
+		{"CREATE TABLE t (a int)", false, "t"},
+		{"CREATE OR REPLACE TEMPORARY TABLE db.s.t AS SELECT 1", false, "t"},
+		{"create secure view if not exists \"v\" as select 1", false, "v"},
+		{"CREATE FILE FORMAT f TYPE = CSV", false, "f"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Statement, func(t *testing.T) {
+			assert.Equal(t, tt.Session, snowflakeSession.MatchString(tt.Statement))
+			created := ""
+			if parts := snowflakeCreate.FindStringSubmatch(tt.Statement); parts != nil {
+				created = snowflakeObjectName(parts[5])
+			}
+			assert.Equal(t, tt.Created, created)
+		})
+	}
+}
+
+func TestSnowflakeDependency(t *testing.T) {
+	created := []string{"t", "v"}
+	assert.Equal(t, "t", snowflakeDependency("INSERT INTO s.t SELECT 1", created))
+	assert.Equal(t, "v", snowflakeDependency("SELECT * FROM \"V\"", created))
+	assert.Equal(t, "", snowflakeDependency("SELECT * FROM t2, t_x", created))
+	assert.Equal(t, "", snowflakeDependency("SELECT 1", nil))
+}
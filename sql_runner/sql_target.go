@@ -0,0 +1,127 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"context"
+	"database/sql"
+)
+
+// SQLTarget runs scripts through database/sql, one statement
+// at a time, for drivers which do not accept multi-statement
+// scripts or do not report rows affected for each statement.
+type SQLTarget struct {
+	Target
+	Client  *sql.DB
+	Split   func(script string) []string
+	Dialect dialect // Quoting rules of the default splitter
+	Convert valueConverter
+	pinned  bool // The database lives on its connection, which is kept
+}
+
+// IsConnectable tests connection to determine whether the target is
+// connectable.
+func (st SQLTarget) IsConnectable() bool {
+	return st.Client.Ping() == nil
+}
+
+// GetTarget returns the Target field of SQLTarget.
+func (st SQLTarget) GetTarget() Target {
+	return st.Target
+}
+
+// Close closes the client of the SQL target.
+func (st SQLTarget) Close() error {
+	return st.Client.Close()
+}
+
+// CheckHealth replaces dead pooled connections of the SQL target. A
+// pinned connection is only pinged, as replacing it would drop the
+// database.
+func (st SQLTarget) CheckHealth() error {
+	if st.pinned {
+		return st.Client.Ping()
+	}
+	return checkSQLHealth(st.Client, st.Target)
+}
+
+// RunQuery runs a query against the target.
+func (st SQLTarget) RunQuery(query ReadyQuery, dryRun bool, showQueryOutput bool) QueryStatus {
+	return runScript(query, dryRun, showQueryOutput, st.FetchResults, func() (int, error) {
+		return st.exec(query.Script)
+	})
+}
+
+// FetchResults runs a query and returns its result sets.
+func (st SQLTarget) FetchResults(query ReadyQuery) ([]ResultSet, error) {
+	return st.queryResults(query.Script)
+}
+
+// statements splits a script, defaulting to splitStatements.
+func (st SQLTarget) statements(script string) []string {
+	if st.Split == nil {
+		return splitStatements(script, st.Dialect)
+	}
+	return st.Split(script)
+}
+
+// exec runs each statement of a script on a single connection,
+// so transactions, SET, temp tables and USE carry over to later
+// statements, returning the rows affected summed over all of them.
+func (st SQLTarget) exec(script string) (int, error) {
+	ctx := context.Background()
+	conn, err := st.Client.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	affected := 0
+	for _, statement := range st.statements(script) {
+		res, err := conn.ExecContext(ctx, statement)
+		if err != nil {
+			return affected, err
+		}
+		// Some drivers cannot count rows for all statements
+		if rows, err := res.RowsAffected(); err == nil {
+			affected += int(rows)
+		}
+	}
+	return affected, nil
+}
+
+// queryResults runs each statement of a script on a single
+// connection, returning the rows of those which produced a result
+// set.
+func (st SQLTarget) queryResults(script string) ([]ResultSet, error) {
+	ctx := context.Background()
+	conn, err := st.Client.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	sets := make([]ResultSet, 0)
+	for _, statement := range st.statements(script) {
+		rows, err := conn.QueryContext(ctx, statement)
+		if err != nil {
+			return nil, err
+		}
+
+		results, err := resultSetsFromRows(rows, st.Convert)
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, results...)
+	}
+	return sets, nil
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "modernc.org/sqlite"
+)
+
+const memoryPath = ":memory:"
+
+func init() {
+	registerTarget(NewSQLiteTarget, sqliteType)
+	registerTargetValidator(validateSQLiteTarget, sqliteType)
+}
+
+// NewSQLiteTarget returns a ptr to a SQLTarget for a SQLite
+// database file, or an in-memory database.
+func NewSQLiteTarget(target Target) (*SQLTarget, error) {
+	if err := validateSQLiteTarget(target); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite", target.Path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite has a single writer, and each connection
+	// to :memory: would open a separate database
+	db.SetMaxOpenConns(1)
+	if err := configurePool(db, target); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLTarget{Target: target, Client: db, pinned: target.Path == memoryPath}, nil
+}
+
+// validateSQLiteTarget checks the options of a SQLite target. An
+// in-memory database lives on its one connection, so the connection
+// cannot be replaced.
+func validateSQLiteTarget(target Target) error {
+	if target.Path == "" {
+		return fmt.Errorf("missing target path")
+	}
+	if target.MaxOpenConns > 1 {
+		return fmt.Errorf("sqlite targets use a single connection, max_open_conns cannot exceed 1")
+	}
+	if target.Path == memoryPath && target.ConnMaxLifetime > 0 {
+		return fmt.Errorf("in-memory sqlite targets keep their database on their connection, conn_max_lifetime cannot be set")
+	}
+	return nil
+}
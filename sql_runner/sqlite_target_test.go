@@ -0,0 +1,122 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const embeddedScript = `
+-- Test file: embedded
+CREATE TABLE table1 (age int, firstName varchar(255), city varchar(255));
+INSERT INTO table1 VALUES (18, 'john', 'new york');
+INSERT INTO table1 VALUES (20, 'ben', 'london;uk'), (22, 'anna', NULL);
+`
+
+func TestNewSQLiteTarget_Error(t *testing.T) {
+	result, err := NewSQLiteTarget(Target{Name: "lite", Type: sqliteType})
+	assert.Nil(t, result)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "missing target path", err.Error())
+	}
+}
+
+func TestValidateSQLiteTarget(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Target   Target
+		Expected string
+	}{
+		{"file", Target{Path: "test.db", ConnMaxLifetime: 60}, ""},
+		{"memory", Target{Path: memoryPath, MaxIdleConns: 1}, ""},
+		{"missing_path", Target{}, "missing target path"},
+		{"max_open_conns", Target{Path: "test.db", MaxOpenConns: 2}, "sqlite targets use a single connection, max_open_conns cannot exceed 1"},
+		{"memory_lifetime", Target{Path: memoryPath, ConnMaxLifetime: 60}, "in-memory sqlite targets keep their database on their connection, conn_max_lifetime cannot be set"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			err := validateSQLiteTarget(tt.Target)
+			if tt.Expected == "" {
+				assert.Nil(t, err)
+			} else if assert.NotNil(t, err) {
+				assert.Equal(t, tt.Expected, err.Error())
+			}
+		})
+	}
+}
+
+func TestSQLiteTarget(t *testing.T) {
+	testCases := []struct {
+		Name string
+		Path string
+	}{
+		{Name: "memory", Path: memoryPath},
+		{Name: "file", Path: filepath.Join(t.TempDir(), "test.db")},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			lite, err := NewSQLiteTarget(Target{Name: "lite", Type: sqliteType, Path: tt.Path})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.True(lite.IsConnectable())
+
+			status := lite.RunQuery(ReadyQuery{Name: "load", Script: embeddedScript}, false, false)
+			assert.Nil(status.Error)
+			assert.Equal(3, status.Affected)
+			assert.Nil(lite.CheckHealth())
+
+			sets, err := lite.FetchResults(ReadyQuery{Script: "SELECT COUNT(*) AS n FROM table1; SELECT city FROM table1 ORDER BY age"})
+			assert.Nil(err)
+			if assert.Equal(2, len(sets)) {
+				assert.Equal([][]string{{"3"}}, sets[0].StringRows())
+				assert.Equal([][]string{{"new york"}, {"london;uk"}, {nullString}}, sets[1].StringRows())
+			}
+
+			status = lite.RunQuery(ReadyQuery{Name: "output", Script: "SELECT * FROM table1;"}, false, true)
+			assert.Nil(status.Error)
+			assert.Equal(3, status.Affected)
+
+			status = lite.RunQuery(ReadyQuery{Name: "bad", Script: "CREATE TABLE"}, false, false)
+			assert.NotNil(status.Error)
+		})
+	}
+}
+
+func TestSQLiteTarget_Checks(t *testing.T) {
+	lite, err := NewSQLiteTarget(Target{Name: "lite", Type: sqliteType, Path: memoryPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lite.RunQuery(ReadyQuery{Script: embeddedScript}, false, false)
+
+	checks := []Check{
+		{Table: "table1", Column: "age", Rule: ruleUnique},
+		{Table: "table1", Rule: ruleRowCount, Min: intPtr(3)},
+		{Table: "table1", Column: "city", Rule: ruleNotNull, Severity: severityWarn},
+	}
+	statuses, warnings := runChecks(lite, lineProgress{}, "checks", checks, false)
+
+	assert.Equal(t, 2, len(statuses))
+	for _, status := range statuses {
+		assert.Nil(t, status.Error)
+	}
+	if assert.Equal(t, 1, len(warnings)) {
+		assert.Equal(t, "CHECK FAILED: not_null(table1.city): 1 offending rows", warnings[0].Error.Error())
+	}
+}
@@ -0,0 +1,288 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	sshDefaultPort      = "22"
+	sshDefaultKeepalive = 30 * time.Second
+)
+
+// SshTunnel represents the bastion host a target is reached through.
+type SshTunnel struct {
+	Host, Port, User string
+	KeyPath          string `yaml:"key_path"`
+	KeyPassphrase    string `yaml:"key_passphrase"`
+	KnownHosts       string `yaml:"known_hosts"` // Defaults to ~/.ssh/known_hosts
+	Keepalive        int    // Seconds between keepalives, 0 for the default
+}
+
+// dialFunc opens a connection to a database server.
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// dialerFromTarget returns the dialer of a TCP based target, which goes
+// through its SSH tunnel when it has one. The tunnel is returned too,
+// to be closed along with the target.
+func dialerFromTarget(target Target) (dialFunc, *sshTunnel, error) {
+	if target.SshTunnel == nil {
+		dialer := &net.Dialer{Timeout: dialTimeout}
+		return dialer.DialContext, nil, nil
+	}
+
+	tunnel, err := newSSHTunnel(*target.SshTunnel)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tunnel.dial, tunnel, nil
+}
+
+// closeTunneled closes the client of a target, then the SSH tunnel it
+// went through, if any.
+func closeTunneled(client io.Closer, tunnel *sshTunnel) error {
+	err := client.Close()
+	if tunnel != nil {
+		if tunnelErr := tunnel.close(); tunnelErr != nil && err == nil {
+			err = tunnelErr
+		}
+	}
+	return err
+}
+
+// tunnelHostDialer dials SQL Server through an SSH tunnel. Being a
+// host dialer, the driver leaves resolving the host to the bastion.
+type tunnelHostDialer struct {
+	tunnel *sshTunnel
+	host   string
+}
+
+// DialContext implements mssql.Dialer.
+func (d tunnelHostDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return d.tunnel.dial(ctx, network, addr)
+}
+
+// HostName implements mssql.HostDialer.
+func (d tunnelHostDialer) HostName() string {
+	return d.host
+}
+
+// sshTunnel forwards connections through an SSH client, which is
+// reconnected on the next dial once it is lost.
+type sshTunnel struct {
+	addr      string
+	config    *ssh.ClientConfig
+	keepalive time.Duration
+
+	mu     sync.Mutex
+	client *ssh.Client
+}
+
+// newSSHTunnel validates the settings of a tunnel and loads its keys.
+// The bastion is only connected to on the first dial.
+func newSSHTunnel(settings SshTunnel) (*sshTunnel, error) {
+	if settings.Host == "" || settings.User == "" {
+		return nil, fmt.Errorf("missing ssh_tunnel host or user")
+	}
+	port := settings.Port
+	if port == "" {
+		port = sshDefaultPort
+	}
+
+	auth, err := sshAuthMethods(settings)
+	if err != nil {
+		return nil, err
+	}
+	hostKeyCallback, err := sshHostKeyCallback(settings.KnownHosts)
+	if err != nil {
+		return nil, err
+	}
+
+	keepalive := sshDefaultKeepalive
+	if settings.Keepalive > 0 {
+		keepalive = time.Duration(settings.Keepalive) * time.Second
+	}
+
+	return &sshTunnel{
+		addr: net.JoinHostPort(settings.Host, port),
+		config: &ssh.ClientConfig{
+			User:            settings.User,
+			Auth:            auth,
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         dialTimeout,
+		},
+		keepalive: keepalive,
+	}, nil
+}
+
+// sshAuthMethods authenticates with the key of the tunnel, or else
+// with the keys of a running SSH agent.
+func sshAuthMethods(settings SshTunnel) ([]ssh.AuthMethod, error) {
+	if settings.KeyPath != "" {
+		pem, err := os.ReadFile(settings.KeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read ssh_tunnel key_path: %s", err)
+		}
+
+		var signer ssh.Signer
+		if settings.KeyPassphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(pem, []byte(settings.KeyPassphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(pem)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse ssh_tunnel key_path: %s", err)
+		}
+		return []ssh.AuthMethod{ssh.PublicKeys(signer)}, nil
+	}
+
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, fmt.Errorf("missing ssh_tunnel key_path, and no ssh agent is running")
+	}
+	return []ssh.AuthMethod{ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		return agent.NewClient(conn).Signers()
+	})}, nil
+}
+
+// sshHostKeyCallback checks the key of the bastion against known_hosts.
+// Unknown hosts are always rejected.
+func sshHostKeyCallback(path string) (ssh.HostKeyCallback, error) {
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("missing ssh_tunnel known_hosts: %s", err)
+		}
+		path = filepath.Join(home, ".ssh", "known_hosts")
+	}
+
+	callback, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read ssh_tunnel known_hosts: %s", err)
+	}
+	return callback, nil
+}
+
+// dial opens a connection to addr from the bastion.
+func (st *sshTunnel) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	client, err := st.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := client.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("ssh tunnel %s: %s", st.addr, err)
+	}
+	return conn, nil
+}
+
+// connect returns the SSH client, connecting to the bastion unless
+// it is already connected.
+func (st *sshTunnel) connect(ctx context.Context) (*ssh.Client, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.client != nil {
+		return st.client, nil
+	}
+
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", st.addr)
+	if err != nil {
+		return nil, fmt.Errorf("ssh tunnel %s: %s", st.addr, err)
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, st.addr, st.config)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("ssh tunnel %s: %s", st.addr, err)
+	}
+
+	client := ssh.NewClient(c, chans, reqs)
+	st.client = client
+	closed := make(chan struct{})
+	go st.keepAlive(client, closed)
+	go func() {
+		client.Wait()
+		close(closed)
+		st.mu.Lock()
+		if st.client == client {
+			st.client = nil
+		}
+		st.mu.Unlock()
+	}()
+	return client, nil
+}
+
+// close disconnects from the bastion, if connected.
+func (st *sshTunnel) close() error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.client == nil {
+		return nil
+	}
+	err := st.client.Close()
+	st.client = nil
+	return err
+}
+
+// keepAlive sends keepalives to the bastion, so that idle tunnels
+// are neither dropped by it nor by firewalls, and closes the client
+// once the bastion stops answering.
+func (st *sshTunnel) keepAlive(client *ssh.Client, closed chan struct{}) {
+	ticker := time.NewTicker(st.keepalive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-closed:
+			return
+		}
+
+		reply := make(chan error, 1)
+		go func() {
+			_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+			reply <- err
+		}()
+
+		select {
+		case err := <-reply:
+			if err == nil {
+				continue
+			}
+			log.Printf("WARNING: Lost ssh tunnel %s: %s", st.addr, err)
+		case <-time.After(st.keepalive):
+			log.Printf("WARNING: Lost ssh tunnel %s: no keepalive reply", st.addr)
+		}
+		client.Close()
+		return
+	}
+}
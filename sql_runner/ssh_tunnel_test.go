@@ -0,0 +1,423 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// fakeSSHD is an in-process sshd accepting a single client key,
+// forwarding direct-tcpip channels and answering keepalives.
+type fakeSSHD struct {
+	listener   net.Listener
+	config     *ssh.ServerConfig
+	hostKey    ssh.PublicKey
+	keepalives atomic.Int32
+	forwards   atomic.Int32
+
+	mu    sync.Mutex
+	conns []*ssh.ServerConn
+}
+
+func newFakeSSHD(t *testing.T, clientKey ssh.PublicKey) *fakeSSHD {
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if meta.User() == "snowplow" && bytes.Equal(key.Marshal(), clientKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, io.EOF
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	sshd := &fakeSSHD{listener: listener, config: config, hostKey: hostSigner.PublicKey()}
+	go sshd.serve()
+	return sshd
+}
+
+func (s *fakeSSHD) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSSHD) handle(conn net.Conn) {
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	s.mu.Lock()
+	s.conns = append(s.conns, sconn)
+	s.mu.Unlock()
+
+	go func() {
+		for req := range reqs {
+			if req.Type == "keepalive@openssh.com" {
+				s.keepalives.Add(1)
+			}
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+		}
+	}()
+
+	for ch := range chans {
+		if ch.ChannelType() != "direct-tcpip" {
+			ch.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		var forward struct {
+			Host       string
+			Port       uint32
+			OriginHost string
+			OriginPort uint32
+		}
+		if err := ssh.Unmarshal(ch.ExtraData(), &forward); err != nil {
+			ch.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		upstream, err := net.Dial("tcp", net.JoinHostPort(forward.Host, strconv.FormatUint(uint64(forward.Port), 10)))
+		if err != nil {
+			ch.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		channel, chReqs, err := ch.Accept()
+		if err != nil {
+			upstream.Close()
+			continue
+		}
+		s.forwards.Add(1)
+		go ssh.DiscardRequests(chReqs)
+		go func() {
+			io.Copy(channel, upstream)
+			channel.CloseWrite()
+		}()
+		go func() {
+			io.Copy(upstream, channel)
+			upstream.Close()
+		}()
+	}
+}
+
+// dropClients closes the connections of every client.
+func (s *fakeSSHD) dropClients() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+// Helper starting an echo server, standing in for a database
+func newEchoServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(conn, conn)
+				conn.Close()
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// Helper writing a client key and the known_hosts of sshd into dir
+func writeSSHFiles(t *testing.T, dir string, hostAddr string, hostKey ssh.PublicKey, clientPriv ed25519.PrivateKey) (string, string) {
+	block, err := ssh.MarshalPrivateKey(clientPriv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	knownHostsPath := filepath.Join(dir, "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(hostAddr)}, hostKey) + "\n"
+	if err := os.WriteFile(knownHostsPath, []byte(line), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return keyPath, knownHostsPath
+}
+
+func newSSHTestSetup(t *testing.T) (*fakeSSHD, SshTunnel) {
+	clientPub, clientPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshClientPub, err := ssh.NewPublicKey(clientPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sshd := newFakeSSHD(t, sshClientPub)
+	host, port, _ := net.SplitHostPort(sshd.listener.Addr().String())
+	keyPath, knownHostsPath := writeSSHFiles(t, t.TempDir(), sshd.listener.Addr().String(), sshd.hostKey, clientPriv)
+	return sshd, SshTunnel{Host: host, Port: port, User: "snowplow", KeyPath: keyPath, KnownHosts: knownHostsPath}
+}
+
+// Helper checking that a connection echoes back what is written
+func assertEcho(t *testing.T, conn net.Conn, message string) {
+	t.Helper()
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(message)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, len(message))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, message, string(buf))
+}
+
+func TestSSHTunnel_Dial(t *testing.T) {
+	assert := assert.New(t)
+	sshd, settings := newSSHTestSetup(t)
+	echo := newEchoServer(t)
+
+	dial, tunnel, err := dialerFromTarget(Target{SshTunnel: &settings})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := dial(context.Background(), "tcp", echo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEcho(t, conn, "SELECT 1;")
+
+	// A lost tunnel is reconnected on the next dial
+	sshd.dropClients()
+	assert.Eventually(func() bool {
+		conn, err := dial(context.Background(), "tcp", echo)
+		if err != nil {
+			return false
+		}
+		assertEcho(t, conn, "SELECT 2;")
+		return true
+	}, dialTimeout, 10*time.Millisecond)
+
+	// Closing the tunnel disconnects from the bastion
+	assert.Nil(tunnel.close())
+	assert.Nil(tunnel.client)
+	assert.Nil(tunnel.close())
+}
+
+func TestSSHTunnel_Keepalive(t *testing.T) {
+	sshd, settings := newSSHTestSetup(t)
+
+	tunnel, err := newSSHTunnel(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tunnel.keepalive = 10 * time.Millisecond
+	if _, err := tunnel.connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Eventually(t, func() bool { return sshd.keepalives.Load() >= 2 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSSHTunnel_UnknownHost(t *testing.T) {
+	_, settings := newSSHTestSetup(t)
+	echo := newEchoServer(t)
+
+	// known_hosts holding the key of another server
+	otherSSHD, otherSettings := newSSHTestSetup(t)
+	otherSSHD.listener.Close()
+	settings.KnownHosts = otherSettings.KnownHosts
+
+	dial, _, err := dialerFromTarget(Target{SshTunnel: &settings})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = dial(context.Background(), "tcp", echo)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "knownhosts: key is unknown")
+	}
+}
+
+func TestNewSSHTunnel_Error(t *testing.T) {
+	_, settings := newSSHTestSetup(t)
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	testCases := []struct {
+		Name      string
+		Input     SshTunnel
+		ErrString string
+	}{
+		{
+			Name:      "missing_user",
+			Input:     SshTunnel{Host: "bastion"},
+			ErrString: "missing ssh_tunnel host or user",
+		},
+		{
+			Name:      "missing_key",
+			Input:     SshTunnel{Host: "bastion", User: "snowplow"},
+			ErrString: "missing ssh_tunnel key_path, and no ssh agent is running",
+		},
+		{
+			Name:      "key_not_pem",
+			Input:     SshTunnel{Host: "bastion", User: "snowplow", KeyPath: settings.KnownHosts},
+			ErrString: "unable to parse ssh_tunnel key_path: ssh: no key found",
+		},
+		{
+			Name:      "missing_known_hosts",
+			Input:     SshTunnel{Host: "bastion", User: "snowplow", KeyPath: settings.KeyPath, KnownHosts: filepath.Join(t.TempDir(), "known_hosts")},
+			ErrString: "unable to read ssh_tunnel known_hosts",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			result, err := newSSHTunnel(tt.Input)
+			assert.Nil(t, result)
+			if assert.NotNil(t, err) {
+				assert.Contains(t, err.Error(), tt.ErrString)
+			}
+		})
+	}
+}
+
+func TestNewPostgresTarget_SSHTunnel(t *testing.T) {
+	_, settings := newSSHTestSetup(t)
+	echo := newEchoServer(t)
+
+	pt, err := NewPostgresTarget(Target{
+		Type:      postgresType,
+		Host:      "localhost",
+		Port:      "5432",
+		Database:  "sql_runner_tests",
+		Username:  "snowplow",
+		SshTunnel: &settings,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := pt.Client.Options().Dialer(context.Background(), "tcp", echo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEcho(t, conn, "SELECT 1;")
+}
+
+// Helper starting a server which hangs up on every connection,
+// counting them
+func newHangupServer(t *testing.T) (string, string, *atomic.Int32) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	accepted := &atomic.Int32{}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted.Add(1)
+			conn.Close()
+		}
+	}()
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	return host, port, accepted
+}
+
+func TestSSHTunnel_Targets(t *testing.T) {
+	testCases := []struct {
+		Name string
+		New  func(target Target) (Db, error)
+	}{
+		{"mysql", func(target Target) (Db, error) { return NewMySQLTarget(target) }},
+		{"clickhouse", func(target Target) (Db, error) { return NewClickHouseTarget(target) }},
+		{"mssql", func(target Target) (Db, error) { return NewMSSQLTarget(target) }},
+		{"trino", func(target Target) (Db, error) { return NewTrinoTarget(target) }},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert := assert.New(t)
+			sshd, settings := newSSHTestSetup(t)
+			host, port, accepted := newHangupServer(t)
+
+			database, err := tt.New(Target{
+				Name:      tt.Name,
+				Type:      tt.Name,
+				Host:      host,
+				Port:      port,
+				Username:  "snowplow",
+				Database:  "sql_runner_tests",
+				SshTunnel: &settings,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The server is only reached through the bastion
+			assert.False(database.IsConnectable())
+			assert.True(sshd.forwards.Load() > 0)
+			assert.Eventually(func() bool { return accepted.Load() > 0 }, 5*time.Second, 10*time.Millisecond)
+			assert.Nil(database.Close())
+		})
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"strings"
+)
+
+// dialect holds the quoting rules of the SQL a script is
+// written in.
+type dialect struct {
+	backslashEscapes bool // Backslashes escape quotes in strings, as in MySQL
+	escapeStrings    bool // E'...' strings take backslash escapes, as in Postgres
+	dollarQuotes     bool // $tag$ opens a dollar-quoted string, as in Postgres
+	hashComments     bool // # opens a comment to the end of the line, as in MySQL
+}
+
+// Dialects of the targets splitting their scripts
+var (
+	standardDialect   = dialect{}
+	postgresDialect   = dialect{escapeStrings: true, dollarQuotes: true}
+	mysqlDialect      = dialect{backslashEscapes: true, hashComments: true}
+	snowflakeDialect  = dialect{backslashEscapes: true, dollarQuotes: true}
+	clickhouseDialect = dialect{backslashEscapes: true, dollarQuotes: true}
+)
+
+// dialects maps the dialect names of generic targets.
+var dialects = map[string]dialect{
+	"":         standardDialect,
+	"standard": standardDialect,
+	"postgres": postgresDialect,
+	"mysql":    mysqlDialect,
+}
+
+// splitStatements splits a script on the semicolons which end its
+// statements. Semicolons in quoted strings and identifiers, comments
+// and, depending on the dialect, dollar-quoted bodies are left alone.
+// Statements made only of comments and whitespace are dropped.
+func splitStatements(script string, d dialect) []string {
+	statements := make([]string, 0)
+	start := 0
+	hasCode := false
+
+	emit := func(end int) {
+		if hasCode {
+			statements = append(statements, strings.TrimSpace(script[start:end]))
+		}
+		start = end + 1
+		hasCode = false
+	}
+
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case c == '-' && strings.HasPrefix(script[i:], "--"):
+			i = skipUntil(script, i+2, "\n") - 1
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			i = skipUntil(script, i+2, "*/") - 1
+		case c == '#' && d.hashComments:
+			i = skipUntil(script, i+1, "\n") - 1
+		case c == '\'' || c == '"':
+			hasCode = true
+			escapes := d.backslashEscapes || (c == '\'' && d.escapeStrings && isEscapeString(script, i))
+			i = skipQuoted(script, i, c, escapes)
+		case c == '`':
+			hasCode = true
+			i = skipQuoted(script, i, c, false)
+		case c == '$' && d.dollarQuotes && (i == 0 || !isIdentifierChar(script[i-1])):
+			hasCode = true
+			if tag, ok := dollarTag(script[i:]); ok {
+				i = skipUntil(script, i+len(tag), tag) - 1
+			}
+		case c == ';':
+			emit(i)
+		case c != ' ' && c != '\t' && c != '\n' && c != '\r':
+			hasCode = true
+		}
+	}
+	emit(len(script))
+
+	return statements
+}
+
+// trimLeadingComments drops the whitespace and comments a statement
+// starts with, leaving its first keyword.
+func trimLeadingComments(statement string) string {
+	for {
+		statement = strings.TrimSpace(statement)
+		switch {
+		case strings.HasPrefix(statement, "--"):
+			statement = statement[skipUntil(statement, 2, "\n"):]
+		case strings.HasPrefix(statement, "/*"):
+			statement = statement[skipUntil(statement, 2, "*/"):]
+		default:
+			return statement
+		}
+	}
+}
+
+// skipUntil returns the index just after the next occurrence
+// of end from i, or the length of s if there is none.
+func skipUntil(s string, i int, end string) int {
+	if i > len(s) {
+		return len(s)
+	}
+	j := strings.Index(s[i:], end)
+	if j < 0 {
+		return len(s)
+	}
+	return i + j + len(end)
+}
+
+// skipQuoted returns the index of the quote closing the string
+// opened at i, treating doubled quotes, and backslashes if the
+// string takes them, as escapes.
+func skipQuoted(s string, i int, quote byte, backslashEscapes bool) int {
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			if backslashEscapes {
+				j++
+			}
+		case quote:
+			if j+1 < len(s) && s[j+1] == quote {
+				j++
+				continue
+			}
+			return j
+		}
+	}
+	return len(s)
+}
+
+// isEscapeString reports whether the quote at i opens an E'...'
+// string.
+func isEscapeString(s string, i int) bool {
+	return i > 0 && (s[i-1] == 'E' || s[i-1] == 'e') && (i == 1 || !isIdentifierChar(s[i-2]))
+}
+
+// isIdentifierChar reports whether c may be part of an unquoted
+// identifier, so that a following $ does not start a token.
+func isIdentifierChar(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+// dollarTag returns the $tag$ opening a Postgres dollar-quoted
+// string at the start of s, if any.
+func dollarTag(s string) (string, bool) {
+	for j := 1; j < len(s); j++ {
+		c := s[j]
+		switch {
+		case c == '$':
+			return s[:j+1], true
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || j > 1 && c >= '0' && c <= '9':
+		default:
+			return "", false
+		}
+	}
+	return "", false
+}
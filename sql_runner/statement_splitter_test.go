@@ -0,0 +1,128 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitStatements(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Dialect  dialect
+		Script   string
+		Expected []string
+	}{
+		{
+			Name:     "empty",
+			Script:   "  \n",
+			Expected: []string{},
+		},
+		{
+			Name:     "single_without_semicolon",
+			Script:   "SELECT 1",
+			Expected: []string{"SELECT 1"},
+		},
+		{
+			Name:     "multiple",
+			Script:   "CREATE TABLE t (a int);\nINSERT INTO t VALUES (1);\n\nSELECT * FROM t;\n",
+			Expected: []string{"CREATE TABLE t (a int)", "INSERT INTO t VALUES (1)", "SELECT * FROM t"},
+		},
+		{
+			Name:     "comments",
+			Script:   "-- Test file: 1.sql; not a statement\n/* header; */\nSELECT 1; -- trailing;\n",
+			Expected: []string{"-- Test file: 1.sql; not a statement\n/* header; */\nSELECT 1"},
+		},
+		{
+			Name:     "quoted",
+			Script:   `SELECT 'a;''b', "c;d", ` + "`e;f`" + `; SELECT 2`,
+			Expected: []string{`SELECT 'a;''b', "c;d", ` + "`e;f`", "SELECT 2"},
+		},
+		{
+			Name:     "backslash_escaped",
+			Dialect:  mysqlDialect,
+			Script:   `SELECT 'g\';h', "i\";j", ` + "`k\\`" + `; SELECT 2`,
+			Expected: []string{`SELECT 'g\';h', "i\";j", ` + "`k\\`", "SELECT 2"},
+		},
+		{
+			Name:     "hash_comments",
+			Dialect:  mysqlDialect,
+			Script:   "# load; not a statement\nSELECT '#;'; # trailing;\nSELECT 2",
+			Expected: []string{"# load; not a statement\nSELECT '#;'", "# trailing;\nSELECT 2"},
+		},
+		{
+			Name:     "hash_not_commenting",
+			Script:   "SELECT a#b FROM t; SELECT 2",
+			Expected: []string{"SELECT a#b FROM t", "SELECT 2"},
+		},
+		{
+			Name:     "backslash_not_escaping",
+			Script:   `SELECT 'C:\'; SELECT 2;`,
+			Expected: []string{`SELECT 'C:\'`, "SELECT 2"},
+		},
+		{
+			Name:     "escape_string",
+			Dialect:  postgresDialect,
+			Script:   `SELECT E'a\';b', 'C:\', e'\\'; SELECT 2`,
+			Expected: []string{`SELECT E'a\';b', 'C:\', e'\\'`, "SELECT 2"},
+		},
+		{
+			Name:     "dollar_quoted",
+			Dialect:  postgresDialect,
+			Script:   "CREATE FUNCTION f() RETURNS int AS $body$ SELECT 1; $body$ LANGUAGE sql; SELECT $1",
+			Expected: []string{"CREATE FUNCTION f() RETURNS int AS $body$ SELECT 1; $body$ LANGUAGE sql", "SELECT $1"},
+		},
+		{
+			Name:     "dollar_in_identifier",
+			Dialect:  postgresDialect,
+			Script:   "SELECT a$b$ FROM t; SELECT 2;",
+			Expected: []string{"SELECT a$b$ FROM t", "SELECT 2"},
+		},
+		{
+			Name:     "dollar_not_quoting",
+			Script:   "SELECT $x$ FROM t; SELECT 2;",
+			Expected: []string{"SELECT $x$ FROM t", "SELECT 2"},
+		},
+		{
+			Name:     "unterminated",
+			Script:   "SELECT 'a;",
+			Expected: []string{"SELECT 'a;"},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(t, tt.Expected, splitStatements(tt.Script, tt.Dialect))
+		})
+	}
+}
+
+func TestTrimLeadingComments(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Input    string
+		Expected string
+	}{
+		{Name: "none", Input: "SELECT 1", Expected: "SELECT 1"},
+		{Name: "line", Input: "-- Test file: 1.sql\n\n  SELECT 1", Expected: "SELECT 1"},
+		{Name: "block", Input: "/* a */ /* b\n */ INSERT INTO t VALUES (1)", Expected: "INSERT INTO t VALUES (1)"},
+		{Name: "only_comments", Input: "-- a\n/* b */", Expected: ""},
+		{Name: "unterminated", Input: "/* a", Expected: ""},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(t, tt.Expected, trimLeadingComments(tt.Input))
+		})
+	}
+}
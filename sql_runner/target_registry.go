@@ -0,0 +1,105 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// targetConstructor creates the Db of a playbook target.
+type targetConstructor func(target Target) (Db, error)
+
+// targetConstructors maps the lower case target types to their
+// constructors. Target types register themselves from init.
+var targetConstructors = map[string]targetConstructor{}
+
+// registerTarget makes a target type, and its aliases, available to
+// playbooks. It panics on duplicate types, as these are programming
+// errors.
+func registerTarget[T Db](constructor func(target Target) (T, error), targetTypes ...string) {
+	for _, targetType := range targetTypes {
+		targetType = strings.ToLower(targetType)
+		if _, ok := targetConstructors[targetType]; ok {
+			panic(fmt.Sprintf("target type %s is registered twice", targetType))
+		}
+
+		targetConstructors[targetType] = func(target Target) (Db, error) {
+			// Avoid wrapping a nil pointer in a non-nil Db
+			database, err := constructor(target)
+			if err != nil {
+				return nil, err
+			}
+			return database, nil
+		}
+	}
+}
+
+// targetValidators maps the lower case target types to the checks of
+// their options, run on the playbook before any target starts.
+var targetValidators = map[string]func(target Target) error{}
+
+// registerTargetValidator makes the options of a target type checked
+// along with the playbook. Values which are secret references are not
+// resolved yet, so validators must leave them alone.
+func registerTargetValidator(validate func(target Target) error, targetTypes ...string) {
+	for _, targetType := range targetTypes {
+		targetValidators[strings.ToLower(targetType)] = validate
+	}
+}
+
+// validateTarget checks the options of a playbook target, if its type
+// has a validator.
+func validateTarget(target Target) error {
+	if target.Plugin != "" {
+		return nil
+	}
+	if validate, ok := targetValidators[strings.ToLower(target.Type)]; ok {
+		return validate(target)
+	}
+	return nil
+}
+
+// lookupTarget returns the constructor of a target type.
+func lookupTarget(targetType string) (targetConstructor, bool) {
+	constructor, ok := targetConstructors[strings.ToLower(targetType)]
+	return constructor, ok
+}
+
+// constructorFor returns the constructor of a playbook target. Targets
+// naming a plugin, and types which are not built in but have a plugin
+// on PATH, are run by their plugin.
+func constructorFor(target Target) (targetConstructor, bool) {
+	if target.Plugin != "" {
+		return lookupTarget(pluginType)
+	}
+	if constructor, ok := lookupTarget(target.Type); ok {
+		return constructor, true
+	}
+	if target.Type != "" {
+		if _, err := exec.LookPath(pluginPrefix + strings.ToLower(target.Type)); err == nil {
+			return lookupTarget(pluginType)
+		}
+	}
+	return nil, false
+}
+
+// registeredTargetTypes returns the sorted registered target types.
+func registeredTargetTypes() []string {
+	types := make([]string, 0, len(targetConstructors))
+	for targetType := range targetConstructors {
+		types = append(types, targetType)
+	}
+	sort.Strings(types)
+	return types
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupTarget(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, targetType := range []string{"Redshift", postgresType, postgresqlType, snowflakeType, bigqueryType,
+		mysqlType, sqliteType, duckdbType, clickhouseType, mssqlType, trinoType, genericType} {
+		_, ok := lookupTarget(targetType)
+		assert.True(ok, targetType)
+	}
+
+	_, ok := lookupTarget("oracle")
+	assert.False(ok)
+	assert.Contains(registeredTargetTypes(), genericType)
+}
+
+func TestRegisterTarget(t *testing.T) {
+	assert := assert.New(t)
+	defer delete(targetConstructors, "failing")
+
+	registerTarget(func(target Target) (*SQLTarget, error) {
+		return nil, errors.New("no connection")
+	}, "Failing")
+
+	constructor, ok := lookupTarget("failing")
+	if !assert.True(ok) {
+		return
+	}
+	database, err := constructor(Target{})
+	assert.EqualError(err, "no connection")
+	// A nil *SQLTarget must not become a non-nil Db
+	assert.True(database == nil)
+
+	assert.Panics(func() {
+		registerTarget(NewGenericTarget, "failing")
+	})
+}
+
+func TestUnsupportedDbType(t *testing.T) {
+	status := unsupportedDbType("target", "oracle")
+	if assert.Len(t, status.Errors, 1) {
+		assert.Contains(t, status.Errors[0].Error(), "Database type is unsupported: oracle (supported: bigquery, clickhouse, ")
+	}
+}
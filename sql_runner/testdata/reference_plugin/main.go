@@ -0,0 +1,221 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+
+// reference_plugin is a plugin target for sql-runner, answering the
+// plugin protocol with canned results, as a starting point for real
+// plugins. Each statement of a script is handled on its own:
+// SELECTs return two rows, INSERTs affect one row, SLEEP runs until
+// it is cancelled and anything else fails. Targets on the host
+// "unreachable" cannot be pinged, and connect fails without a database.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+type request struct {
+	ID     *uint64         `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+type response struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      uint64      `json:"id"`
+	Result  interface{} `json:"result,omitempty"`
+	Error   *rpcError   `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type target struct {
+	Name     string            `json:"name"`
+	Host     string            `json:"host"`
+	Database string            `json:"database"`
+	Params   map[string]string `json:"params"`
+}
+
+type query struct {
+	Name   string `json:"name"`
+	Script string `json:"script"`
+}
+
+type plugin struct {
+	writeMu sync.Mutex
+	out     *json.Encoder
+
+	mu        sync.Mutex
+	target    target
+	cancelled map[uint64]chan struct{}
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("reference_plugin: ")
+
+	p := &plugin{out: json.NewEncoder(os.Stdout), cancelled: make(map[uint64]chan struct{})}
+	var wg sync.WaitGroup
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var req request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			log.Printf("invalid request: %s", err)
+			continue
+		}
+
+		if req.ID == nil {
+			p.notify(req)
+			continue
+		}
+
+		id := *req.ID
+		p.mu.Lock()
+		p.cancelled[id] = make(chan struct{})
+		p.mu.Unlock()
+
+		// Requests are answered concurrently, as sql-runner runs the
+		// queries of a step in parallel
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := p.handle(id, req)
+			p.respond(id, result, err)
+		}()
+	}
+	wg.Wait()
+}
+
+// notify handles requests which expect no response.
+func (p *plugin) notify(req request) {
+	if req.Method != "cancel" {
+		log.Printf("unknown notification %s", req.Method)
+		return
+	}
+
+	var params struct {
+		ID uint64 `json:"id"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		log.Printf("invalid cancel: %s", err)
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if ch, ok := p.cancelled[params.ID]; ok {
+		close(ch)
+		delete(p.cancelled, params.ID)
+	}
+}
+
+func (p *plugin) handle(id uint64, req request) (interface{}, error) {
+	switch req.Method {
+	case "connect":
+		var params struct {
+			Target target `json:"target"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		if params.Target.Database == "" {
+			return nil, fmt.Errorf("missing database")
+		}
+		p.mu.Lock()
+		p.target = params.Target
+		p.mu.Unlock()
+		return struct{}{}, nil
+	case "ping":
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.target.Host == "unreachable" {
+			return nil, fmt.Errorf("host unreachable")
+		}
+		return struct{}{}, nil
+	case "run_query", "fetch_results":
+		var q query
+		if err := json.Unmarshal(req.Params, &q); err != nil {
+			return nil, err
+		}
+		return p.run(id, q, req.Method == "fetch_results")
+	default:
+		return nil, fmt.Errorf("unknown method %s", req.Method)
+	}
+}
+
+// run runs each statement of a query, returning the rows affected
+// or the result sets of its SELECTs.
+func (p *plugin) run(id uint64, q query, fetch bool) (interface{}, error) {
+	affected := 0
+	sets := make([]interface{}, 0)
+	for _, statement := range strings.Split(q.Script, ";") {
+		statement = strings.TrimSpace(statement)
+		switch {
+		case statement == "":
+		case strings.HasPrefix(statement, "SELECT"):
+			affected += 2
+			sets = append(sets, map[string]interface{}{
+				"columns": []map[string]string{
+					{"name": "n", "type": "BIGINT"},
+					{"name": "s", "type": "VARCHAR"},
+					{"name": "ts", "type": "TIMESTAMP"},
+				},
+				"rows": [][]interface{}{
+					{1, "a", "2025-01-02T03:04:05Z"},
+					{2, nil, nil},
+				},
+			})
+		case strings.HasPrefix(statement, "INSERT"):
+			affected++
+		case statement == "SLEEP":
+			p.mu.Lock()
+			cancelled := p.cancelled[id]
+			p.mu.Unlock()
+			if cancelled != nil {
+				<-cancelled
+			}
+			return nil, fmt.Errorf("query %s was cancelled", q.Name)
+		default:
+			return nil, fmt.Errorf("syntax error at %q", statement)
+		}
+	}
+
+	if fetch {
+		return map[string]interface{}{"result_sets": sets}, nil
+	}
+	return map[string]interface{}{"affected": affected}, nil
+}
+
+func (p *plugin) respond(id uint64, result interface{}, err error) {
+	p.mu.Lock()
+	delete(p.cancelled, id)
+	p.mu.Unlock()
+
+	resp := response{JSONRPC: "2.0", ID: id, Result: result}
+	if err != nil {
+		resp = response{JSONRPC: "2.0", ID: id, Error: &rpcError{Code: -32000, Message: err.Error()}}
+	}
+
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	if err := p.out.Encode(resp); err != nil {
+		log.Printf("cannot respond: %s", err)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Postgres sslmode values, as in libpq
+const (
+	sslModeDisable    = "disable"
+	sslModeRequire    = "require"
+	sslModeVerifyCA   = "verify-ca"
+	sslModeVerifyFull = "verify-full"
+
+	// ssl_root_cert of the system certificate authorities
+	sslRootCertSystem = "system"
+)
+
+// tlsConfigFromTarget builds the TLS configuration of a target.
+//
+// Returns nil when TLS is disabled. The server certificate is only
+// verified when ssl_root_cert is set, otherwise any certificate is
+// accepted, as with ssl: true on Postgres targets.
+func tlsConfigFromTarget(target Target) (*tls.Config, error) {
+	if !target.Ssl && target.SslRootCert == "" && target.SslCert == "" {
+		return nil, nil
+	}
+
+	config := &tls.Config{ServerName: tlsServerName(target)}
+
+	if target.SslRootCert != "" {
+		pool, err := loadRootCerts(target.SslRootCert)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	} else {
+		config.InsecureSkipVerify = true
+	}
+
+	if err := loadClientCert(target, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// postgresTLSConfig builds the TLS configuration of a Postgres or
+// Redshift target, following the sslmode of libpq:
+//
+//   - disable: no TLS
+//   - require: TLS, verifying the certificate authority only when
+//     ssl_root_cert is set
+//   - verify-ca: TLS, verifying the certificate authority
+//   - verify-full: TLS, verifying the certificate authority and that
+//     the certificate matches ssl_server_name, or else the host
+//
+// The certificate authorities are read from ssl_root_cert, falling back
+// to those of the system, as does ssl_root_cert: system, which implies
+// verify-full. Without sslmode, ssl: true means require.
+func postgresTLSConfig(target Target) (*tls.Config, error) {
+	mode := strings.ToLower(target.SslMode)
+	switch mode {
+	case "":
+		if target.SslRootCert == sslRootCertSystem {
+			mode = sslModeVerifyFull
+		} else if target.Ssl {
+			mode = sslModeRequire
+		} else {
+			return nil, nil
+		}
+	case sslModeDisable:
+		return nil, nil
+	case sslModeRequire, sslModeVerifyCA, sslModeVerifyFull:
+	default:
+		return nil, fmt.Errorf("unsupported sslmode %q (supported: %s, %s, %s, %s)",
+			target.SslMode, sslModeDisable, sslModeRequire, sslModeVerifyCA, sslModeVerifyFull)
+	}
+
+	if target.SslRootCert == sslRootCertSystem && mode != sslModeVerifyFull {
+		return nil, fmt.Errorf("ssl_root_cert system requires sslmode verify-full")
+	}
+	if mode == sslModeRequire && target.SslRootCert != "" {
+		mode = sslModeVerifyCA
+	}
+
+	config := &tls.Config{ServerName: tlsServerName(target)}
+	if err := loadClientCert(target, config); err != nil {
+		return nil, err
+	}
+	if mode == sslModeRequire {
+		config.InsecureSkipVerify = true
+		return config, nil
+	}
+
+	var roots *x509.CertPool
+	if target.SslRootCert != "" && target.SslRootCert != sslRootCertSystem {
+		pool, err := loadRootCerts(target.SslRootCert)
+		if err != nil {
+			return nil, err
+		}
+		roots = pool
+	}
+
+	if mode == sslModeVerifyCA {
+		// Skip the default verification, which checks the host name
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			return verifyCertificateChain(state, roots)
+		}
+	} else {
+		config.RootCAs = roots
+	}
+	return config, nil
+}
+
+// verifyCertificateChain verifies the certificate of a server against
+// roots, or the system certificate authorities when nil, regardless of
+// its host name.
+func verifyCertificateChain(state tls.ConnectionState, roots *x509.CertPool) error {
+	if len(state.PeerCertificates) == 0 {
+		return fmt.Errorf("server sent no certificate")
+	}
+
+	options := x509.VerifyOptions{Roots: roots, Intermediates: x509.NewCertPool()}
+	for _, cert := range state.PeerCertificates[1:] {
+		options.Intermediates.AddCert(cert)
+	}
+	_, err := state.PeerCertificates[0].Verify(options)
+	return err
+}
+
+// tlsServerName returns the name expected on the certificate of a
+// target's server.
+func tlsServerName(target Target) string {
+	if target.SslServerName != "" {
+		return target.SslServerName
+	}
+	return target.Host
+}
+
+// loadRootCerts reads the certificate authorities of ssl_root_cert.
+func loadRootCerts(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read ssl_root_cert: %s", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in ssl_root_cert %s", path)
+	}
+	return pool, nil
+}
+
+// loadClientCert adds the client certificate of a target, if any, to config.
+func loadClientCert(target Target, config *tls.Config) error {
+	if target.SslCert == "" && target.SslKey == "" {
+		return nil
+	}
+	if target.SslCert == "" || target.SslKey == "" {
+		return fmt.Errorf("ssl_cert and ssl_key must be set together")
+	}
+	cert, err := tls.LoadX509KeyPair(target.SslCert, target.SslKey)
+	if err != nil {
+		return fmt.Errorf("unable to load client certificate: %s", err)
+	}
+	config.Certificates = []tls.Certificate{cert}
+	return nil
+}
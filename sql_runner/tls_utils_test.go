@@ -0,0 +1,322 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testCertificates holds the paths of a generated CA and
+// of a certificate it signed for localhost.
+type testCertificates struct {
+	CA, Cert, Key string
+}
+
+// Helper generating a CA and a localhost certificate in dir
+func writeTestCertificates(t *testing.T, dir string) testCertificates {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "sql-runner test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caTemplate, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certs := testCertificates{
+		CA:   filepath.Join(dir, "ca.pem"),
+		Cert: filepath.Join(dir, "cert.pem"),
+		Key:  filepath.Join(dir, "key.pem"),
+	}
+	writePem := func(path string, blockType string, bytes []byte) {
+		data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: bytes})
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writePem(certs.CA, "CERTIFICATE", caDer)
+	writePem(certs.Cert, "CERTIFICATE", der)
+	writePem(certs.Key, "EC PRIVATE KEY", keyDer)
+	return certs
+}
+
+func TestTlsConfigFromTarget(t *testing.T) {
+	assert := assert.New(t)
+	certs := writeTestCertificates(t, t.TempDir())
+
+	config, err := tlsConfigFromTarget(Target{Host: "db"})
+	assert.Nil(err)
+	assert.Nil(config)
+
+	config, err = tlsConfigFromTarget(Target{Host: "db", Ssl: true})
+	assert.Nil(err)
+	if assert.NotNil(config) {
+		assert.True(config.InsecureSkipVerify)
+		assert.Equal("db", config.ServerName)
+	}
+
+	config, err = tlsConfigFromTarget(Target{
+		Host:          "10.0.0.1",
+		SslRootCert:   certs.CA,
+		SslCert:       certs.Cert,
+		SslKey:        certs.Key,
+		SslServerName: "localhost",
+	})
+	assert.Nil(err)
+	if assert.NotNil(config) {
+		assert.False(config.InsecureSkipVerify)
+		assert.NotNil(config.RootCAs)
+		assert.Equal("localhost", config.ServerName)
+		assert.Equal(1, len(config.Certificates))
+	}
+}
+
+func TestTlsConfigFromTarget_Error(t *testing.T) {
+	certs := writeTestCertificates(t, t.TempDir())
+
+	testCases := []struct {
+		Name      string
+		Input     Target
+		ErrString string
+	}{
+		{
+			Name:      "cert_without_key",
+			Input:     Target{Ssl: true, SslCert: certs.Cert},
+			ErrString: "ssl_cert and ssl_key must be set together",
+		},
+		{
+			Name:      "root_cert_not_pem",
+			Input:     Target{SslRootCert: certs.Key},
+			ErrString: "no certificates found in ssl_root_cert " + certs.Key,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			_, err := tlsConfigFromTarget(tt.Input)
+			if assert.NotNil(t, err) {
+				assert.Equal(t, tt.ErrString, err.Error())
+			}
+		})
+	}
+}
+
+func TestPostgresTLSConfig(t *testing.T) {
+	assert := assert.New(t)
+	certs := writeTestCertificates(t, t.TempDir())
+
+	for _, target := range []Target{
+		{Host: "db"},
+		{Host: "db", Ssl: true, SslMode: "disable"},
+		{Host: "db", SslRootCert: certs.CA},
+	} {
+		config, err := postgresTLSConfig(target)
+		assert.Nil(err)
+		assert.Nil(config)
+	}
+
+	config, err := postgresTLSConfig(Target{Host: "db", Ssl: true})
+	assert.Nil(err)
+	if assert.NotNil(config) {
+		assert.True(config.InsecureSkipVerify)
+		assert.Nil(config.VerifyConnection)
+	}
+
+	config, err = postgresTLSConfig(Target{Host: "db", SslMode: "require", SslRootCert: certs.CA})
+	assert.Nil(err)
+	if assert.NotNil(config) {
+		// Like libpq, a root certificate turns require into verify-ca
+		assert.True(config.InsecureSkipVerify)
+		assert.NotNil(config.VerifyConnection)
+	}
+
+	config, err = postgresTLSConfig(Target{Host: "db", SslMode: "VERIFY-FULL", SslCert: certs.Cert, SslKey: certs.Key})
+	assert.Nil(err)
+	if assert.NotNil(config) {
+		assert.False(config.InsecureSkipVerify)
+		assert.Nil(config.RootCAs)
+		assert.Equal("db", config.ServerName)
+		assert.Equal(1, len(config.Certificates))
+	}
+
+	config, err = postgresTLSConfig(Target{Host: "db", SslRootCert: "system"})
+	assert.Nil(err)
+	if assert.NotNil(config) {
+		assert.False(config.InsecureSkipVerify)
+		assert.Nil(config.RootCAs)
+	}
+}
+
+func TestPostgresTLSConfig_Error(t *testing.T) {
+	certs := writeTestCertificates(t, t.TempDir())
+
+	testCases := []struct {
+		Name      string
+		Input     Target
+		ErrString string
+	}{
+		{
+			Name:      "unsupported_sslmode",
+			Input:     Target{SslMode: "prefer"},
+			ErrString: `unsupported sslmode "prefer" (supported: disable, require, verify-ca, verify-full)`,
+		},
+		{
+			Name:      "system_without_verify_full",
+			Input:     Target{SslMode: "verify-ca", SslRootCert: "system"},
+			ErrString: "ssl_root_cert system requires sslmode verify-full",
+		},
+		{
+			Name:      "key_without_cert",
+			Input:     Target{SslMode: "require", SslKey: certs.Key},
+			ErrString: "ssl_cert and ssl_key must be set together",
+		},
+		{
+			Name:      "root_cert_not_pem",
+			Input:     Target{SslMode: "verify-full", SslRootCert: certs.Key},
+			ErrString: "no certificates found in ssl_root_cert " + certs.Key,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			_, err := postgresTLSConfig(tt.Input)
+			if assert.NotNil(t, err) {
+				assert.Equal(t, tt.ErrString, err.Error())
+			}
+		})
+	}
+}
+
+// Helper running a TLS handshake against a server presenting certs
+func tlsHandshake(t *testing.T, certs testCertificates, config *tls.Config) error {
+	t.Helper()
+
+	cert, err := tls.LoadX509KeyPair(certs.Cert, certs.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.(*tls.Conn).Handshake()
+	}()
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), config)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func TestPostgresTLSConfig_Handshake(t *testing.T) {
+	certs := writeTestCertificates(t, t.TempDir())
+	otherCerts := writeTestCertificates(t, t.TempDir())
+
+	testCases := []struct {
+		Name      string
+		Input     Target
+		ErrString string
+	}{
+		{
+			Name:  "require",
+			Input: Target{Host: "127.0.0.1", SslMode: "require"},
+		},
+		{
+			Name:  "verify_ca_ignores_host",
+			Input: Target{Host: "127.0.0.1", SslMode: "verify-ca", SslRootCert: certs.CA},
+		},
+		{
+			Name:      "verify_ca_unknown_authority",
+			Input:     Target{Host: "127.0.0.1", SslMode: "verify-ca", SslRootCert: otherCerts.CA},
+			ErrString: "certificate signed by unknown authority",
+		},
+		{
+			Name:      "verify_full_checks_host",
+			Input:     Target{Host: "db.example.com", SslMode: "verify-full", SslRootCert: certs.CA},
+			ErrString: "certificate is valid for localhost, not db.example.com",
+		},
+		{
+			Name:  "verify_full_server_name",
+			Input: Target{Host: "127.0.0.1", SslMode: "verify-full", SslRootCert: certs.CA, SslServerName: "localhost"},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			config, err := postgresTLSConfig(tt.Input)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = tlsHandshake(t, certs, config)
+			if tt.ErrString == "" {
+				assert.Nil(t, err)
+			} else if assert.NotNil(t, err) {
+				assert.Contains(t, err.Error(), tt.ErrString)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/trinodb/trino-go-client/trino"
+)
+
+const (
+	trinoSource = "sql-runner"
+
+	trinoStatementPath = "/v1/statement" // Queries are submitted to it
+)
+
+// TrinoTarget represents Trino and Presto as target.
+type TrinoTarget struct {
+	Target
+	Client *sql.DB
+	ctx    context.Context
+	tunnel *sshTunnel
+}
+
+// IsConnectable tests connection to determine whether the Trino target is
+// connectable. Opening a connection does not reach the coordinator, so a
+// trivial query is run instead.
+func (tt TrinoTarget) IsConnectable() bool {
+	var one int
+	return tt.Client.QueryRowContext(tt.ctx, "SELECT 1").Scan(&one) == nil
+}
+
+func init() {
+	registerTarget(NewTrinoTarget, trinoType)
+}
+
+// NewTrinoTarget returns a ptr to a TrinoTarget.
+func NewTrinoTarget(target Target) (*TrinoTarget, error) {
+	if target.Host == "" || target.Port == "" || target.Username == "" {
+		return nil, fmt.Errorf("missing target connection parameters")
+	}
+
+	tlsConfig, err := tlsConfigFromTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	dial, tunnel, err := dialerFromTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	server := url.URL{
+		Scheme: "http",
+		User:   url.User(target.Username),
+		Host:   net.JoinHostPort(target.Host, target.Port),
+	}
+	if tlsConfig != nil {
+		server.Scheme = "https"
+	}
+	if target.Password != "" {
+		// The driver drops passwords over plain http
+		if tlsConfig == nil {
+			return nil, fmt.Errorf("password authentication requires ssl")
+		}
+		server.User = url.UserPassword(target.Username, target.Password)
+	}
+
+	config := trino.Config{
+		ServerURI:         server.String(),
+		Source:            trinoSource,
+		Catalog:           target.Catalog,
+		Schema:            target.Schema,
+		SessionProperties: target.SessionProperties,
+	}
+
+	config.CustomClientName = trinoSource + "-" + target.Name
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	if tunnel != nil {
+		// The coordinator is reached from the bastion, not a proxy
+		transport.DialContext = dial
+		transport.Proxy = nil
+	}
+	client := &http.Client{Transport: trinoQueryIDTransport{transport}}
+	if err := trino.RegisterCustomClient(config.CustomClientName, client); err != nil {
+		return nil, err
+	}
+
+	dsn, err := config.FormatDSN()
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("trino", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := configurePool(db, target); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &TrinoTarget{target, db, interruptContext(), tunnel}, nil
+}
+
+// GetTarget returns the Target field of TrinoTarget.
+func (tt TrinoTarget) GetTarget() Target {
+	return tt.Target
+}
+
+// Close closes the client of the Trino target and its SSH tunnel.
+func (tt TrinoTarget) Close() error {
+	return closeTunneled(tt.Client, tt.tunnel)
+}
+
+// RunQuery runs a query against the target.
+func (tt TrinoTarget) RunQuery(query ReadyQuery, dryRun bool, showQueryOutput bool) QueryStatus {
+	return runScript(query, dryRun, showQueryOutput, tt.FetchResults, func() (int, error) {
+		return tt.exec(query.Script)
+	})
+}
+
+// FetchResults runs a query and returns its result sets.
+func (tt TrinoTarget) FetchResults(query ReadyQuery) ([]ResultSet, error) {
+	return tt.queryResults(query.Script)
+}
+
+// exec runs each statement of a script, as Trino takes a single
+// statement per request, on a single connection so the session
+// carries over, returning the rows affected summed over all of
+// them. Cancelling the context cancels the running query on the
+// coordinator.
+func (tt TrinoTarget) exec(script string) (int, error) {
+	conn, err := tt.Client.Conn(tt.ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	affected := 0
+	for _, statement := range splitStatements(script, standardDialect) {
+		queryID := &trinoQueryID{}
+		res, err := conn.ExecContext(queryID.context(tt.ctx), statement)
+		if err != nil {
+			return affected, queryID.wrap(err)
+		}
+		if rows, err := res.RowsAffected(); err == nil {
+			affected += int(rows)
+		}
+	}
+	return affected, nil
+}
+
+// queryResults runs each statement of a script on a single
+// connection, returning the rows of those which produced a result
+// set.
+func (tt TrinoTarget) queryResults(script string) ([]ResultSet, error) {
+	conn, err := tt.Client.Conn(tt.ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	sets := make([]ResultSet, 0)
+	for _, statement := range splitStatements(script, standardDialect) {
+		queryID := &trinoQueryID{}
+		rows, err := conn.QueryContext(queryID.context(tt.ctx), statement)
+		if err != nil {
+			return nil, queryID.wrap(err)
+		}
+
+		results, err := resultSetsFromRows(rows, nil)
+		rows.Close()
+		if err != nil {
+			return nil, queryID.wrap(err)
+		}
+		sets = append(sets, results...)
+	}
+	return sets, nil
+}
+
+// trinoQueryID captures the id of a query, which the driver does not
+// report on errors.
+type trinoQueryID struct {
+	mu sync.Mutex
+	id string
+}
+
+// trinoQueryIDKey carries the trinoQueryID of a query in the context
+// of its requests.
+type trinoQueryIDKey struct{}
+
+// context returns a context for running the query, in which its id
+// is captured.
+func (q *trinoQueryID) context(ctx context.Context) context.Context {
+	return context.WithValue(ctx, trinoQueryIDKey{}, q)
+}
+
+// set records the id of the query.
+func (q *trinoQueryID) set(id string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.id = id
+}
+
+// wrap adds the query id to an error, when it is known.
+func (q *trinoQueryID) wrap(err error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.id == "" {
+		return err
+	}
+	return errors.Wrap(err, fmt.Sprintf("QueryID: %s", q.id))
+}
+
+// trinoQueryIDTransport reads the id of each query from the response
+// of the coordinator accepting it, before the query can fail.
+type trinoQueryIDTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t trinoQueryIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	queryID, ok := req.Context().Value(trinoQueryIDKey{}).(*trinoQueryID)
+	if err != nil || !ok || req.Method != http.MethodPost || !strings.HasSuffix(req.URL.Path, trinoStatementPath) || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var accepted struct {
+		ID string `json:"id"`
+	}
+	if json.Unmarshal(body, &accepted) == nil && accepted.ID != "" {
+		queryID.set(accepted.ID)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
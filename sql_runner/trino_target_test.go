@@ -0,0 +1,275 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTrino is a coordinator which answers the statement protocol with
+// canned results: SELECT 1 checks the connection, other SELECTs return
+// two rows, INSERT reports one row, FAIL fails once running, FAST fails
+// before running and SLEEP runs until it is cancelled.
+type fakeTrino struct {
+	mu        sync.Mutex
+	server    *httptest.Server
+	queries   []string
+	headers   http.Header
+	cancelled []string
+	sleeping  chan struct{}
+}
+
+func newFakeTrino(t *testing.T) *fakeTrino {
+	ft := &fakeTrino{sleeping: make(chan struct{}, 1)}
+	ft.server = httptest.NewServer(http.HandlerFunc(ft.handle))
+	t.Cleanup(ft.server.Close)
+	return ft
+}
+
+func (ft *fakeTrino) target() Target {
+	host, port, _ := net.SplitHostPort(ft.server.Listener.Addr().String())
+	return Target{
+		Name:              "trino",
+		Type:              trinoType,
+		Host:              host,
+		Port:              port,
+		Username:          "snowplow",
+		Catalog:           "memory",
+		Schema:            "default",
+		SessionProperties: map[string]string{"query_max_run_time": "1h"},
+	}
+}
+
+func (ft *fakeTrino) handle(w http.ResponseWriter, r *http.Request) {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/statement":
+		body, _ := io.ReadAll(r.Body)
+		ft.queries = append(ft.queries, string(body))
+		ft.headers = r.Header.Clone()
+		id := fmt.Sprintf("query_%d", len(ft.queries))
+		ft.respond(w, map[string]interface{}{
+			"id":      id,
+			"nextUri": ft.server.URL + "/v1/statement/executing/" + id + "/1",
+			"stats":   map[string]interface{}{"state": "QUEUED"},
+		})
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/1"):
+		// Like Trino, report the query running before its results
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/statement/executing/"), "/1")
+		var n int
+		fmt.Sscanf(id, "query_%d", &n)
+		if strings.HasPrefix(ft.queries[n-1], "FAST") {
+			ft.respond(w, ft.result(id, ft.queries[n-1], r))
+			return
+		}
+		ft.respond(w, map[string]interface{}{
+			"id":      id,
+			"nextUri": ft.server.URL + "/v1/statement/executing/" + id + "/2",
+			"stats":   map[string]interface{}{"state": "RUNNING"},
+		})
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/2"):
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/statement/executing/"), "/2")
+		var n int
+		fmt.Sscanf(id, "query_%d", &n)
+		ft.respond(w, ft.result(id, ft.queries[n-1], r))
+	case r.Method == http.MethodDelete:
+		ft.cancelled = append(ft.cancelled, strings.TrimPrefix(r.URL.Path, "/v1/query/"))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (ft *fakeTrino) result(id, query string, r *http.Request) map[string]interface{} {
+	finished := map[string]interface{}{"state": "FINISHED"}
+	bigint := map[string]interface{}{"rawType": "bigint", "arguments": []interface{}{}}
+	switch {
+	case query == "SELECT 1":
+		return map[string]interface{}{
+			"id":      id,
+			"columns": []map[string]interface{}{{"name": "_col0", "type": "bigint", "typeSignature": bigint}},
+			"data":    [][]interface{}{{1}},
+			"stats":   finished,
+		}
+	case strings.HasPrefix(query, "SELECT"):
+		return map[string]interface{}{
+			"id": id,
+			"columns": []map[string]interface{}{
+				{"name": "n", "type": "bigint", "typeSignature": bigint},
+				{"name": "s", "type": "varchar", "typeSignature": map[string]interface{}{"rawType": "varchar", "arguments": []interface{}{}}},
+			},
+			"data":  [][]interface{}{{1, "a"}, {2, "b"}},
+			"stats": finished,
+		}
+	case strings.HasPrefix(query, "INSERT"):
+		return map[string]interface{}{"id": id, "updateType": "INSERT", "updateCount": 1, "stats": finished}
+	case strings.HasPrefix(query, "SLEEP"):
+		ft.sleeping <- struct{}{}
+		ft.mu.Unlock()
+		<-r.Context().Done()
+		ft.mu.Lock()
+		return nil
+	default:
+		return map[string]interface{}{
+			"id":    id,
+			"stats": map[string]interface{}{"state": "FAILED"},
+			"error": map[string]interface{}{"message": "line 1:1: mismatched input", "errorName": "SYNTAX_ERROR", "errorType": "USER_ERROR"},
+		}
+	}
+}
+
+func (ft *fakeTrino) respond(w http.ResponseWriter, body map[string]interface{}) {
+	if body == nil {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+func TestNewTrinoTarget_Error(t *testing.T) {
+	testCases := []struct {
+		Name      string
+		Input     Target
+		ErrString string
+	}{
+		{
+			Name: "missing_username",
+			Input: Target{
+				Host: "localhost",
+				Port: "8080",
+			},
+			ErrString: "missing target connection parameters",
+		},
+		{
+			Name: "password_without_ssl",
+			Input: Target{
+				Host:     "localhost",
+				Port:     "8080",
+				Username: "snowplow",
+				Password: "secret",
+			},
+			ErrString: "password authentication requires ssl",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			result, err := NewTrinoTarget(tt.Input)
+			assert.Nil(result)
+			if err == nil {
+				t.Fatalf("expected error, got nil")
+			}
+
+			assert.Equal(tt.ErrString, err.Error())
+		})
+	}
+}
+
+func TestTrinoTarget_RunQuery(t *testing.T) {
+	assert := assert.New(t)
+	ft := newFakeTrino(t)
+
+	trino, err := NewTrinoTarget(ft.target())
+	assert.Nil(err)
+	assert.True(trino.IsConnectable())
+
+	script := "INSERT INTO t VALUES (1);\nINSERT INTO t VALUES (';');\n-- a trailing comment;"
+	status := trino.RunQuery(ReadyQuery{Script: script, Name: "insert", Path: "insert.sql"}, false, false)
+	assert.Nil(status.Error)
+	assert.Equal(2, status.Affected)
+	assert.Equal([]string{"SELECT 1", "INSERT INTO t VALUES (1)", "INSERT INTO t VALUES (';')"}, ft.queries)
+	assert.Equal("snowplow", ft.headers.Get("X-Trino-User"))
+	assert.Equal("memory", ft.headers.Get("X-Trino-Catalog"))
+	assert.Equal("default", ft.headers.Get("X-Trino-Schema"))
+	assert.Equal("query_max_run_time=1h", ft.headers.Get("X-Trino-Session"))
+	assert.Equal(trinoSource, ft.headers.Get("X-Trino-Source"))
+
+	sets, err := trino.FetchResults(ReadyQuery{Script: "SELECT n, s FROM t;"})
+	assert.Nil(err)
+	if assert.Len(sets, 1) {
+		assert.Equal([][]interface{}{{int64(1), "a"}, {int64(2), "b"}}, sets[0].Rows)
+		assert.Equal(KindInteger, sets[0].Columns[0].Kind)
+	}
+
+	status = trino.RunQuery(ReadyQuery{Script: "FAIL;", Name: "fail", Path: "fail.sql"}, false, false)
+	if assert.NotNil(status.Error) {
+		assert.Contains(status.Error.Error(), "QueryID: query_5")
+		assert.Contains(status.Error.Error(), "mismatched input")
+	}
+}
+
+func TestTrinoTarget_FastFailure(t *testing.T) {
+	assert := assert.New(t)
+	ft := newFakeTrino(t)
+
+	trino, err := NewTrinoTarget(ft.target())
+	assert.Nil(err)
+
+	// The query fails without ever being reported running
+	status := trino.RunQuery(ReadyQuery{Script: "SELECT 1;\nFAST;", Name: "fast", Path: "fast.sql"}, false, false)
+	if assert.NotNil(status.Error) {
+		assert.Contains(status.Error.Error(), "QueryID: query_2")
+		assert.Contains(status.Error.Error(), "mismatched input")
+	}
+
+	_, err = trino.FetchResults(ReadyQuery{Script: "FAST;"})
+	if assert.NotNil(err) {
+		assert.Contains(err.Error(), "QueryID: query_3")
+	}
+}
+
+func TestTrinoTarget_Cancel(t *testing.T) {
+	assert := assert.New(t)
+	ft := newFakeTrino(t)
+
+	trino, err := NewTrinoTarget(ft.target())
+	assert.Nil(err)
+	ctx, cancel := context.WithCancel(context.Background())
+	trino.ctx = ctx
+
+	go func() {
+		<-ft.sleeping
+		cancel()
+	}()
+
+	done := make(chan QueryStatus)
+	go func() {
+		done <- trino.RunQuery(ReadyQuery{Script: "SLEEP;", Name: "sleep", Path: "sleep.sql"}, false, false)
+	}()
+
+	select {
+	case status := <-done:
+		assert.ErrorIs(status.Error, context.Canceled)
+		assert.Contains(status.Error.Error(), "QueryID: query_1")
+	case <-time.After(10 * time.Second):
+		t.Fatal("query was not cancelled")
+	}
+
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+	assert.Equal([]string{"query_1"}, ft.cancelled)
+}
@@ -0,0 +1,402 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Auth methods of Vault
+const (
+	vaultAuthToken      = "token"
+	vaultAuthAppRole    = "approle"
+	vaultAuthKubernetes = "kubernetes"
+
+	vaultDefaultMount   = "database"
+	vaultKubernetesJwt  = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	vaultMinRenewPeriod = time.Second
+)
+
+// VaultAuth represents the Vault database secrets engine issuing the
+// credentials of a target, and how to log in to Vault.
+type VaultAuth struct {
+	Address   string // Defaults to VAULT_ADDR
+	Namespace string // Defaults to VAULT_NAMESPACE
+	Auth      string // token, approle or kubernetes
+	AuthMount string `yaml:"auth_mount"` // Defaults to the name of the auth method
+	Token     string // Defaults to VAULT_TOKEN
+	RoleId    string `yaml:"role_id"`
+	SecretId  string `yaml:"secret_id"`
+	AuthRole  string `yaml:"auth_role"` // Role of kubernetes auth
+	JwtPath   string `yaml:"jwt_path"`  // Defaults to the token of the service account
+	Mount     string // Database secrets engine, defaults to database
+	Role      string // Role of the database secrets engine
+}
+
+// vaultClient talks to the HTTP API of Vault.
+type vaultClient struct {
+	address   string
+	token     string
+	namespace string
+	loggedIn  bool // Whether the token was issued by logging in
+	client    *http.Client
+
+	// Expiration of a token issued by logging in, zero if it does not
+	// expire. Leases are revoked along with the token they were
+	// created with.
+	tokenExpiration time.Time
+	tokenRenewable  bool
+}
+
+// newVaultClient returns a client logged in as configured. Settings
+// left unset are read, as by the Vault CLI, from VAULT_ADDR,
+// VAULT_TOKEN and VAULT_NAMESPACE.
+func newVaultClient(settings VaultAuth) (*vaultClient, error) {
+	address := settings.Address
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	if address == "" {
+		return nil, fmt.Errorf("VAULT_ADDR is not set")
+	}
+	namespace := settings.Namespace
+	if namespace == "" {
+		namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+
+	vc := &vaultClient{
+		address:   strings.TrimRight(address, "/"),
+		namespace: namespace,
+		client:    &http.Client{Timeout: dialTimeout},
+	}
+	if err := vc.login(settings); err != nil {
+		return nil, err
+	}
+	return vc, nil
+}
+
+// login sets the token of the client, logging in with the auth method
+// of the settings.
+func (vc *vaultClient) login(settings VaultAuth) error {
+	method := strings.ToLower(settings.Auth)
+	mount := settings.AuthMount
+	if mount == "" {
+		mount = method
+	}
+
+	var body map[string]string
+	switch method {
+	case "", vaultAuthToken:
+		vc.token = settings.Token
+		if vc.token == "" {
+			vc.token = os.Getenv("VAULT_TOKEN")
+		}
+		if vc.token == "" {
+			return fmt.Errorf("VAULT_TOKEN is not set")
+		}
+		return nil
+	case vaultAuthAppRole:
+		if settings.RoleId == "" {
+			return fmt.Errorf("missing vault role_id")
+		}
+		body = map[string]string{"role_id": settings.RoleId, "secret_id": settings.SecretId}
+	case vaultAuthKubernetes:
+		if settings.AuthRole == "" {
+			return fmt.Errorf("missing vault auth_role")
+		}
+		path := settings.JwtPath
+		if path == "" {
+			path = vaultKubernetesJwt
+		}
+		jwt, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("unable to read vault jwt_path: %s", err)
+		}
+		body = map[string]string{"role": settings.AuthRole, "jwt": strings.TrimSpace(string(jwt))}
+	default:
+		return fmt.Errorf("unsupported vault auth %q (supported: %s, %s, %s)",
+			settings.Auth, vaultAuthToken, vaultAuthAppRole, vaultAuthKubernetes)
+	}
+
+	resp, err := vc.do(http.MethodPost, fmt.Sprintf("auth/%s/login", mount), body)
+	if err != nil {
+		return fmt.Errorf("cannot log in to vault with %s: %s", method, err)
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return fmt.Errorf("cannot log in to vault with %s: no token was returned", method)
+	}
+	vc.token = resp.Auth.ClientToken
+	vc.loggedIn = true
+	vc.tokenRenewable = resp.Auth.Renewable
+	if resp.Auth.LeaseDuration > 0 {
+		vc.tokenExpiration = time.Now().Add(time.Duration(resp.Auth.LeaseDuration) * time.Second)
+	}
+	return nil
+}
+
+// tokenExpires reports whether the token of a login has to be renewed
+// to keep its leases.
+func (vc *vaultClient) tokenExpires() bool {
+	return vc.loggedIn && vc.tokenRenewable && !vc.tokenExpiration.IsZero()
+}
+
+// renewToken extends the token of a login by increment, returning the
+// ttl granted, which is capped by the max ttl of the token.
+func (vc *vaultClient) renewToken(increment time.Duration) (time.Duration, error) {
+	resp, err := vc.do(http.MethodPost, "auth/token/renew-self", map[string]interface{}{
+		"increment": int(increment.Seconds()),
+	})
+	if err != nil {
+		return 0, err
+	}
+	if resp.Auth == nil {
+		return 0, fmt.Errorf("no token was returned")
+	}
+	ttl := time.Duration(resp.Auth.LeaseDuration) * time.Second
+	vc.tokenExpiration = time.Now().Add(ttl)
+	return ttl, nil
+}
+
+// vaultResponse is the envelope of Vault responses.
+type vaultResponse struct {
+	LeaseID       string                 `json:"lease_id"`
+	LeaseDuration int                    `json:"lease_duration"`
+	Renewable     bool                   `json:"renewable"`
+	Data          map[string]interface{} `json:"data"`
+	Auth          *vaultAuthResponse     `json:"auth"`
+	Errors        []string               `json:"errors"`
+}
+
+// vaultAuthResponse is the token issued by a login.
+type vaultAuthResponse struct {
+	ClientToken   string `json:"client_token"`
+	LeaseDuration int    `json:"lease_duration"`
+	Renewable     bool   `json:"renewable"`
+}
+
+// do sends a request to Vault, decoding its response.
+func (vc *vaultClient) do(method string, path string, body interface{}) (*vaultResponse, error) {
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequest(method, vc.address+"/v1/"+strings.TrimLeft(path, "/"), &payload)
+	if err != nil {
+		return nil, err
+	}
+	if vc.token != "" {
+		req.Header.Set("X-Vault-Token", vc.token)
+	}
+	if vc.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", vc.namespace)
+	}
+
+	resp, err := vc.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out vaultResponse
+	if resp.StatusCode == http.StatusNoContent {
+		return &out, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil && resp.StatusCode < 300 {
+		return nil, fmt.Errorf("unable to parse vault response: %s", err)
+	}
+	if resp.StatusCode >= 300 {
+		if len(out.Errors) > 0 {
+			return nil, fmt.Errorf("vault returned %s: %s", resp.Status, strings.Join(out.Errors, "; "))
+		}
+		return nil, fmt.Errorf("vault returned %s", resp.Status)
+	}
+	return &out, nil
+}
+
+// read returns the data of a secret. Secrets of KV version 2 engines
+// are nested in a data field, next to their metadata.
+func (vc *vaultClient) read(path string) (map[string]interface{}, error) {
+	resp, err := vc.do(http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Data == nil {
+		return nil, fmt.Errorf("vault secret %s has no data", path)
+	}
+
+	data, nested := resp.Data["data"].(map[string]interface{})
+	if _, versioned := resp.Data["metadata"]; nested && versioned {
+		return data, nil
+	}
+	return resp.Data, nil
+}
+
+// vaultLease is a lease of dynamic database credentials, renewed in
+// the background so that they outlive long runs, until it is revoked.
+type vaultLease struct {
+	client             *vaultClient
+	id                 string
+	Username, Password string
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newVaultLease logs in to Vault and leases credentials of the role
+// of the settings.
+func newVaultLease(settings VaultAuth) (*vaultLease, error) {
+	if settings.Role == "" {
+		return nil, fmt.Errorf("missing vault role")
+	}
+	mount := settings.Mount
+	if mount == "" {
+		mount = vaultDefaultMount
+	}
+
+	client, err := newVaultClient(settings)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.do(http.MethodGet, fmt.Sprintf("%s/creds/%s", mount, settings.Role), nil)
+	if err != nil {
+		client.revokeToken()
+		return nil, fmt.Errorf("cannot get vault credentials of role %s: %s", settings.Role, err)
+	}
+	username, _ := resp.Data["username"].(string)
+	password, _ := resp.Data["password"].(string)
+	if username == "" || password == "" {
+		client.revokeToken()
+		return nil, fmt.Errorf("vault credentials of role %s have no username or password", settings.Role)
+	}
+	registerSecret(password)
+
+	vl := &vaultLease{
+		client:   client,
+		id:       resp.LeaseID,
+		Username: username,
+		Password: password,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	expiration := time.Now().Add(time.Duration(resp.LeaseDuration) * time.Second)
+	log.Printf("Leased vault credentials of role %s, expiring at %s", settings.Role, expiration.Format(time.RFC3339))
+	if client.loggedIn && !client.tokenRenewable && !client.tokenExpiration.IsZero() && client.tokenExpiration.Before(expiration) {
+		log.Printf("WARNING: Vault token cannot be renewed, so lease %s could not be renewed and will expire at %s along with it",
+			vl.id, client.tokenExpiration.Format(time.RFC3339))
+	}
+	if resp.Renewable || client.tokenExpires() {
+		go vl.renew(expiration, time.Duration(resp.LeaseDuration)*time.Second, resp.Renewable)
+	} else {
+		close(vl.done)
+	}
+	return vl, nil
+}
+
+// renew extends the lease halfway to its expiration, retrying failed
+// renewals until the lease is revoked or expires. The token of a login
+// is renewed along with it, as its leases are revoked once it expires.
+// Renewals are capped by the max ttl of the role, and of the token.
+func (vl *vaultLease) renew(expiration time.Time, increment time.Duration, renewable bool) {
+	defer close(vl.done)
+
+	capped, tokenCapped := false, false
+	for {
+		next := expiration
+		if vl.client.tokenExpires() && vl.client.tokenExpiration.Before(next) {
+			next = vl.client.tokenExpiration
+		}
+		wait := time.Until(next) / 2
+		if wait < vaultMinRenewPeriod {
+			wait = vaultMinRenewPeriod
+		}
+		select {
+		case <-vl.stop:
+			return
+		case <-time.After(wait):
+		}
+
+		if vl.client.tokenExpires() {
+			ttl, err := vl.client.renewToken(increment)
+			switch {
+			case err != nil:
+				log.Printf("WARNING: Cannot renew vault token of lease %s, retrying: %s", vl.id, err)
+			case ttl < time.Until(expiration) || ttl < increment:
+				if !tokenCapped {
+					log.Printf("WARNING: Vault token is capped by its max ttl, expiring at %s along with lease %s",
+						vl.client.tokenExpiration.Format(time.RFC3339), vl.id)
+					tokenCapped = true
+				}
+			}
+		}
+
+		if !renewable {
+			if time.Now().After(expiration) {
+				return
+			}
+			continue
+		}
+
+		resp, err := vl.client.do(http.MethodPut, "sys/leases/renew", map[string]interface{}{
+			"lease_id":  vl.id,
+			"increment": int(increment.Seconds()),
+		})
+		switch {
+		case err != nil && time.Now().After(expiration):
+			log.Printf("WARNING: Vault lease %s has expired: %s", vl.id, err)
+			return
+		case err != nil:
+			log.Printf("WARNING: Cannot renew vault lease %s, retrying: %s", vl.id, err)
+		case resp.LeaseDuration <= 0:
+			log.Printf("WARNING: Vault lease %s has expired", vl.id)
+			return
+		default:
+			expiration = time.Now().Add(time.Duration(resp.LeaseDuration) * time.Second)
+			if time.Duration(resp.LeaseDuration)*time.Second < increment && !capped {
+				log.Printf("WARNING: Vault lease %s is capped by its max ttl, expiring at %s", vl.id, expiration.Format(time.RFC3339))
+				capped = true
+			}
+		}
+	}
+}
+
+// revoke stops renewing the lease and revokes it, dropping its
+// credentials, along with the token of a login.
+func (vl *vaultLease) revoke() error {
+	close(vl.stop)
+	<-vl.done
+
+	_, err := vl.client.do(http.MethodPut, "sys/leases/revoke", map[string]string{"lease_id": vl.id})
+	if err != nil {
+		err = fmt.Errorf("cannot revoke vault lease %s: %s", vl.id, err)
+	}
+	vl.client.revokeToken()
+	return err
+}
+
+// revokeToken revokes the token of a login, which is only used once.
+func (vc *vaultClient) revokeToken() {
+	if !vc.loggedIn {
+		return
+	}
+	if _, err := vc.do(http.MethodPost, "auth/token/revoke-self", nil); err != nil {
+		log.Printf("WARNING: Cannot revoke vault token: %s", err)
+	}
+}
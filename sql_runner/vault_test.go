@@ -0,0 +1,419 @@
+// Copyright (c) 2015-2025 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeVault stands in for a Vault dev server, with a KV version 2
+// engine mounted at secret/, a version 1 engine at kv/, the approle
+// and kubernetes auth methods, whose tokens last tokenTTL seconds up
+// to tokenMaxTTL, if set, and a database engine whose leases last
+// leaseDuration seconds, up to maxTTL. Leases are revoked along with
+// the token they were created with.
+type fakeVault struct {
+	mu            sync.Mutex
+	server        *httptest.Server
+	token         string
+	tokens        map[string]bool // Tokens issued by logins, false once revoked
+	secrets       map[string]map[string]interface{}
+	leaseDuration int
+	maxTTL        int
+	leases        map[string]bool // Leases issued, false once revoked
+	renewals      int
+
+	tokenTTL, tokenMaxTTL int
+	tokenExpirations      map[string]time.Time
+	tokenDeadlines        map[string]time.Time
+	tokenRenewals         int
+	leaseTokens           map[string]string
+}
+
+func newFakeVault(t *testing.T) *fakeVault {
+	fv := &fakeVault{
+		token:         "root",
+		tokens:        make(map[string]bool),
+		secrets:       make(map[string]map[string]interface{}),
+		leaseDuration: 3600,
+		maxTTL:        7200,
+		leases:        make(map[string]bool),
+
+		tokenExpirations: make(map[string]time.Time),
+		tokenDeadlines:   make(map[string]time.Time),
+		leaseTokens:      make(map[string]string),
+	}
+	fv.server = httptest.NewServer(http.HandlerFunc(fv.handle))
+	t.Cleanup(fv.server.Close)
+
+	t.Setenv("VAULT_ADDR", fv.server.URL)
+	t.Setenv("VAULT_TOKEN", fv.token)
+	t.Setenv("VAULT_NAMESPACE", "")
+	return fv
+}
+
+func (fv *fakeVault) writeError(w http.ResponseWriter, status int, message string) {
+	errs := []string{}
+	if message != "" {
+		errs = append(errs, message)
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string][]string{"errors": errs})
+}
+
+func (fv *fakeVault) handle(w http.ResponseWriter, r *http.Request) {
+	fv.mu.Lock()
+	defer fv.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	path := strings.TrimPrefix(r.URL.Path, "/v1/")
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	if strings.HasSuffix(path, "/login") {
+		switch {
+		case path == "auth/approle/login" && body["role_id"] == "sql-runner" && body["secret_id"] == "s3cret":
+		case path == "auth/k8s/login" && body["role"] == "sql-runner" && body["jwt"] == "eyJhbGciOi":
+		default:
+			fv.writeError(w, http.StatusBadRequest, "invalid credentials")
+			return
+		}
+		token := fmt.Sprintf("hvs.login-%d", len(fv.tokens)+1)
+		fv.tokens[token] = true
+		if fv.tokenTTL > 0 {
+			fv.tokenExpirations[token] = time.Now().Add(time.Duration(fv.tokenTTL) * time.Second)
+			fv.tokenDeadlines[token] = time.Now().Add(time.Duration(fv.tokenMaxTTL) * time.Second)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"auth": map[string]interface{}{
+			"client_token": token, "lease_duration": fv.tokenTTL, "renewable": fv.tokenTTL > 0,
+		}})
+		return
+	}
+
+	fv.expireTokens()
+	token := r.Header.Get("X-Vault-Token")
+	if token != fv.token && !fv.tokens[token] {
+		fv.writeError(w, http.StatusForbidden, "permission denied")
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "database/creds/"):
+		id := fmt.Sprintf("database/creds/sql-runner/lease-%d", len(fv.leases)+1)
+		fv.leases[id] = true
+		fv.leaseTokens[id] = token
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"lease_id":       id,
+			"lease_duration": fv.leaseDuration,
+			"renewable":      true,
+			"data":           map[string]interface{}{"username": "v-sql-runner", "password": "leased"},
+		})
+	case path == "sys/leases/renew":
+		if !fv.leases[fmt.Sprint(body["lease_id"])] {
+			fv.writeError(w, http.StatusBadRequest, "lease not found")
+			return
+		}
+		fv.renewals++
+		duration := fv.leaseDuration
+		if remaining := fv.maxTTL - fv.renewals*fv.leaseDuration; remaining < duration {
+			duration = remaining
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"lease_id": body["lease_id"], "lease_duration": duration, "renewable": true})
+	case path == "sys/leases/revoke":
+		fv.leases[fmt.Sprint(body["lease_id"])] = false
+		w.WriteHeader(http.StatusNoContent)
+	case path == "auth/token/renew-self":
+		fv.tokenRenewals++
+		expiration := time.Now().Add(time.Duration(body["increment"].(float64)) * time.Second)
+		if deadline := fv.tokenDeadlines[token]; expiration.After(deadline) {
+			expiration = deadline
+		}
+		fv.tokenExpirations[token] = expiration
+		json.NewEncoder(w).Encode(map[string]interface{}{"auth": map[string]interface{}{
+			"client_token": token, "lease_duration": int(time.Until(expiration).Round(time.Second).Seconds()), "renewable": true,
+		}})
+	case path == "auth/token/revoke-self":
+		fv.tokens[token] = false
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "secret/data/"):
+		data, ok := fv.secrets[strings.TrimPrefix(path, "secret/data/")]
+		if !ok {
+			fv.writeError(w, http.StatusNotFound, "")
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"data": data, "metadata": map[string]interface{}{"version": 1}},
+		})
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "kv/"):
+		data, ok := fv.secrets[strings.TrimPrefix(path, "kv/")]
+		if !ok {
+			fv.writeError(w, http.StatusNotFound, "")
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	default:
+		fv.writeError(w, http.StatusNotFound, "no handler for route")
+	}
+}
+
+// expireTokens revokes the expired tokens, and their leases.
+func (fv *fakeVault) expireTokens() {
+	for token, expiration := range fv.tokenExpirations {
+		if fv.tokens[token] && time.Now().After(expiration) {
+			fv.tokens[token] = false
+			for id, owner := range fv.leaseTokens {
+				if owner == token {
+					fv.leases[id] = false
+				}
+			}
+		}
+	}
+}
+
+func TestVaultClient_Read(t *testing.T) {
+	assert := assert.New(t)
+	fv := newFakeVault(t)
+	fv.secrets["sql-runner"] = map[string]interface{}{"password": "s3cret"}
+
+	client, err := newVaultClient(VaultAuth{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Secrets of both KV versions are unwrapped
+	data, err := client.read("secret/data/sql-runner")
+	assert.Nil(err)
+	assert.Equal(map[string]interface{}{"password": "s3cret"}, data)
+
+	data, err = client.read("kv/sql-runner")
+	assert.Nil(err)
+	assert.Equal(map[string]interface{}{"password": "s3cret"}, data)
+
+	_, err = client.read("secret/data/missing")
+	assert.EqualError(err, "vault returned 404 Not Found")
+
+	client.token = "expired"
+	_, err = client.read("secret/data/sql-runner")
+	assert.EqualError(err, "vault returned 403 Forbidden: permission denied")
+}
+
+func TestNewVaultClient_Error(t *testing.T) {
+	t.Setenv("VAULT_ADDR", "")
+	_, err := newVaultClient(VaultAuth{})
+	assert.EqualError(t, err, "VAULT_ADDR is not set")
+
+	t.Setenv("VAULT_ADDR", "http://127.0.0.1:8200")
+	t.Setenv("VAULT_TOKEN", "")
+	_, err = newVaultClient(VaultAuth{})
+	assert.EqualError(t, err, "VAULT_TOKEN is not set")
+}
+
+func TestNewVaultClient_Login(t *testing.T) {
+	fv := newFakeVault(t)
+	t.Setenv("VAULT_TOKEN", "")
+
+	jwt := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(jwt, []byte("eyJhbGciOi\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		Name      string
+		Input     VaultAuth
+		Token     string
+		ErrString string
+	}{
+		{
+			Name:  "token",
+			Input: VaultAuth{Address: fv.server.URL, Token: "root"},
+			Token: "root",
+		},
+		{
+			Name:  "approle",
+			Input: VaultAuth{Address: fv.server.URL, Auth: "approle", RoleId: "sql-runner", SecretId: "s3cret"},
+			Token: "hvs.login-1",
+		},
+		{
+			Name:  "kubernetes",
+			Input: VaultAuth{Address: fv.server.URL, Auth: "kubernetes", AuthMount: "k8s", AuthRole: "sql-runner", JwtPath: jwt},
+			Token: "hvs.login-2",
+		},
+		{
+			Name:      "missing_token",
+			Input:     VaultAuth{Address: fv.server.URL},
+			ErrString: "VAULT_TOKEN is not set",
+		},
+		{
+			Name:      "bad_secret_id",
+			Input:     VaultAuth{Address: fv.server.URL, Auth: "approle", RoleId: "sql-runner", SecretId: "guess"},
+			ErrString: "cannot log in to vault with approle: vault returned 400 Bad Request: invalid credentials",
+		},
+		{
+			Name:      "missing_role_id",
+			Input:     VaultAuth{Address: fv.server.URL, Auth: "approle"},
+			ErrString: "missing vault role_id",
+		},
+		{
+			Name:      "missing_jwt",
+			Input:     VaultAuth{Address: fv.server.URL, Auth: "kubernetes", AuthRole: "sql-runner", JwtPath: "/nonexistent/token"},
+			ErrString: "unable to read vault jwt_path: open /nonexistent/token: no such file or directory",
+		},
+		{
+			Name:      "unsupported_auth",
+			Input:     VaultAuth{Address: fv.server.URL, Auth: "ldap"},
+			ErrString: `unsupported vault auth "ldap" (supported: token, approle, kubernetes)`,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.Name, func(t *testing.T) {
+			client, err := newVaultClient(tt.Input)
+			if tt.ErrString != "" {
+				assert.EqualError(t, err, tt.ErrString)
+				return
+			}
+			if assert.Nil(t, err) {
+				assert.Equal(t, tt.Token, client.token)
+			}
+		})
+	}
+}
+
+func TestVaultLease(t *testing.T) {
+	assert := assert.New(t)
+	fv := newFakeVault(t)
+	fv.leaseDuration = 1
+	fv.maxTTL = 60
+
+	lease, err := newVaultLease(VaultAuth{Auth: "approle", RoleId: "sql-runner", SecretId: "s3cret", Role: "sql-runner"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal("v-sql-runner", lease.Username)
+	assert.Equal("leased", lease.Password)
+
+	// The lease is renewed before it expires
+	assert.Eventually(func() bool {
+		fv.mu.Lock()
+		defer fv.mu.Unlock()
+		return fv.renewals >= 2
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// Revoking drops the lease, and the token of the login
+	assert.Nil(lease.revoke())
+	fv.mu.Lock()
+	defer fv.mu.Unlock()
+	assert.Equal(map[string]bool{"database/creds/sql-runner/lease-1": false}, fv.leases)
+	assert.Equal(map[string]bool{"hvs.login-1": false}, fv.tokens)
+}
+
+func TestVaultLease_TokenRenewal(t *testing.T) {
+	assert := assert.New(t)
+	fv := newFakeVault(t)
+	fv.leaseDuration = 2
+	fv.maxTTL = 60
+	fv.tokenTTL = 2
+	fv.tokenMaxTTL = 60
+
+	lease, err := newVaultLease(VaultAuth{Auth: "approle", RoleId: "sql-runner", SecretId: "s3cret", Role: "sql-runner"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The token outlives its initial ttl, keeping the lease
+	time.Sleep(3 * time.Second)
+	fv.mu.Lock()
+	fv.expireTokens()
+	assert.True(fv.tokenRenewals >= 2)
+	assert.True(fv.tokens["hvs.login-1"])
+	assert.True(fv.leases["database/creds/sql-runner/lease-1"])
+	fv.mu.Unlock()
+
+	assert.Nil(lease.revoke())
+}
+
+func TestVaultLease_TokenCapped(t *testing.T) {
+	fv := newFakeVault(t)
+	fv.leaseDuration = 60
+	fv.maxTTL = 600
+	fv.tokenTTL = 2
+	fv.tokenMaxTTL = 3
+
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+
+	lease, err := newVaultLease(VaultAuth{Auth: "approle", RoleId: "sql-runner", SecretId: "s3cret", Role: "sql-runner"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The token cannot be renewed past its max ttl, shorter than the lease
+	assert.Eventually(t, func() bool {
+		fv.mu.Lock()
+		defer fv.mu.Unlock()
+		return fv.tokenRenewals >= 1
+	}, 5*time.Second, 50*time.Millisecond)
+	assert.Nil(t, lease.revoke())
+	assert.Contains(t, out.String(), "WARNING: Vault token is capped by its max ttl")
+}
+
+func TestNewVaultLease_Error(t *testing.T) {
+	newFakeVault(t)
+
+	_, err := newVaultLease(VaultAuth{})
+	assert.EqualError(t, err, "missing vault role")
+
+	_, err = newVaultLease(VaultAuth{Token: "expired", Role: "sql-runner"})
+	assert.EqualError(t, err, "cannot get vault credentials of role sql-runner: vault returned 403 Forbidden: permission denied")
+}
+
+func TestNewPostgresTarget_Vault(t *testing.T) {
+	assert := assert.New(t)
+	fv := newFakeVault(t)
+
+	target := Target{
+		Name:     "postgres",
+		Type:     postgresType,
+		Host:     "localhost",
+		Port:     "5432",
+		Database: "sql_runner_tests",
+		Vault:    &VaultAuth{Address: fv.server.URL, Token: "root", Role: "sql-runner"},
+	}
+	pt, err := NewPostgresTarget(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal("v-sql-runner", pt.Client.Options().User)
+	assert.Equal("leased", pt.Client.Options().Password)
+
+	assert.Nil(pt.Close())
+	fv.mu.Lock()
+	assert.Equal(map[string]bool{"database/creds/sql-runner/lease-1": false}, fv.leases)
+	fv.mu.Unlock()
+
+	target.Password = "snowplow"
+	_, err = NewPostgresTarget(target)
+	assert.EqualError(err, "vault credentials cannot be combined with a password or iam authentication")
+}
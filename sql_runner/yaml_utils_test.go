@@ -36,6 +36,104 @@ func TestParsePlaybookYaml(t *testing.T) {
 	assert.NotNil(playbook)
 	assert.Equal(2, len(playbook.Targets))
 	assert.Equal(6, len(playbook.Steps))
+	assert.Equal(3, len(playbook.Steps[4].Queries))
+	assert.Equal(0, *playbook.Steps[4].Queries[1].Assert.ExpectRows)
+
+	playbookBytes, err1 = loadLocalFile("../integration/resources/good-postgres-checks.yml")
+	assert.Nil(err1)
+
+	playbook, err = parsePlaybookYaml(playbookBytes, nil)
+	assert.Nil(err)
+	assert.Nil(playbook.Validate())
+	assert.Equal(4, len(playbook.Steps[2].Checks))
+	assert.Equal([]string{"us"}, playbook.Steps[2].Checks[3].Values)
+	assert.Equal(severityWarn, playbook.Steps[2].Checks[3].Severity)
+
+	playbookBytes, err1 = loadLocalFile("../integration/resources/good-postgres-compare.yml")
+	assert.Nil(err1)
+
+	playbook, err = parsePlaybookYaml(playbookBytes, nil)
+	assert.Nil(err)
+	assert.Nil(playbook.Validate())
+	assert.Equal([]string{"My Postgres database 1", "My Postgres database 2"}, playbook.Steps[2].Compare.Targets)
+	assert.True(playbook.Steps[2].Compare.Template)
+	assert.Equal(compareChecksum, playbook.Steps[3].Compare.Mode)
+
+	playbookBytes, err1 = loadLocalFile("../integration/resources/good-mysql.yml")
+	assert.Nil(err1)
+
+	playbook, err = parsePlaybookYaml(playbookBytes, nil)
+	assert.Nil(err)
+	assert.Nil(playbook.Validate())
+	assert.Equal(mysqlType, playbook.Targets[0].Type)
+	assert.Equal("3", *playbook.Steps[2].Queries[1].Assert.ExpectValue)
+
+	playbookBytes, err1 = loadLocalFile("../integration/resources/good-clickhouse-http.yml")
+	assert.Nil(err1)
+
+	playbook, err = parsePlaybookYaml(playbookBytes, nil)
+	assert.Nil(err)
+	assert.Nil(playbook.Validate())
+	assert.Equal(clickhouseType, playbook.Targets[0].Type)
+	assert.Equal("http", playbook.Targets[0].Protocol)
+	assert.Len(playbook.Steps[3].Checks, 2)
+
+	playbookBytes, err1 = loadLocalFile("../integration/resources/good-trino.yml")
+	assert.Nil(err1)
+
+	playbook, err = parsePlaybookYaml(playbookBytes, nil)
+	assert.Nil(err)
+	assert.Nil(playbook.Validate())
+	assert.Equal("memory", playbook.Targets[0].Catalog)
+	assert.Equal("default", playbook.Targets[0].Schema)
+	assert.Equal(map[string]string{"query_max_run_time": "10m"}, playbook.Targets[0].SessionProperties)
+
+	playbookBytes, err1 = loadLocalFile("../integration/resources/good-postgres-tls.yml")
+	assert.Nil(err1)
+
+	playbook, err = parsePlaybookYaml(playbookBytes, nil)
+	assert.Nil(err)
+	assert.Nil(playbook.Validate())
+	assert.Equal(sslModeVerifyFull, playbook.Targets[0].SslMode)
+	assert.Equal("integration/certs/ca.crt", playbook.Targets[0].SslRootCert)
+	assert.Equal("integration/certs/client.key", playbook.Targets[0].SslKey)
+
+	playbookBytes, err1 = loadLocalFile("../integration/resources/good-postgres-ssh.yml")
+	assert.Nil(err1)
+
+	playbook, err = parsePlaybookYaml(playbookBytes, nil)
+	assert.Nil(err)
+	assert.Nil(playbook.Validate())
+	assert.Equal(&SshTunnel{
+		Host:       "localhost",
+		Port:       "2223",
+		User:       "snowplow",
+		KeyPath:    "integration/certs/ssh_client_key",
+		KnownHosts: "integration/certs/known_hosts",
+		Keepalive:  10,
+	}, playbook.Targets[0].SshTunnel)
+
+	playbookBytes, err1 = loadLocalFile("../integration/resources/bad-postgres-validate.yml")
+	assert.Nil(err1)
+
+	playbook, err = parsePlaybookYaml(playbookBytes, nil)
+	assert.Nil(err)
+	assert.Nil(playbook.Validate())
+	assert.Len(playbook.Steps[0].Queries, 2)
+	assert.Equal("postgres-validate-sql/bad/syntax.sql", playbook.Steps[0].Queries[1].File)
+
+	playbookBytes, err1 = loadLocalFile("../integration/resources/good-postgres-vault.yml")
+	assert.Nil(err1)
+
+	playbook, err = parsePlaybookYaml(playbookBytes, nil)
+	assert.Nil(err)
+	assert.Nil(playbook.Validate())
+	assert.Equal(&VaultAuth{
+		Address: "http://localhost:8201",
+		Auth:    "token",
+		Token:   "env:SQL_RUNNER_VAULT_TOKEN",
+		Role:    "sql-runner",
+	}, playbook.Targets[0].Vault)
 }
 
 func TestCleanYaml(t *testing.T) {
@@ -116,6 +214,24 @@ func TestParse_QueryFlag(t *testing.T) {
 				Steps:     nil,
 			},
 		},
+		{
+			Name: "sensitive",
+			Playbook: `
+:variables:
+  :api_key: secret
+  :schema:  atomic
+:sensitive: [api_key]
+`,
+			Expected: &Playbook{
+				Targets: nil,
+				Variables: map[string]interface{}{
+					"api_key": "secret",
+					"schema":  "atomic",
+				},
+				Sensitive: []string{"api_key"},
+				Steps:     nil,
+			},
+		},
 	}
 
 	noVars := make(map[string]string)
@@ -233,6 +349,42 @@ func TestParsePlaybookYaml_compatibility(t *testing.T) {
 				},
 			},
 		},
+		{
+			Name: "assert",
+			Playbook: `
+:steps:
+- :name: checks
+  :queries:
+    - :name: no_orphans
+      :file: orphans.sql
+      :assert:
+        :expect_rows: 0
+        :expect_value: 7
+        :operator: "<"
+        :threshold: 10
+`,
+			Expected: &Playbook{
+				Targets:   nil,
+				Variables: map[string]interface{}{},
+				Steps: []Step{
+					{
+						Name: "checks",
+						Queries: []Query{
+							{
+								Name: "no_orphans",
+								File: "orphans.sql",
+								Assert: &Assertion{
+									ExpectRows:  intPtr(0),
+									ExpectValue: stringPtr("7"),
+									Operator:    "<",
+									Threshold:   floatPtr(10),
+								},
+							},
+						},
+					},
+				},
+			},
+		},
 	}
 
 	noVars := make(map[string]string)